@@ -1,15 +1,28 @@
 package main
 
 import (
-	"encoding/json"
-	"io"
+	"context"
 	"log"
 	"net/http"
 	"time"
 
+	"kosen-schedule-system/internal/api/auth"
+	"kosen-schedule-system/internal/api/calendar"
+	"kosen-schedule-system/internal/api/class"
 	"kosen-schedule-system/internal/api/csv"
+	"kosen-schedule-system/internal/api/department"
+	"kosen-schedule-system/internal/api/event"
+	"kosen-schedule-system/internal/api/notification"
+	"kosen-schedule-system/internal/api/permission"
+	"kosen-schedule-system/internal/api/request"
+	"kosen-schedule-system/internal/api/room"
+	"kosen-schedule-system/internal/api/student"
+	"kosen-schedule-system/internal/api/teacher"
 	"kosen-schedule-system/internal/api/timetable"
+	"kosen-schedule-system/internal/api/webhook"
 	"kosen-schedule-system/internal/config"
+	"kosen-schedule-system/internal/mailer"
+	appmiddleware "kosen-schedule-system/internal/middleware"
 	"kosen-schedule-system/internal/models"
 	"kosen-schedule-system/internal/services"
 
@@ -26,12 +39,94 @@ func main() {
 	}
 	defer db.Close()
 
+	cfg := config.Load()
+
 	// サービス初期化
+	authService := services.NewAuthService(db.DB)
+	permissionService := services.NewPermissionService(db.DB)
+	approvalPolicyService := services.NewApprovalPolicyService(db.DB)
+	oidcService := services.NewOIDCService(authService, services.OIDCConfig{
+		Issuer:         cfg.OIDCIssuer,
+		ClientID:       cfg.OIDCClientID,
+		ClientSecret:   cfg.OIDCClientSecret,
+		RedirectURL:    cfg.OIDCRedirectURL,
+		AllowedDomains: services.ParseOIDCList(cfg.OIDCAllowedDomains),
+		DomainRoles:    services.ParseOIDCMapping(cfg.OIDCDomainRoles),
+		GroupRoles:     services.ParseOIDCMapping(cfg.OIDCGroupRoles),
+		DefaultRole:    cfg.OIDCDefaultRole,
+	})
 	timetableService := services.NewTimetableService(db.DB)
 	classService := services.NewClassService(db.DB)
+	classGroupService := services.NewClassGroupService(db.DB)
+	departmentService := services.NewDepartmentService(db.DB)
+	enrollmentService := services.NewEnrollmentService(db.DB)
+	conflictService := services.NewConflictService(db.DB)
+	unavailabilityService := services.NewTeacherUnavailabilityService(db.DB)
+	csvService := services.NewCSVService(db.DB)
+	overrideService := services.NewTimetableOverrideService(db.DB)
+	substituteService := services.NewSubstituteService(db.DB)
+	changeRequestService := services.NewChangeRequestService(db.DB)
+
+	// 時間割の変更をプロセス内で配信（SSE の購読者へ）
+	eventBroker := services.NewEventBroker()
+	changeRequestService.SetEventBroker(eventBroker)
+	overrideService.SetEventBroker(eventBroker)
+	notificationService := services.NewNotificationService(db.DB)
+	webhookService := services.NewWebhookService(db.DB)
+	calendarFeedService := services.NewCalendarFeedService(db.DB, timetableService)
+	roomService := services.NewRoomService(db.DB, timetableService)
+
+	// 申請の期限切れ・リマインドをバックグラウンドで確認
+	schedulerInterval, err := time.ParseDuration(cfg.RequestSchedulerInterval)
+	if err != nil {
+		log.Fatal("Invalid REQUEST_SCHEDULER_INTERVAL:", err)
+	}
+	reminderBefore, err := time.ParseDuration(cfg.RequestReminderBefore)
+	if err != nil {
+		log.Fatal("Invalid REQUEST_REMINDER_BEFORE:", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	services.NewRequestScheduler(db.DB, schedulerInterval, reminderBefore).Start(ctx)
+
+	// 通知メールを送信待ちから非同期に送信（失敗したメールは間隔を空けて再送）
+	mailOutboxInterval, err := time.ParseDuration(cfg.MailOutboxInterval)
+	if err != nil {
+		log.Fatal("Invalid MAIL_OUTBOX_INTERVAL:", err)
+	}
+	mailSender := mailer.New(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom)
+	services.NewMailOutboxWorker(db.DB, mailSender, cfg.AppBaseURL, mailOutboxInterval).Start(ctx)
+
+	// Webhook を送信待ちから非同期に送信（失敗したものは間隔を空けて再送）
+	webhookInterval, err := time.ParseDuration(cfg.WebhookDispatchInterval)
+	if err != nil {
+		log.Fatal("Invalid WEBHOOK_DISPATCH_INTERVAL:", err)
+	}
+	services.NewWebhookDispatcher(db.DB, webhookInterval).Start(ctx)
+
+	// 毎日決まった時刻に、次の授業日の変更を関係者ごとにまとめて通知
+	digestTime, err := services.ParseDigestTime(cfg.DigestTime)
+	if err != nil {
+		log.Fatal("Invalid DIGEST_TIME:", err)
+	}
+	services.NewDigestScheduler(db.DB, digestTime).Start(ctx)
+
+	authMiddleware := appmiddleware.NewAuthMiddleware(authService, permissionService)
 
 	// ハンドラー初期化
-	timetableHandler := timetable.NewHandler(timetableService, classService)
+	authHandler := auth.NewHandler(authService, oidcService)
+	permissionHandler := permission.NewHandler(permissionService, approvalPolicyService)
+	timetableHandler := timetable.NewHandler(timetableService, conflictService, csvService, overrideService)
+	classHandler := class.NewHandler(classService, classGroupService)
+	departmentHandler := department.NewHandler(departmentService, classService)
+	studentHandler := student.NewHandler(timetableService, enrollmentService)
+	teacherHandler := teacher.NewHandler(unavailabilityService, permissionService, substituteService)
+	requestHandler := request.NewHandler(changeRequestService, permissionService)
+	notificationHandler := notification.NewHandler(notificationService)
+	eventHandler := event.NewHandler(eventBroker, services.NewStreamTicketStore(), roomService)
+	webhookHandler := webhook.NewHandler(webhookService)
+	calendarHandler := calendar.NewHandler(calendarFeedService)
+	roomHandler := room.NewHandler(roomService)
 
 	// Echo初期化
 	e := echo.New()
@@ -52,146 +147,64 @@ func main() {
 	// API グループ
 	api := e.Group("/api")
 
-	// 認証エンドポイント（既存）
-	api.POST("/auth/login", handleLogin)
-	api.GET("/auth/me", handleMe)
+	// 認証エンドポイント（パスワード / OIDC）
+	auth.RegisterRoutes(e, authHandler, authMiddleware)
+
+	// 権限管理エンドポイント
+	permission.RegisterRoutes(api, permissionHandler, authMiddleware)
 
 	// 時間割関連エンドポイント（新規追加）
 	api.GET("/timetables", timetableHandler.GetTimetables)
 	api.GET("/timetables/:id", timetableHandler.GetTimetableByID)
 	api.GET("/timetables/weekly/:class_id", timetableHandler.GetWeeklyTimetable)
+	api.GET("/timetables/weekly/teacher/me", timetableHandler.GetMyWeeklyTimetable, authMiddleware.RequireTeacher)
+	api.GET("/timetables/weekly/teacher/:id", timetableHandler.GetTeacherWeeklyTimetable)
+	api.POST("/timetables/check", timetableHandler.CheckConflicts, authMiddleware.RequireTeacher)
+	api.GET("/timetables/effective", timetableHandler.GetEffectiveTimetable)
+	api.GET("/timetables/overrides", timetableHandler.GetOverrides)
+	api.POST("/timetables/overrides", timetableHandler.CreateOverride, authMiddleware.RequirePermission(models.PermissionTimetableEdit))
+	api.DELETE("/timetables/overrides/:id", timetableHandler.DeleteOverride, authMiddleware.RequirePermission(models.PermissionTimetableEdit))
 	
-	// クラス関連エンドポイント（新規追加）
-	api.GET("/classes", timetableHandler.GetClasses)
-	api.GET("/classes/:id", timetableHandler.GetClassByID)
+	// クラス・学科関連エンドポイント
+	class.RegisterRoutes(api, classHandler, authMiddleware)
+	department.RegisterRoutes(api, departmentHandler, authMiddleware)
 
-	// CSV関連のルート追加（修正版）
-	csvService := services.NewCSVService(db.DB)
-	csvHandler := csv.NewHandler(csvService)
+	// 教室マスタと教室の使用状況
+	room.RegisterRoutes(api, roomHandler, authMiddleware)
 
-	// CSV API エンドポイント（認証なしで一時的に動作確認）
-	csvGroup := api.Group("/csv")
-	csvGroup.POST("/import/subjects", csvHandler.ImportSubjects)
-	csvGroup.POST("/import/timetables", csvHandler.ImportTimetables)
-	csvGroup.GET("/export/timetables", csvHandler.ExportTimetables)
-	csvGroup.GET("/export/subjects", csvHandler.ExportSubjects)
+	// 学生・在籍関連エンドポイント
+	student.RegisterRoutes(api, studentHandler, authMiddleware)
 
-	// サーバー起動
-	log.Println("Server starting on :8080...")
-	e.Logger.Fatal(e.Start(":8080"))
-}
+	// 教員の都合の悪い時間
+	teacher.RegisterRoutes(api, teacherHandler, authMiddleware)
 
-// 既存の認証関数（変更なし）
-func handleLogin(c echo.Context) error {
-	log.Println("=== Login request received ===")
-	
-	body, err := io.ReadAll(c.Request().Body)
-	if err != nil {
-		log.Printf("Error reading request body: %v", err)
-		return c.JSON(http.StatusBadRequest, models.AuthResponse{
-			Success: false,
-			Message: "Failed to read request body",
-		})
-	}
-	
-	log.Printf("Raw request body: %s", string(body))
-	
-	var req models.LoginRequest
-	
-	if err := json.Unmarshal(body, &req); err != nil {
-		log.Printf("JSON unmarshal error: %v", err)
-		return c.JSON(http.StatusBadRequest, models.AuthResponse{
-			Success: false,
-			Message: "Invalid JSON format",
-		})
-	}
+	// 変更申請エンドポイント
+	request.RegisterRoutes(api, requestHandler, authMiddleware)
 
-	log.Printf("Parsed data - Email: '%s', Password: '%s'", req.Email, req.Password)
-
-	if req.Email == "admin@test.com" && req.Password == "password" {
-		log.Printf("✅ Login successful for: %s", req.Email)
-		
-		user := models.User{
-			ID:        1,
-			Name:      "管理者",
-			Email:     req.Email,
-			Role:      "admin",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-		
-		loginResponse := models.LoginResponse{
-			Token:        "mock-jwt-token-12345",
-			RefreshToken: "mock-refresh-token-67890",
-			User:         user,
-		}
-		
-		return c.JSON(http.StatusOK, models.AuthResponse{
-			Success: true,
-			Data:    loginResponse,
-			Message: "ログインに成功しました",
-		})
-	}
+	// 通知エンドポイント
+	notification.RegisterRoutes(api, notificationHandler, authMiddleware)
 
-	validUsers := map[string]map[string]interface{}{
-		"teacher@test.com": {
-			"password": "password",
-			"name":     "教員",
-			"role":     "teacher",
-		},
-		"student@test.com": {
-			"password": "password", 
-			"name":     "学生",
-			"role":     "student",
-		},
-	}
+	// 時間割の変更の配信（Server-Sent Events）
+	event.RegisterRoutes(api, eventHandler, authMiddleware)
 
-	if userData, exists := validUsers[req.Email]; exists {
-		if userData["password"] == req.Password {
-			log.Printf("✅ Login successful for: %s", req.Email)
-			
-			user := models.User{
-				ID:        1,
-				Name:      userData["name"].(string),
-				Email:     req.Email,
-				Role:      userData["role"].(string),
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
-			
-			loginResponse := models.LoginResponse{
-				Token:        "mock-jwt-token-12345",
-				RefreshToken: "mock-refresh-token-67890",
-				User:         user,
-			}
-			
-			return c.JSON(http.StatusOK, models.AuthResponse{
-				Success: true,
-				Data:    loginResponse,
-				Message: "ログインに成功しました",
-			})
-		}
-	}
+	// Webhook の設定と送信記録（管理者のみ）
+	webhook.RegisterRoutes(api, webhookHandler, authMiddleware)
 
-	log.Printf("❌ Login failed for email: '%s'", req.Email)
-	return c.JSON(http.StatusUnauthorized, models.AuthResponse{
-		Success: false,
-		Message: "Invalid email or password",
-	})
-}
+	// 時限の時刻と iCalendar 配信
+	calendar.RegisterRoutes(api, calendarHandler, authMiddleware)
 
-func handleMe(c echo.Context) error {
-	user := models.User{
-		ID:        1,
-		Name:      "管理者",
-		Email:     "admin@test.com",
-		Role:      "admin",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	
-	return c.JSON(http.StatusOK, models.AuthResponse{
-		Success: true,
-		Data:    user,
-	})
+	// CSV関連のルート追加（修正版）
+	csvHandler := csv.NewHandler(csvService)
+
+	// CSV API エンドポイント（教務など CSV 権限を持つユーザーのみ）
+	csvGroup := api.Group("/csv")
+	csvGroup.POST("/import/subjects", csvHandler.ImportSubjects, authMiddleware.RequirePermission(models.PermissionCSVImport))
+	csvGroup.POST("/import/timetables", csvHandler.ImportTimetables, authMiddleware.RequirePermission(models.PermissionCSVImport))
+	csvGroup.POST("/import/enrollments", csvHandler.ImportEnrollments, authMiddleware.RequirePermission(models.PermissionCSVImport))
+	csvGroup.GET("/export/timetables", csvHandler.ExportTimetables, authMiddleware.RequirePermission(models.PermissionCSVExport))
+	csvGroup.GET("/export/subjects", csvHandler.ExportSubjects, authMiddleware.RequirePermission(models.PermissionCSVExport))
+
+	// サーバー起動
+	log.Println("Server starting on :8080...")
+	e.Logger.Fatal(e.Start(":8080"))
 }
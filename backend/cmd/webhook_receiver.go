@@ -0,0 +1,53 @@
+package main
+
+// 開発用の Webhook 受信サーバー（受け取った内容を表示し、署名を検証する）
+//   go run cmd/webhook_receiver.go -addr :9090 -secret <Webhook 作成時に返された secret>
+
+import (
+	"crypto/hmac"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"kosen-schedule-system/internal/services"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "待ち受けるアドレス")
+	secret := flag.String("secret", "", "署名の鍵（空なら検証しない）")
+	status := flag.Int("status", http.StatusOK, "返すステータスコード（再送の確認用に 500 などを指定できる）")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		verified := "未検証"
+		if *secret != "" {
+			expected := "sha256=" + services.SignWebhook(*secret, r.Header.Get("X-Webhook-Timestamp"), body)
+			if hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Webhook-Signature"))) {
+				verified = "OK"
+			} else {
+				verified = "NG"
+			}
+		}
+
+		fmt.Printf("--- %s %s（送信記録 %s、署名 %s）\n%s\n\n",
+			r.Header.Get("X-Webhook-Event"), r.Header.Get("X-Webhook-Timestamp"),
+			r.Header.Get("X-Webhook-Delivery"), verified, body)
+
+		if verified == "NG" {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("webhook receiver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
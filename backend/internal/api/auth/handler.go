@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"time"
 
 	"kosen-schedule-system/internal/models"
 	"kosen-schedule-system/internal/services"
@@ -9,13 +10,18 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// oidcBrowserCookie - OIDCログインを開始したブラウザの鍵を入れるCookie
+const oidcBrowserCookie = "oidc_login"
+
 type Handler struct {
 	authService *services.AuthService
+	oidcService *services.OIDCService
 }
 
-func NewHandler(authService *services.AuthService) *Handler {
+func NewHandler(authService *services.AuthService, oidcService *services.OIDCService) *Handler {
 	return &Handler{
 		authService: authService,
+		oidcService: oidcService,
 	}
 }
 
@@ -46,6 +52,93 @@ func (h *Handler) Login(c echo.Context) error {
 		})
 	}
 
+	return h.respondWithTokens(c, user)
+}
+
+// OIDCログイン開始（認可エンドポイントのURLを返す）
+func (h *Handler) OIDCLogin(c echo.Context) error {
+	if h.oidcService == nil || !h.oidcService.Enabled() {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "OIDC login is not configured",
+		})
+	}
+
+	authURL, state, browserKey, err := h.oidcService.AuthorizationURL()
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	setOIDCBrowserCookie(c, browserKey, int(services.OIDCLoginTTL/time.Second))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": models.OIDCAuthorizationResponse{
+			AuthorizationURL: authURL,
+			State:            state,
+		},
+	})
+}
+
+// OIDCコールバック（認可コードを交換して独自のJWTを発行）
+func (h *Handler) OIDCCallback(c echo.Context) error {
+	if h.oidcService == nil || !h.oidcService.Enabled() {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "OIDC login is not configured",
+		})
+	}
+
+	var req models.OIDCCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "Invalid request format",
+		})
+	}
+
+	if req.Code == "" || req.State == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "Code and state are required",
+		})
+	}
+
+	// ログインを開始したブラウザのCookieと照合する（鍵は一度しか使えないため、結果にかかわらず削除）
+	var browserKey string
+	if cookie, err := c.Cookie(oidcBrowserCookie); err == nil {
+		browserKey = cookie.Value
+	}
+	setOIDCBrowserCookie(c, "", -1)
+
+	user, err := h.oidcService.Exchange(req.Code, req.State, browserKey)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return h.respondWithTokens(c, user)
+}
+
+// OIDCログインを開始したブラウザの鍵をCookieに設定（maxAge が負なら削除）
+func setOIDCBrowserCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     oidcBrowserCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// アクセストークンとリフレッシュトークンを発行してログインレスポンスを返す
+func (h *Handler) respondWithTokens(c echo.Context, user *models.User) error {
 	// JWTトークン生成
 	token, err := h.authService.GenerateToken(user)
 	if err != nil {
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
 
 	"github.com/labstack/echo/v4"
 )
@@ -12,12 +13,14 @@ func RegisterRoutes(e *echo.Echo, handler *Handler, authMiddleware *middleware.A
 	// 認証不要のエンドポイント
 	auth.POST("/login", handler.Login)
 	auth.POST("/refresh", handler.RefreshToken)
+	auth.GET("/oidc/login", handler.OIDCLogin)
+	auth.POST("/oidc/callback", handler.OIDCCallback)
 	
 	// 認証必要のエンドポイント
 	auth.GET("/me", handler.GetMe, authMiddleware.RequireAuth)
 	auth.POST("/logout", handler.Logout, authMiddleware.RequireAuth)
 	auth.POST("/change-password", handler.ChangePassword, authMiddleware.RequireAuth)
 	
-	// 管理者のみ
-	auth.POST("/users", handler.CreateUser, authMiddleware.RequireAdmin)
+	// ユーザー管理権限が必要
+	auth.POST("/users", handler.CreateUser, authMiddleware.RequirePermission(models.PermissionUserManage))
 }
\ No newline at end of file
@@ -0,0 +1,182 @@
+package calendar
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	calendarFeedService *services.CalendarFeedService
+}
+
+func NewHandler(calendarFeedService *services.CalendarFeedService) *Handler {
+	return &Handler{calendarFeedService: calendarFeedService}
+}
+
+// 時限の開始・終了時刻
+func (h *Handler) GetBellSchedule(c echo.Context) error {
+	periods, err := h.calendarFeedService.GetBellSchedule()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "時限の時刻の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    periods,
+		"message": "時限の時刻を取得しました",
+	})
+}
+
+// 時限の開始・終了時刻の変更
+func (h *Handler) UpdateBellSchedule(c echo.Context) error {
+	var req models.UpdateBellScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	periods, err := h.calendarFeedService.UpdateBellSchedule(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    periods,
+		"message": "時限の時刻を更新しました",
+	})
+}
+
+// 自分が作成した配信の一覧
+func (h *Handler) GetFeeds(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	feeds, err := h.calendarFeedService.GetFeeds(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "配信の一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+	for i := range feeds {
+		feeds[i].URL = feedURL(c, feeds[i].Token)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    feeds,
+		"message": "配信の一覧を取得しました",
+	})
+}
+
+// 配信の作成（カレンダーアプリに登録する URL を返す）
+func (h *Handler) CreateFeed(c echo.Context) error {
+	var req models.CreateCalendarFeedRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+	feed, err := h.calendarFeedService.CreateFeed(userID, &req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	feed.URL = feedURL(c, feed.Token)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    feed,
+		"message": "配信を作成しました",
+	})
+}
+
+// 配信の削除（URL は使えなくなる）
+func (h *Handler) DeleteFeed(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+	if err := h.calendarFeedService.DeleteFeed(userID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "配信が見つかりません",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "配信の削除に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "配信を削除しました",
+	})
+}
+
+// iCalendar の取得（カレンダーアプリから定期的に取得される。トークンで認証する）
+func (h *Handler) GetICS(c echo.Context) error {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	feed, err := h.calendarFeedService.GetFeedByToken(token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.String(http.StatusNotFound, "calendar feed not found")
+		}
+		return c.String(http.StatusInternalServerError, "failed to load calendar feed")
+	}
+
+	body, err := h.calendarFeedService.GenerateICS(feed, time.Now())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "failed to generate calendar")
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", body)
+}
+
+// feedURL - 配信の URL（このリクエストを受けたホストの URL）
+func feedURL(c echo.Context, token string) string {
+	return c.Scheme() + "://" + c.Request().Host + "/api/calendar/ics/" + token + ".ics"
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	calendar := g.Group("/calendar")
+	calendar.GET("/bell-schedule", h.GetBellSchedule)
+	calendar.PUT("/bell-schedule", h.UpdateBellSchedule, authMiddleware.RequirePermission(models.PermissionMasterEdit))
+
+	calendar.GET("/feeds", h.GetFeeds, authMiddleware.RequireAuth)
+	calendar.POST("/feeds", h.CreateFeed, authMiddleware.RequireAuth)
+	calendar.DELETE("/feeds/:id", h.DeleteFeed, authMiddleware.RequireAuth)
+	calendar.GET("/ics/:token", h.GetICS)
+}
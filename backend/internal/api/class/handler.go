@@ -3,28 +3,38 @@ package class
 import (
 	"net/http"
 	"strconv"
+	"time"
 
-	"timetable-change-system/internal/models"
-	"timetable-change-system/internal/services"
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
 
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	classService *services.ClassService
+	classService      *services.ClassService
+	classGroupService *services.ClassGroupService
 }
 
-func NewHandler(classService *services.ClassService) *Handler {
+func NewHandler(classService *services.ClassService, classGroupService *services.ClassGroupService) *Handler {
 	return &Handler{
-		classService: classService,
+		classService:      classService,
+		classGroupService: classGroupService,
 	}
 }
 
 // クラス一覧取得
 func (h *Handler) GetClasses(c echo.Context) error {
-	grade, _ := strconv.Atoi(c.QueryParam("grade"))
+	var filter models.ClassFilter
+	if grade, err := strconv.Atoi(c.QueryParam("grade")); err == nil {
+		filter.Grade = &grade
+	}
+	if departmentID, err := strconv.Atoi(c.QueryParam("department_id")); err == nil {
+		filter.DepartmentID = &departmentID
+	}
 
-	classes, err := h.classService.GetClasses(grade)
+	classes, err := h.classService.GetClasses(filter)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -148,4 +158,203 @@ func (h *Handler) DeleteClass(c echo.Context) error {
 		"success": true,
 		"message": "クラスを削除しました",
 	})
-}
\ No newline at end of file
+}
+
+// クラスのグループ一覧
+func (h *Handler) GetClassGroups(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	groups, err := h.classGroupService.GetClassGroups(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "グループ一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    groups,
+		"message": "グループ一覧を取得しました",
+	})
+}
+
+// グループ作成
+func (h *Handler) CreateClassGroup(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.CreateClassGroupRequest
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	group, err := h.classGroupService.CreateClassGroup(id, &req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "グループの作成に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    group,
+		"message": "グループを作成しました",
+	})
+}
+
+// グループ削除
+func (h *Handler) DeleteClassGroup(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	groupID, err := strconv.Atoi(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.classGroupService.DeleteClassGroup(id, groupID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "グループが見つかりません",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "グループを削除しました",
+	})
+}
+
+// グループ所属学生一覧
+func (h *Handler) GetGroupMembers(c echo.Context) error {
+	groupID, err := strconv.Atoi(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	members, err := h.classGroupService.GetGroupMembers(groupID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "グループ所属学生の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    members,
+		"message": "グループ所属学生を取得しました",
+	})
+}
+
+// グループに学生を追加
+func (h *Handler) AddGroupMember(c echo.Context) error {
+	groupID, err := strconv.Atoi(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.AddClassGroupMemberRequest
+	if err := c.Bind(&req); err != nil || req.StudentID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	if err := h.classGroupService.AddGroupMember(groupID, req.StudentID, models.AcademicYearOf(time.Now())); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "学生の追加に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "学生をグループに追加しました",
+	})
+}
+
+// グループから学生を外す
+func (h *Handler) RemoveGroupMember(c echo.Context) error {
+	groupID, err := strconv.Atoi(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	studentID, err := strconv.Atoi(c.Param("student_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.classGroupService.RemoveGroupMember(groupID, studentID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "学生の削除に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "学生をグループから外しました",
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	requireMasterEdit := authMiddleware.RequirePermission(models.PermissionMasterEdit)
+
+	classes := g.Group("/classes")
+	classes.GET("", h.GetClasses)
+	classes.GET("/:id", h.GetClass)
+	classes.POST("", h.CreateClass, requireMasterEdit)
+	classes.PUT("/:id", h.UpdateClass, requireMasterEdit)
+	classes.DELETE("/:id", h.DeleteClass, requireMasterEdit)
+
+	classes.GET("/:id/groups", h.GetClassGroups)
+	classes.POST("/:id/groups", h.CreateClassGroup, requireMasterEdit)
+	classes.DELETE("/:id/groups/:group_id", h.DeleteClassGroup, requireMasterEdit)
+	classes.GET("/:id/groups/:group_id/members", h.GetGroupMembers, authMiddleware.RequireTeacher)
+	classes.POST("/:id/groups/:group_id/members", h.AddGroupMember, requireMasterEdit)
+	classes.DELETE("/:id/groups/:group_id/members/:student_id", h.RemoveGroupMember, requireMasterEdit)
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"kosen-schedule-system/internal/models"
 	"kosen-schedule-system/internal/services"
 
 	"github.com/labstack/echo/v4"
@@ -127,6 +128,72 @@ func (h *Handler) ImportTimetables(c echo.Context) error {
 	})
 }
 
+// 学生名簿CSVインポート
+func (h *Handler) ImportEnrollments(c echo.Context) error {
+	// 年度（省略時は今年度）
+	academicYear := models.AcademicYearOf(time.Now())
+	if yearStr := c.QueryParam("academic_year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": "年度が不正です",
+			})
+		}
+		academicYear = year
+	}
+
+	// ファイル取得
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "ファイルが選択されていません",
+		})
+	}
+
+	// ファイルサイズチェック
+	if file.Size > 10*1024*1024 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "ファイルサイズが大きすぎます（10MB以下にしてください）",
+		})
+	}
+
+	// ファイル拡張子チェック
+	if !isCSVFile(file.Filename) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "CSVファイルを選択してください",
+		})
+	}
+
+	// ファイルを開く
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "ファイルの読み込みに失敗しました",
+		})
+	}
+	defer src.Close()
+
+	// CSVインポート実行
+	result, err := h.csvService.ImportEnrollments(src, academicYear)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("インポートに失敗しました: %v", err),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "学生名簿のインポートが完了しました",
+		"data":    result,
+	})
+}
+
 // 時間割CSVエクスポート
 func (h *Handler) ExportTimetables(c echo.Context) error {
 	// フィルターパラメータ取得
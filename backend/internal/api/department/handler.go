@@ -0,0 +1,376 @@
+package department
+
+import (
+	"net/http"
+	"strconv"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	departmentService *services.DepartmentService
+	classService      *services.ClassService
+}
+
+func NewHandler(departmentService *services.DepartmentService, classService *services.ClassService) *Handler {
+	return &Handler{
+		departmentService: departmentService,
+		classService:      classService,
+	}
+}
+
+// 学科一覧取得
+func (h *Handler) GetDepartments(c echo.Context) error {
+	departments, err := h.departmentService.GetDepartments()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "学科一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    departments,
+		"message": "学科一覧を取得しました",
+	})
+}
+
+// 学科取得
+func (h *Handler) GetDepartment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	department, err := h.departmentService.GetDepartmentByID(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "学科が見つかりません",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    department,
+		"message": "学科を取得しました",
+	})
+}
+
+// 学科作成
+func (h *Handler) CreateDepartment(c echo.Context) error {
+	var req models.CreateDepartmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	department, err := h.departmentService.CreateDepartment(&req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "学科の作成に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    department,
+		"message": "学科を作成しました",
+	})
+}
+
+// 学科更新
+func (h *Handler) UpdateDepartment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.UpdateDepartmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	department, err := h.departmentService.UpdateDepartment(id, &req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "学科の更新に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    department,
+		"message": "学科を更新しました",
+	})
+}
+
+// 学科削除
+func (h *Handler) DeleteDepartment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.departmentService.DeleteDepartment(id); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "学科の削除に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "学科を削除しました",
+	})
+}
+
+// 学科所属クラス一覧
+func (h *Handler) GetDepartmentClasses(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	classes, err := h.classService.GetClasses(models.ClassFilter{DepartmentID: &id})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "クラス一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    classes,
+		"message": "クラス一覧を取得しました",
+	})
+}
+
+// 学科所属教員一覧
+func (h *Handler) GetDepartmentTeachers(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	teachers, err := h.departmentService.GetDepartmentTeachers(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "教員一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    teachers,
+		"message": "教員一覧を取得しました",
+	})
+}
+
+// 学科開講科目一覧
+func (h *Handler) GetDepartmentSubjects(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	subjects, err := h.departmentService.GetDepartmentSubjects(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "科目一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    subjects,
+		"message": "科目一覧を取得しました",
+	})
+}
+
+// 学科に教員を追加
+func (h *Handler) AddTeacher(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.AddDepartmentTeacherRequest
+	if err := c.Bind(&req); err != nil || req.UserID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	if err := h.departmentService.AddTeacher(id, req.UserID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "教員の追加に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "教員を追加しました",
+	})
+}
+
+// 学科から教員を外す
+func (h *Handler) RemoveTeacher(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.departmentService.RemoveTeacher(id, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "教員の削除に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "教員を学科から外しました",
+	})
+}
+
+// 学科に科目を追加
+func (h *Handler) AddSubject(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.AddDepartmentSubjectRequest
+	if err := c.Bind(&req); err != nil || req.SubjectID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	if err := h.departmentService.AddSubject(id, req.SubjectID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "科目の追加に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "科目を追加しました",
+	})
+}
+
+// 学科から科目を外す
+func (h *Handler) RemoveSubject(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	subjectID, err := strconv.Atoi(c.Param("subject_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.departmentService.RemoveSubject(id, subjectID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "科目の削除に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "科目を学科から外しました",
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	requireMasterEdit := authMiddleware.RequirePermission(models.PermissionMasterEdit)
+
+	departments := g.Group("/departments")
+	departments.GET("", h.GetDepartments)
+	departments.GET("/:id", h.GetDepartment)
+	departments.GET("/:id/classes", h.GetDepartmentClasses)
+	departments.GET("/:id/teachers", h.GetDepartmentTeachers)
+	departments.GET("/:id/subjects", h.GetDepartmentSubjects)
+
+	departments.POST("", h.CreateDepartment, requireMasterEdit)
+	departments.PUT("/:id", h.UpdateDepartment, requireMasterEdit)
+	departments.DELETE("/:id", h.DeleteDepartment, requireMasterEdit)
+	departments.POST("/:id/teachers", h.AddTeacher, requireMasterEdit)
+	departments.DELETE("/:id/teachers/:user_id", h.RemoveTeacher, requireMasterEdit)
+	departments.POST("/:id/subjects", h.AddSubject, requireMasterEdit)
+	departments.DELETE("/:id/subjects/:subject_id", h.RemoveSubject, requireMasterEdit)
+}
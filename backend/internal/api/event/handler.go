@@ -0,0 +1,153 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 接続を保つためにコメント行を送る間隔（プロキシのタイムアウトより短くする）
+const keepAliveInterval = 30 * time.Second
+
+type Handler struct {
+	broker      *services.EventBroker
+	tickets     *services.StreamTicketStore
+	roomService *services.RoomService
+}
+
+func NewHandler(broker *services.EventBroker, tickets *services.StreamTicketStore, roomService *services.RoomService) *Handler {
+	return &Handler{broker: broker, tickets: tickets, roomService: roomService}
+}
+
+// 配信用チケットの発行（EventSource の URL の ?ticket= に指定する。一度だけ・30秒以内に使える）
+func (h *Handler) IssueStreamTicket(c echo.Context) error {
+	ticket, expiresAt, err := h.tickets.Issue(
+		c.Get("user_id").(int),
+		c.Get("user_email").(string),
+		c.Get("user_role").(string),
+		time.Now(),
+	)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "チケットの発行に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"ticket":     ticket,
+			"expires_at": expiresAt,
+		},
+		"message": "チケットを発行しました",
+	})
+}
+
+// 時間割の変更を Server-Sent Events で受け取る
+// 自分の授業・クラスに影響する変更に加え、class_id・teacher_id・room（カンマ区切り）に該当する変更も受け取る
+func (h *Handler) StreamTimetableEvents(c echo.Context) error {
+	classIDs, err := parseIDs(c.QueryParam("class_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+	teacherIDs, err := parseIDs(c.QueryParam("teacher_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+	// 変更の内容には教室名が入るため、別表記で指定された教室も教室名にして比べる
+	var rooms []string
+	for _, room := range strings.Split(c.QueryParam("room"), ",") {
+		if room = strings.TrimSpace(room); room == "" {
+			continue
+		}
+		name, err := h.roomService.ResolveRoomName(room)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+		rooms = append(rooms, name)
+	}
+
+	sub := h.broker.Subscribe(&services.Subscription{
+		UserID:     c.Get("user_id").(int),
+		ClassIDs:   classIDs,
+		TeacherIDs: teacherIDs,
+		Rooms:      rooms,
+	})
+	defer h.broker.Unsubscribe(sub)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	// 切断された場合は5秒後に再接続させる
+	fmt.Fprint(res, "retry: 5000\n: connected\n\n")
+	res.Flush()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ticker.C:
+			fmt.Fprint(res, ": ping\n\n")
+			res.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data)
+			res.Flush()
+		}
+	}
+}
+
+// parseIDs - カンマ区切りの ID
+func parseIDs(s string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	events := g.Group("/events")
+	events.POST("/tickets", h.IssueStreamTicket, authMiddleware.RequireAuth)
+	events.GET("/timetable", h.StreamTimetableEvents, authMiddleware.RequireAuthOrStreamTicket(h.tickets))
+}
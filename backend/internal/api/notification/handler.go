@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	notificationService *services.NotificationService
+}
+
+func NewHandler(notificationService *services.NotificationService) *Handler {
+	return &Handler{notificationService: notificationService}
+}
+
+// 自分の通知一覧取得（?unread=true で未読のみ）
+func (h *Handler) GetNotifications(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	unreadOnly := c.QueryParam("unread") == "true"
+
+	userID := c.Get("user_id").(int)
+	notifications, total, err := h.notificationService.GetNotifications(userID, unreadOnly, limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "通知一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+	unreadCount, err := h.notificationService.GetUnreadCount(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "未読件数の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"notifications": notifications,
+			"total":         total,
+			"unread_count":  unreadCount,
+			"limit":         limit,
+			"offset":        offset,
+		},
+		"message": "通知一覧を取得しました",
+	})
+}
+
+// 未読件数取得
+func (h *Handler) GetUnreadCount(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	count, err := h.notificationService.GetUnreadCount(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "未読件数の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"unread_count": count,
+		},
+		"message": "未読件数を取得しました",
+	})
+}
+
+// 通知を既読にする
+func (h *Handler) MarkRead(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+	if err := h.notificationService.MarkRead(userID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "通知が見つかりません",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "通知の更新に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "通知を既読にしました",
+	})
+}
+
+// 通知をすべて既読にする
+func (h *Handler) MarkAllRead(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	count, err := h.notificationService.MarkAllRead(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "通知の更新に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"updated": count,
+		},
+		"message": "通知をすべて既読にしました",
+	})
+}
+
+// メール受信設定取得
+func (h *Handler) GetPreferences(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+	preferences, err := h.notificationService.GetPreferences(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "通知設定の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    preferences,
+		"message": "通知設定を取得しました",
+	})
+}
+
+// メール受信設定の変更
+func (h *Handler) UpdatePreferences(c echo.Context) error {
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+	preferences, err := h.notificationService.UpdatePreferences(userID, &req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    preferences,
+		"message": "通知設定を更新しました",
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	notifications := g.Group("/notifications", authMiddleware.RequireAuth)
+	notifications.GET("", h.GetNotifications)
+	notifications.GET("/unread-count", h.GetUnreadCount)
+	notifications.PUT("/read-all", h.MarkAllRead)
+	notifications.GET("/preferences", h.GetPreferences)
+	notifications.PUT("/preferences", h.UpdatePreferences)
+	notifications.PUT("/:id/read", h.MarkRead)
+}
@@ -0,0 +1,303 @@
+package permission
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	permissionService     *services.PermissionService
+	approvalPolicyService *services.ApprovalPolicyService
+}
+
+func NewHandler(permissionService *services.PermissionService, approvalPolicyService *services.ApprovalPolicyService) *Handler {
+	return &Handler{
+		permissionService:     permissionService,
+		approvalPolicyService: approvalPolicyService,
+	}
+}
+
+// 権限一覧取得（定義済み権限とロールごとの割り当て）
+func (h *Handler) GetPermissions(c echo.Context) error {
+	roles := make(map[string][]string)
+	for role := range models.DefaultRolePermissions {
+		permissions, err := h.permissionService.GetRolePermissions(role)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"success": false,
+				"message": "権限一覧の取得に失敗しました",
+				"error":   err.Error(),
+			})
+		}
+		roles[role] = permissions
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"permissions": models.AllPermissions,
+			"roles":       roles,
+		},
+		"message": "権限一覧を取得しました",
+	})
+}
+
+// ロールの権限更新
+func (h *Handler) UpdateRolePermissions(c echo.Context) error {
+	role := c.Param("role")
+
+	var req models.UpdateRolePermissionsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	if err := h.permissionService.SetRolePermissions(role, req.Permissions); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "ロールの権限更新に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"role":        role,
+			"permissions": req.Permissions,
+		},
+		"message": "ロールの権限を更新しました",
+	})
+}
+
+// ユーザーの追加ロールと有効な権限を取得
+func (h *Handler) GetUserRoles(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	assignments, err := h.permissionService.GetRoleAssignments(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "ロールの取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	grants, err := h.permissionService.GetUserGrants(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "権限の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"assignments": assignments,
+			"grants":      grants,
+		},
+		"message": "ロールを取得しました",
+	})
+}
+
+// ユーザーに追加ロールを割り当て
+func (h *Handler) AssignRole(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.CreateRoleAssignmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	assignment, err := h.permissionService.AssignRole(userID, &req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "ロールの割り当てに失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    assignment,
+		"message": "ロールを割り当てました",
+	})
+}
+
+// 追加ロールの割り当て解除
+func (h *Handler) RevokeRole(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	assignmentID, err := strconv.Atoi(c.Param("assignment_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.permissionService.RevokeRoleAssignment(userID, assignmentID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "ロールの割り当てが見つかりません",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "ロールの割り当てを解除しました",
+	})
+}
+
+// 承認ポリシー一覧取得
+func (h *Handler) GetApprovalPolicies(c echo.Context) error {
+	policies, err := h.approvalPolicyService.GetPolicies()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "承認ポリシーの取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    policies,
+		"message": "承認ポリシーを取得しました",
+	})
+}
+
+// 承認ポリシー作成
+func (h *Handler) CreateApprovalPolicy(c echo.Context) error {
+	var req models.CreateApprovalPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	policy, err := h.approvalPolicyService.CreatePolicy(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "承認ポリシーの作成に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    policy,
+		"message": "承認ポリシーを作成しました",
+	})
+}
+
+// 承認ポリシー更新
+func (h *Handler) UpdateApprovalPolicy(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.CreateApprovalPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	policy, err := h.approvalPolicyService.UpdatePolicy(id, &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == sql.ErrNoRows {
+			status = http.StatusNotFound
+		}
+		return c.JSON(status, map[string]interface{}{
+			"success": false,
+			"message": "承認ポリシーの更新に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    policy,
+		"message": "承認ポリシーを更新しました",
+	})
+}
+
+// 承認ポリシー削除
+func (h *Handler) DeleteApprovalPolicy(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.approvalPolicyService.DeletePolicy(id); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "承認ポリシーが見つかりません",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "承認ポリシーを削除しました",
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	requireUserManage := authMiddleware.RequirePermission(models.PermissionUserManage)
+
+	g.GET("/permissions", h.GetPermissions, requireUserManage)
+	g.PUT("/roles/:role/permissions", h.UpdateRolePermissions, requireUserManage)
+	g.GET("/users/:id/roles", h.GetUserRoles, requireUserManage)
+	g.POST("/users/:id/roles", h.AssignRole, requireUserManage)
+	g.DELETE("/users/:id/roles/:assignment_id", h.RevokeRole, requireUserManage)
+
+	g.GET("/approval-policies", h.GetApprovalPolicies, requireUserManage)
+	g.POST("/approval-policies", h.CreateApprovalPolicy, requireUserManage)
+	g.PUT("/approval-policies/:id", h.UpdateApprovalPolicy, requireUserManage)
+	g.DELETE("/approval-policies/:id", h.DeleteApprovalPolicy, requireUserManage)
+}
@@ -1,22 +1,27 @@
 package request
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
-	"timetable-change-system/internal/models"
-	"timetable-change-system/internal/services"
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
 
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
 	changeRequestService *services.ChangeRequestService
+	permissionService    *services.PermissionService
 }
 
-func NewHandler(changeRequestService *services.ChangeRequestService) *Handler {
+func NewHandler(changeRequestService *services.ChangeRequestService, permissionService *services.PermissionService) *Handler {
 	return &Handler{
 		changeRequestService: changeRequestService,
+		permissionService:    permissionService,
 	}
 }
 
@@ -34,7 +39,9 @@ func (h *Handler) GetChangeRequests(c echo.Context) error {
 	requesterID, _ := strconv.Atoi(c.QueryParam("requester_id"))
 	status := c.QueryParam("status")
 
-	requests, total, err := h.changeRequestService.GetChangeRequests(requesterID, status, limit, offset)
+	// 承認権限がなければ自分の申請のみ、範囲を限定した承認権限なら範囲内の申請のみ
+	userID := c.Get("user_id").(int)
+	requests, total, err := h.changeRequestService.GetChangeRequests(userID, requesterID, status, limit, offset)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
@@ -73,6 +80,10 @@ func (h *Handler) GetChangeRequest(c echo.Context) error {
 		})
 	}
 
+	if ok, err := h.canView(c.Get("user_id").(int), request); !ok {
+		return errorResponse(c, err, "申請の取得に失敗しました")
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
 		"data":    request,
@@ -95,11 +106,7 @@ func (h *Handler) CreateChangeRequest(c echo.Context) error {
 
 	request, err := h.changeRequestService.CreateChangeRequest(userID, &req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "申請の作成に失敗しました",
-			"error":   err.Error(),
-		})
+		return errorResponse(c, err, "申請の作成に失敗しました")
 	}
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
@@ -119,6 +126,8 @@ func (h *Handler) UpdateChangeRequest(c echo.Context) error {
 		})
 	}
 
+	userID := c.Get("user_id").(int)
+
 	var req models.UpdateChangeRequestRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
@@ -127,13 +136,9 @@ func (h *Handler) UpdateChangeRequest(c echo.Context) error {
 		})
 	}
 
-	request, err := h.changeRequestService.UpdateChangeRequest(id, &req)
+	request, err := h.changeRequestService.UpdateChangeRequest(id, userID, &req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "申請の更新に失敗しました",
-			"error":   err.Error(),
-		})
+		return errorResponse(c, err, "申請の更新に失敗しました")
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -156,9 +161,7 @@ func (h *Handler) ApproveChangeRequest(c echo.Context) error {
 	// JWTからユーザーIDを取得
 	approverID := c.Get("user_id").(int)
 
-	var req struct {
-		Comment string `json:"comment"`
-	}
+	var req models.ApproveRequestRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"success": false,
@@ -166,19 +169,48 @@ func (h *Handler) ApproveChangeRequest(c echo.Context) error {
 		})
 	}
 
-	request, err := h.changeRequestService.ApproveChangeRequest(id, approverID, req.Comment)
+	request, err := h.changeRequestService.ApproveChangeRequest(id, approverID, req.Comment, req.Version)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		return errorResponse(c, err, "申請の承認に失敗しました")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    request,
+		"message": "申請を承認しました",
+	})
+}
+
+// 反映済みの申請の取り消し
+func (h *Handler) RevertChangeRequest(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"message": "申請の承認に失敗しました",
-			"error":   err.Error(),
+			"message": "無効なIDです",
 		})
 	}
 
+	// JWTからユーザーIDを取得
+	userID := c.Get("user_id").(int)
+
+	var req models.RevertRequestRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	request, err := h.changeRequestService.RevertChangeRequest(id, userID, req.Comment, req.Version)
+	if err != nil {
+		return errorResponse(c, err, "申請の取り消しに失敗しました")
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
 		"data":    request,
-		"message": "申請を承認しました",
+		"message": "申請の反映を取り消しました",
 	})
 }
 
@@ -195,9 +227,39 @@ func (h *Handler) RejectChangeRequest(c echo.Context) error {
 	// JWTからユーザーIDを取得
 	approverID := c.Get("user_id").(int)
 
-	var req struct {
-		Comment string `json:"comment"`
+	var req models.RejectRequestRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	request, err := h.changeRequestService.RejectChangeRequest(id, approverID, req.Comment, req.Version)
+	if err != nil {
+		return errorResponse(c, err, "申請の却下に失敗しました")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    request,
+		"message": "申請を却下しました",
+	})
+}
+
+// 下書きの申請を提出
+func (h *Handler) SubmitChangeRequest(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
 	}
+
+	userID := c.Get("user_id").(int)
+
+	var req models.ChangeRequestActionRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"success": false,
@@ -205,19 +267,47 @@ func (h *Handler) RejectChangeRequest(c echo.Context) error {
 		})
 	}
 
-	request, err := h.changeRequestService.RejectChangeRequest(id, approverID, req.Comment)
+	request, err := h.changeRequestService.SubmitChangeRequest(id, userID, req.Version)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		return errorResponse(c, err, "申請の提出に失敗しました")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    request,
+		"message": "申請を提出しました",
+	})
+}
+
+// 申請の取り下げ（申請者本人、承認前のみ）
+func (h *Handler) CancelChangeRequest(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"message": "申請の却下に失敗しました",
-			"error":   err.Error(),
+			"message": "無効なIDです",
 		})
 	}
 
+	userID := c.Get("user_id").(int)
+
+	var req models.ChangeRequestActionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	request, err := h.changeRequestService.CancelChangeRequest(id, userID, req.Version)
+	if err != nil {
+		return errorResponse(c, err, "申請の取り下げに失敗しました")
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
 		"data":    request,
-		"message": "申請を却下しました",
+		"message": "申請を取り下げました",
 	})
 }
 
@@ -232,16 +322,248 @@ func (h *Handler) DeleteChangeRequest(c echo.Context) error {
 	}
 
 	err = h.changeRequestService.DeleteChangeRequest(id)
+	if err != nil {
+		return errorResponse(c, err, "申請の削除に失敗しました")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "申請を削除しました",
+	})
+}
+
+// 自分が同意を求められている申請一覧（入れ替え申請など）
+func (h *Handler) GetPendingConsents(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	consents, err := h.changeRequestService.GetPendingConsents(userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
-			"message": "申請の削除に失敗しました",
+			"message": "同意待ちの申請の取得に失敗しました",
 			"error":   err.Error(),
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "申請を削除しました",
+		"data":    consents,
+		"message": "同意待ちの申請を取得しました",
+	})
+}
+
+// 申請への同意・不同意
+func (h *Handler) RespondConsent(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+
+	var req models.RespondConsentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	if err := h.changeRequestService.RespondConsent(id, userID, &req); err != nil {
+		return errorResponse(c, err, "申請への回答に失敗しました")
+	}
+
+	request, err := h.changeRequestService.GetChangeRequestByID(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "申請が見つかりません",
+		})
+	}
+
+	message := "申請に同意しました"
+	if !req.Accept {
+		message = "申請に同意しませんでした"
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    request,
+		"message": message,
+	})
+}
+
+// 申請の履歴（コメント、状態の変更、申請内容の修正）
+func (h *Handler) GetRequestHistory(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	request, err := h.changeRequestService.GetChangeRequestByID(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "申請が見つかりません",
+		})
+	}
+	if ok, err := h.canView(c.Get("user_id").(int), request); !ok {
+		return errorResponse(c, err, "履歴の取得に失敗しました")
+	}
+
+	events, err := h.changeRequestService.GetRequestHistory(id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "履歴の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    events,
+		"message": "履歴を取得しました",
 	})
 }
+
+// 申請へのコメント追加（申請を閲覧できるユーザー）
+func (h *Handler) AddComment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+
+	var req models.CreateCommentRequest
+	if err := c.Bind(&req); err != nil || req.Comment == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	request, err := h.changeRequestService.GetChangeRequestByID(id)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "申請が見つかりません",
+		})
+	}
+	if ok, err := h.canView(userID, request); !ok {
+		return errorResponse(c, err, "コメントの追加に失敗しました")
+	}
+
+	event, err := h.changeRequestService.AddComment(id, userID, req.Comment)
+	if err != nil {
+		return errorResponse(c, err, "コメントの追加に失敗しました")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    event,
+		"message": "コメントを追加しました",
+	})
+}
+
+// canView - 申請者本人、同意を求められた教員、対象クラスの範囲で承認権限を持つユーザーのみ申請を閲覧できる
+func (h *Handler) canView(userID int, request *models.ChangeRequest) (bool, error) {
+	if request.RequesterID == userID {
+		return true, nil
+	}
+	for _, consent := range request.Consents {
+		if consent.TeacherID == userID {
+			return true, nil
+		}
+	}
+
+	scope, err := h.changeRequestService.RequestScope(request)
+	if err != nil {
+		return false, err
+	}
+	ok, err := h.permissionService.HasPermission(userID, models.PermissionRequestApprove, scope)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, services.ErrPermissionDenied
+	}
+	return true, nil
+}
+
+// errorResponse - サービスのエラーを HTTP ステータスに対応付けて返す（競合の場合は競合内容を data に含める）
+func errorResponse(c echo.Context, err error, message string) error {
+	var conflictErr *services.ConflictError
+	var batchErr *services.BatchConflictError
+	status := http.StatusBadRequest
+	switch {
+	case errors.As(err, &batchErr):
+		// まとめた申請は変更ごとの結果を返す
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"data":    batchErr.Items,
+			"message": message,
+			"error":   err.Error(),
+		})
+	case errors.As(err, &conflictErr):
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"data":    conflictErr.Conflicts,
+			"message": message,
+			"error":   err.Error(),
+		})
+	case errors.Is(err, services.ErrPermissionDenied),
+		errors.Is(err, services.ErrNotRequester),
+		errors.Is(err, services.ErrConsentNotRequested),
+		errors.Is(err, services.ErrAlreadyApprovedStep):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrVersionConflict),
+		errors.Is(err, services.ErrRequestNotEditable),
+		errors.Is(err, services.ErrRequestNotDeletable),
+		errors.Is(err, services.ErrRequestNotAwaitingConsent),
+		errors.Is(err, services.ErrSlotChanged),
+		errors.Is(err, services.ErrLessonAlreadyChanged),
+		errors.Is(err, services.ErrNoApplications):
+		status = http.StatusConflict
+	case errors.Is(err, sql.ErrNoRows):
+		status = http.StatusNotFound
+		message = "申請が見つかりません"
+	}
+
+	return c.JSON(status, map[string]interface{}{
+		"success": false,
+		"message": message,
+		"error":   err.Error(),
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	requireApprove := authMiddleware.RequirePermission(models.PermissionRequestApprove)
+
+	requests := g.Group("/requests")
+	requests.GET("", h.GetChangeRequests, authMiddleware.RequireTeacher)
+	requests.GET("/consents", h.GetPendingConsents, authMiddleware.RequireTeacher)
+	requests.GET("/:id", h.GetChangeRequest, authMiddleware.RequireTeacher)
+	requests.GET("/:id/history", h.GetRequestHistory, authMiddleware.RequireTeacher)
+	requests.POST("/:id/comments", h.AddComment, authMiddleware.RequireTeacher)
+	requests.POST("", h.CreateChangeRequest, authMiddleware.RequirePermission(models.PermissionRequestCreate))
+	requests.PUT("/:id", h.UpdateChangeRequest, authMiddleware.RequireTeacher)
+	requests.PUT("/:id/submit", h.SubmitChangeRequest, authMiddleware.RequireTeacher)
+	requests.PUT("/:id/cancel", h.CancelChangeRequest, authMiddleware.RequireTeacher)
+	requests.PUT("/:id/consent", h.RespondConsent, authMiddleware.RequireTeacher)
+	requests.PUT("/:id/approve", h.ApproveChangeRequest, requireApprove)
+	requests.PUT("/:id/reject", h.RejectChangeRequest, requireApprove)
+	requests.POST("/:id/revert", h.RevertChangeRequest, requireApprove)
+	requests.DELETE("/:id", h.DeleteChangeRequest, authMiddleware.RequireAdmin)
+}
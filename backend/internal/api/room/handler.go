@@ -0,0 +1,253 @@
+package room
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	roomService *services.RoomService
+}
+
+func NewHandler(roomService *services.RoomService) *Handler {
+	return &Handler{roomService: roomService}
+}
+
+// 教室一覧取得
+func (h *Handler) GetRooms(c echo.Context) error {
+	var filter models.RoomFilter
+	if roomType := c.QueryParam("room_type"); roomType != "" {
+		filter.RoomType = &roomType
+	}
+	if building := c.QueryParam("building"); building != "" {
+		filter.Building = &building
+	}
+
+	rooms, err := h.roomService.GetRooms(filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "教室一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    rooms,
+		"message": "教室一覧を取得しました",
+	})
+}
+
+// 教室詳細取得
+func (h *Handler) GetRoom(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	room, err := h.roomService.GetRoomByID(id)
+	if err != nil {
+		return roomError(c, err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    room,
+		"message": "教室を取得しました",
+	})
+}
+
+// 教室作成
+func (h *Handler) CreateRoom(c echo.Context) error {
+	var req models.CreateRoomRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	room, err := h.roomService.CreateRoom(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    room,
+		"message": "教室を登録しました",
+	})
+}
+
+// 教室更新（名前を変えると時間割などの授業場所も変わる）
+func (h *Handler) UpdateRoom(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.UpdateRoomRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	room, err := h.roomService.UpdateRoom(id, &req)
+	if err != nil {
+		return roomError(c, err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    room,
+		"message": "教室を更新しました",
+	})
+}
+
+// 教室削除
+func (h *Handler) DeleteRoom(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.roomService.DeleteRoom(id); err != nil {
+		return roomError(c, err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "教室を削除しました",
+	})
+}
+
+// 表記の揺れで別々に登録された教室をまとめる
+func (h *Handler) MergeRoom(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.MergeRoomRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	room, err := h.roomService.MergeRoom(id, &req)
+	if err != nil {
+		return roomError(c, err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    room,
+		"message": "教室をまとめました",
+	})
+}
+
+// 同じ表記の教室をまとめる（マイグレーションで登録した既存の授業場所の表記の揺れなど）
+func (h *Handler) MergeDuplicateRooms(c echo.Context) error {
+	merged, err := h.roomService.MergeDuplicateRooms()
+	if err != nil {
+		return roomError(c, err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"merged": merged,
+		},
+		"message": "同じ表記の教室をまとめました",
+	})
+}
+
+// 教室の週間の使用状況（date を含む週、省略時は今週）
+func (h *Handler) GetRoomOccupancy(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	date := time.Now()
+	if dateStr := c.QueryParam("date"); dateStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": "日付の形式が無効です（YYYY-MM-DD）",
+			})
+		}
+		date = t
+	}
+
+	occupancy, err := h.roomService.GetRoomOccupancy(id, date)
+	if err != nil {
+		return roomError(c, err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    occupancy,
+		"message": "教室の使用状況を取得しました",
+	})
+}
+
+// roomError - 教室が見つからなければ 404、それ以外は status でエラーを返す
+func roomError(c echo.Context, err error, status int) error {
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "教室が見つかりません",
+		})
+	}
+	return c.JSON(status, map[string]interface{}{
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	requireMasterEdit := authMiddleware.RequirePermission(models.PermissionMasterEdit)
+
+	rooms := g.Group("/rooms")
+	rooms.GET("", h.GetRooms)
+	rooms.GET("/:id", h.GetRoom)
+	rooms.GET("/:id/occupancy", h.GetRoomOccupancy)
+	rooms.POST("", h.CreateRoom, requireMasterEdit)
+	rooms.POST("/merge-duplicates", h.MergeDuplicateRooms, requireMasterEdit)
+	rooms.PUT("/:id", h.UpdateRoom, requireMasterEdit)
+	rooms.DELETE("/:id", h.DeleteRoom, requireMasterEdit)
+	rooms.POST("/:id/merge", h.MergeRoom, requireMasterEdit)
+}
@@ -1,27 +1,145 @@
 package student
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
 
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	db *sql.DB
+	timetableService  *services.TimetableService
+	enrollmentService *services.EnrollmentService
 }
 
-func NewHandler(db *sql.DB) *Handler {
-	return &Handler{db: db}
+func NewHandler(timetableService *services.TimetableService, enrollmentService *services.EnrollmentService) *Handler {
+	return &Handler{
+		timetableService:  timetableService,
+		enrollmentService: enrollmentService,
+	}
 }
 
+// ログイン中の学生の今週の時間割取得（承認済みの変更を反映）
 func (h *Handler) GetStudentTimetable(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	timetable, err := h.timetableService.GetStudentTimetable(userID, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrNotEnrolled) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "所属クラスが登録されていません",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "時間割の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    timetable,
+		"message": "時間割を取得しました",
+	})
+}
+
+// クラスの在籍学生一覧
+func (h *Handler) GetClassEnrollments(c echo.Context) error {
+	classID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	academicYear := models.AcademicYearOf(time.Now())
+	if yearStr := c.QueryParam("academic_year"); yearStr != "" {
+		if year, err := strconv.Atoi(yearStr); err == nil {
+			academicYear = year
+		}
+	}
+
+	enrollments, err := h.enrollmentService.GetClassEnrollments(classID, academicYear)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "在籍学生の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    enrollments,
+		"message": "在籍学生を取得しました",
+	})
+}
+
+// 学生の在籍登録
+func (h *Handler) CreateEnrollment(c echo.Context) error {
+	var req models.CreateEnrollmentRequest
+	if err := c.Bind(&req); err != nil || req.StudentID == 0 || req.ClassID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	enrollment, err := h.enrollmentService.EnrollStudent(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "在籍登録に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    enrollment,
+		"message": "在籍登録しました",
+	})
+}
+
+// 在籍登録削除
+func (h *Handler) DeleteEnrollment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.enrollmentService.DeleteEnrollment(id); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "在籍登録が見つかりません",
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "Student timetable endpoint - to be implemented",
+		"success": true,
+		"message": "在籍登録を削除しました",
 	})
 }
 
-func RegisterRoutes(g *echo.Group, h *Handler) {
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	requireMasterEdit := authMiddleware.RequirePermission(models.PermissionMasterEdit)
+
 	student := g.Group("/student")
-	student.GET("/timetable", h.GetStudentTimetable)
-}
\ No newline at end of file
+	student.GET("/timetable", h.GetStudentTimetable, authMiddleware.RequireStudent)
+
+	g.GET("/classes/:id/enrollments", h.GetClassEnrollments, authMiddleware.RequireTeacher)
+	g.POST("/enrollments", h.CreateEnrollment, requireMasterEdit)
+	g.DELETE("/enrollments/:id", h.DeleteEnrollment, requireMasterEdit)
+}
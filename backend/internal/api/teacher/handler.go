@@ -0,0 +1,196 @@
+package teacher
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	unavailabilityService *services.TeacherUnavailabilityService
+	permissionService     *services.PermissionService
+	substituteService     *services.SubstituteService
+}
+
+func NewHandler(unavailabilityService *services.TeacherUnavailabilityService, permissionService *services.PermissionService, substituteService *services.SubstituteService) *Handler {
+	return &Handler{
+		unavailabilityService: unavailabilityService,
+		permissionService:     permissionService,
+		substituteService:     substituteService,
+	}
+}
+
+// 教員の都合の悪い時間一覧
+func (h *Handler) GetUnavailability(c echo.Context) error {
+	teacherID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	items, err := h.unavailabilityService.GetTeacherUnavailability(teacherID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "都合の悪い時間の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    items,
+		"message": "都合の悪い時間を取得しました",
+	})
+}
+
+// 教員の都合の悪い時間を登録（本人または時間割編集権限を持つユーザー）
+func (h *Handler) AddUnavailability(c echo.Context) error {
+	teacherID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if ok, err := h.canEdit(c, teacherID); !ok {
+		return forbidden(c, err)
+	}
+
+	var req models.CreateTeacherUnavailabilityRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	item, err := h.unavailabilityService.AddTeacherUnavailability(teacherID, &req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "都合の悪い時間の登録に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    item,
+		"message": "都合の悪い時間を登録しました",
+	})
+}
+
+// 教員の都合の悪い時間を削除（本人または時間割編集権限を持つユーザー）
+func (h *Handler) DeleteUnavailability(c echo.Context) error {
+	teacherID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Param("unavailability_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if ok, err := h.canEdit(c, teacherID); !ok {
+		return forbidden(c, err)
+	}
+
+	if err := h.unavailabilityService.DeleteTeacherUnavailability(teacherID, id); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "都合の悪い時間が見つかりません",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "都合の悪い時間を削除しました",
+	})
+}
+
+// 不在期間に代講が必要な授業と、各コマで代講できる教員の候補（本人または時間割編集権限を持つユーザー）
+func (h *Handler) GetSubstituteSlots(c echo.Context) error {
+	teacherID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if ok, err := h.canEdit(c, teacherID); !ok {
+		return forbidden(c, err)
+	}
+
+	from, errFrom := time.ParseInLocation("2006-01-02", c.QueryParam("from"), time.Local)
+	to, errTo := time.ParseInLocation("2006-01-02", c.QueryParam("to"), time.Local)
+	if errFrom != nil || errTo != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "期間（from・to）を YYYY-MM-DD で指定してください",
+		})
+	}
+
+	slots, err := h.substituteService.GetSubstituteSlots(teacherID, from, to)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "代講候補の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    slots,
+		"message": "代講候補を取得しました",
+	})
+}
+
+// canEdit - 本人、または時間割編集権限を持つか
+func (h *Handler) canEdit(c echo.Context, teacherID int) (bool, error) {
+	userID := c.Get("user_id").(int)
+	if userID == teacherID {
+		return true, nil
+	}
+	return h.permissionService.HasPermission(userID, models.PermissionTimetableEdit, nil)
+}
+
+func forbidden(c echo.Context, err error) error {
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "権限の確認に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+	return c.JSON(http.StatusForbidden, map[string]interface{}{
+		"success": false,
+		"message": "この操作を行う権限がありません",
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	teachers := g.Group("/teachers")
+	teachers.GET("/:id/unavailability", h.GetUnavailability, authMiddleware.RequireTeacher)
+	teachers.POST("/:id/unavailability", h.AddUnavailability, authMiddleware.RequireTeacher)
+	teachers.DELETE("/:id/unavailability/:unavailability_id", h.DeleteUnavailability, authMiddleware.RequireTeacher)
+	teachers.GET("/:id/substitutes", h.GetSubstituteSlots, authMiddleware.RequireTeacher)
+}
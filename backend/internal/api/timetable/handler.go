@@ -1,8 +1,10 @@
 package timetable
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"kosen-schedule-system/internal/models"
 	"kosen-schedule-system/internal/services"
@@ -12,13 +14,17 @@ import (
 
 type Handler struct {
 	timetableService *services.TimetableService
-	classService     *services.ClassService
+	conflictService  *services.ConflictService
+	csvService       *services.CSVService
+	overrideService  *services.TimetableOverrideService
 }
 
-func NewHandler(timetableService *services.TimetableService, classService *services.ClassService) *Handler {
+func NewHandler(timetableService *services.TimetableService, conflictService *services.ConflictService, csvService *services.CSVService, overrideService *services.TimetableOverrideService) *Handler {
 	return &Handler{
 		timetableService: timetableService,
-		classService:     classService,
+		conflictService:  conflictService,
+		csvService:       csvService,
+		overrideService:  overrideService,
 	}
 }
 
@@ -39,6 +45,12 @@ func (h *Handler) GetTimetables(c echo.Context) error {
 		}
 	}
 
+	if groupIDStr := c.QueryParam("group_id"); groupIDStr != "" {
+		if groupID, err := strconv.Atoi(groupIDStr); err == nil {
+			filter.GroupID = &groupID
+		}
+	}
+
 	if className := c.QueryParam("class_name"); className != "" {
 		filter.ClassName = &className
 	}
@@ -53,6 +65,12 @@ func (h *Handler) GetTimetables(c echo.Context) error {
 		}
 	}
 
+	if departmentIDStr := c.QueryParam("department_id"); departmentIDStr != "" {
+		if departmentID, err := strconv.Atoi(departmentIDStr); err == nil {
+			filter.DepartmentID = &departmentID
+		}
+	}
+
 	timetables, err := h.timetableService.GetTimetables(filter)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
@@ -92,19 +110,55 @@ func (h *Handler) GetWeeklyTimetable(c echo.Context) error {
 	})
 }
 
-// クラス一覧取得
-func (h *Handler) GetClasses(c echo.Context) error {
-	classes, err := h.classService.GetClasses()
+// 教員の週間時間割取得（date を含む週、省略時は今週）
+func (h *Handler) GetTeacherWeeklyTimetable(c echo.Context) error {
+	teacherID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+	return h.teacherWeeklyTimetable(c, teacherID)
+}
+
+// ログイン中の教員の週間時間割取得
+func (h *Handler) GetMyWeeklyTimetable(c echo.Context) error {
+	return h.teacherWeeklyTimetable(c, c.Get("user_id").(int))
+}
+
+func (h *Handler) teacherWeeklyTimetable(c echo.Context, teacherID int) error {
+	date := time.Now()
+	if dateStr := c.QueryParam("date"); dateStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": "日付の形式が無効です（YYYY-MM-DD）",
+			})
+		}
+		date = t
+	}
+
+	timetable, err := h.timetableService.GetTeacherTimetable(teacherID, date)
+	if err != nil {
+		if errors.Is(err, services.ErrTeacherNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "教員が見つかりません",
+			})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
+			"message": "時間割の取得に失敗しました",
 			"error":   err.Error(),
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"data":    classes,
+		"data":    timetable,
+		"message": "時間割を取得しました",
 	})
 }
 
@@ -145,27 +199,215 @@ func (h *Handler) GetTimetableByID(c echo.Context) error {
 	})
 }
 
-// クラス詳細取得
-func (h *Handler) GetClassByID(c echo.Context) error {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
+// 時間割の競合チェック（変更内容の JSON、または時間割CSVファイルを受け付ける）
+func (h *Handler) CheckConflicts(c echo.Context) error {
+	var result *models.ConflictCheckResult
+
+	if file, err := c.FormFile("file"); err == nil {
+		src, err := file.Open()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"success": false,
+				"message": "ファイルの読み込みに失敗しました",
+			})
+		}
+		defer src.Close()
+
+		result, err = h.csvService.CheckTimetablesCSV(src)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": "競合チェックに失敗しました",
+				"error":   err.Error(),
+			})
+		}
+	} else {
+		var req models.TimetableChangeData
+		if err := c.Bind(&req); err != nil || req.OriginalTimetableID == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": "リクエストデータが無効です",
+			})
+		}
+
+		result, err = h.conflictService.CheckChange(&req, c.Get("user_id").(int))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"message": "競合チェックに失敗しました",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	message := "競合はありません"
+	if result.HasConflicts {
+		message = "時間割の競合があります"
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    result,
+		"message": message,
+	})
+}
+
+// parseDateRange - クエリパラメータ from / to（YYYY-MM-DD）を取得（省略時は今日から1週間）
+func parseDateRange(c echo.Context) (time.Time, time.Time, error) {
+	from := time.Now()
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+
+	to := from.AddDate(0, 0, 6)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+
+	return from, to, nil
+}
+
+// 実際の時間割取得（期間内の各日の授業に日付指定の変更を反映）
+func (h *Handler) GetEffectiveTimetable(c echo.Context) error {
+	from, to, err := parseDateRange(c)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"error":   "Invalid class ID",
+			"message": "日付の形式が無効です（YYYY-MM-DD）",
 		})
 	}
 
-	class, err := h.classService.GetClassByID(id)
+	filter := models.EffectiveTimetableFilter{From: from, To: to}
+
+	if classIDStr := c.QueryParam("class_id"); classIDStr != "" {
+		if classID, err := strconv.Atoi(classIDStr); err == nil {
+			filter.ClassID = &classID
+		}
+	}
+
+	if teacherIDStr := c.QueryParam("teacher_id"); teacherIDStr != "" {
+		if teacherID, err := strconv.Atoi(teacherIDStr); err == nil {
+			filter.TeacherID = &teacherID
+		}
+	}
+
+	if room := c.QueryParam("room"); room != "" {
+		filter.Room = &room
+	}
+
+	lessons, err := h.timetableService.GetEffectiveTimetable(filter)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]interface{}{
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
 			"success": false,
+			"message": "時間割の取得に失敗しました",
 			"error":   err.Error(),
 		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"data":    class,
+		"data":    lessons,
+		"message": "時間割を取得しました",
+	})
+}
+
+// 日付指定の変更一覧
+func (h *Handler) GetOverrides(c echo.Context) error {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "日付の形式が無効です（YYYY-MM-DD）",
+		})
+	}
+
+	overrides, err := h.overrideService.GetOverrides(from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "日付指定の変更の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    overrides,
+		"message": "日付指定の変更を取得しました",
+	})
+}
+
+// 日付指定の変更登録（休講・移動・代講・教室変更）
+func (h *Handler) CreateOverride(c echo.Context) error {
+	userID := c.Get("user_id").(int)
+
+	var req models.CreateTimetableOverrideRequest
+	if err := c.Bind(&req); err != nil || req.TimetableID == 0 || req.Type == "" || req.Date == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	override, err := h.overrideService.CreateOverride(&req, userID)
+	if err != nil {
+		var conflictErr *services.ConflictError
+		if errors.As(err, &conflictErr) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"success": false,
+				"data":    conflictErr.Conflicts,
+				"message": "時間割の競合があります",
+				"error":   err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrLessonAlreadyChanged) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"success": false,
+				"message": "日付指定の変更の登録に失敗しました",
+				"error":   err.Error(),
+			})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "日付指定の変更の登録に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    override,
+		"message": "日付指定の変更を登録しました",
+	})
+}
+
+// 日付指定の変更の取り消し
+func (h *Handler) DeleteOverride(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.overrideService.DeleteOverride(id); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]interface{}{
+			"success": false,
+			"message": "日付指定の変更が見つかりません",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "日付指定の変更を取り消しました",
 	})
 }
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"kosen-schedule-system/internal/middleware"
+	"kosen-schedule-system/internal/models"
+	"kosen-schedule-system/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	webhookService *services.WebhookService
+}
+
+func NewHandler(webhookService *services.WebhookService) *Handler {
+	return &Handler{webhookService: webhookService}
+}
+
+// Webhook 一覧取得
+func (h *Handler) GetWebhooks(c echo.Context) error {
+	webhooks, err := h.webhookService.GetWebhooks()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "Webhook 一覧の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    webhooks,
+		"message": "Webhook 一覧を取得しました",
+	})
+}
+
+// Webhook 作成（署名の鍵はこのレスポンスでのみ返す）
+func (h *Handler) CreateWebhook(c echo.Context) error {
+	var req models.CreateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	userID := c.Get("user_id").(int)
+	webhook, err := h.webhookService.CreateWebhook(userID, &req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    webhook,
+		"message": "Webhook を作成しました",
+	})
+}
+
+// Webhook 更新
+func (h *Handler) UpdateWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "リクエストデータが無効です",
+		})
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(id, &req)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "Webhook が見つかりません",
+			})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    webhook,
+		"message": "Webhook を更新しました",
+	})
+}
+
+// Webhook 削除
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	if err := h.webhookService.DeleteWebhook(id); err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "Webhook が見つかりません",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "Webhook の削除に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Webhook を削除しました",
+	})
+}
+
+// テスト送信（ping）
+func (h *Handler) TestWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	delivery, err := h.webhookService.TestWebhook(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "Webhook が見つかりません",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "テスト送信の登録に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"success": true,
+		"data":    delivery,
+		"message": "テスト送信を登録しました",
+	})
+}
+
+// 送信記録取得（?status=dead で再送をやめた記録のみ）
+func (h *Handler) GetDeliveries(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, total, err := h.webhookService.GetDeliveries(id, c.QueryParam("status"), limit, offset)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"message": "送信記録の取得に失敗しました",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"deliveries": deliveries,
+			"total":      total,
+			"limit":      limit,
+			"offset":     offset,
+		},
+		"message": "送信記録を取得しました",
+	})
+}
+
+// 再送をやめた送信の再送
+func (h *Handler) RetryDelivery(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"message": "無効なIDです",
+		})
+	}
+
+	delivery, err := h.webhookService.RetryDelivery(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"message": "送信記録が見つかりません",
+			})
+		}
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"success": true,
+		"data":    delivery,
+		"message": "再送を登録しました",
+	})
+}
+
+func RegisterRoutes(g *echo.Group, h *Handler, authMiddleware *middleware.AuthMiddleware) {
+	webhooks := g.Group("/webhooks", authMiddleware.RequireAdmin)
+	webhooks.GET("", h.GetWebhooks)
+	webhooks.POST("", h.CreateWebhook)
+	webhooks.PUT("/:id", h.UpdateWebhook)
+	webhooks.DELETE("/:id", h.DeleteWebhook)
+	webhooks.POST("/:id/test", h.TestWebhook)
+	webhooks.GET("/:id/deliveries", h.GetDeliveries)
+	webhooks.POST("/deliveries/:delivery_id/retry", h.RetryDelivery)
+}
@@ -13,6 +13,31 @@ type Config struct {
 	SMTPUser       string
 	SMTPPassword   string
 	Environment    string
+
+	// OpenID Connect（学校のMicrosoft/Googleテナント）
+	OIDCIssuer         string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCRedirectURL    string
+	OIDCAllowedDomains string // カンマ区切り（例: "st.example.ac.jp,example.ac.jp"）
+	OIDCDomainRoles    string // ドメイン:ロール（例: "st.example.ac.jp:student,example.ac.jp:teacher"）
+	OIDCGroupRoles     string // グループ:ロール（例: "kyomu-admins:admin,teachers:teacher"）
+	OIDCDefaultRole    string
+
+	// 申請の期限切れ・リマインドを確認する間隔と、判断期限の何時間前にリマインドするか（例: "10m", "24h"）
+	RequestSchedulerInterval string
+	RequestReminderBefore    string
+
+	// 通知メールの差出人、送信待ちのメールを確認する間隔、メールに載せる画面の URL
+	SMTPFrom           string
+	MailOutboxInterval string
+	AppBaseURL         string
+
+	// 送信待ちの Webhook を確認する間隔
+	WebhookDispatchInterval string
+
+	// 次の授業日の変更のまとめを通知する時刻（例: "18:00"）
+	DigestTime string
 }
 
 func Load() *Config {
@@ -25,6 +50,26 @@ func Load() *Config {
 		SMTPUser:       getEnv("SMTP_USER", ""),
 		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
 		Environment:    getEnv("ENVIRONMENT", "development"),
+
+		OIDCIssuer:         getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:       getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:   getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:    getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/callback"),
+		OIDCAllowedDomains: getEnv("OIDC_ALLOWED_DOMAINS", ""),
+		OIDCDomainRoles:    getEnv("OIDC_DOMAIN_ROLES", ""),
+		OIDCGroupRoles:     getEnv("OIDC_GROUP_ROLES", ""),
+		OIDCDefaultRole:    getEnv("OIDC_DEFAULT_ROLE", "student"),
+
+		RequestSchedulerInterval: getEnv("REQUEST_SCHEDULER_INTERVAL", "10m"),
+		RequestReminderBefore:    getEnv("REQUEST_REMINDER_BEFORE", "24h"),
+
+		SMTPFrom:           getEnv("SMTP_FROM", "noreply@localhost"),
+		MailOutboxInterval: getEnv("MAIL_OUTBOX_INTERVAL", "1m"),
+		AppBaseURL:         getEnv("APP_BASE_URL", "http://localhost:3000"),
+
+		WebhookDispatchInterval: getEnv("WEBHOOK_DISPATCH_INTERVAL", "30s"),
+
+		DigestTime: getEnv("DIGEST_TIME", "18:00"),
 	}
 }
 
@@ -33,4 +78,4 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message - 送信するメール（テキストと HTML の両方を multipart/alternative で送る）
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer - SMTP サーバー経由でメールを送信する
+// User が空の場合は認証しない（開発環境の Mailpit などのローカル SMTP で確認できる）
+type Mailer struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+func New(host, port, user, password, from string) *Mailer {
+	return &Mailer{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Send - メールを送信する（サーバーが対応していれば STARTTLS で暗号化する）
+func (m *Mailer) Send(msg *Message) error {
+	body, err := m.build(msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.User != "" {
+		auth = smtp.PlainAuth("", m.User, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, m.From, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("メールの送信に失敗しました: %v", err)
+	}
+	return nil
+}
+
+// build - ヘッダーを UTF-8 でエンコードし、本文を base64 にしたメールを組み立てる
+func (m *Mailer) build(msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	header := []string{
+		"From: " + m.From,
+		"To: " + msg.To,
+		"Subject: " + mime.BEncoding.Encode("UTF-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + w.Boundary(),
+	}
+	var out bytes.Buffer
+	out.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	}
+	for _, p := range parts {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(wrapBase64(p.body))); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// wrapBase64 - base64 にして 76 文字ごとに改行する
+func wrapBase64(s string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.String()
+}
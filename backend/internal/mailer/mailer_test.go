@@ -0,0 +1,208 @@
+package mailer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	m := New("localhost", "1025", "", "", "noreply@example.ac.jp")
+	msg := &Message{
+		To:      "teacher@example.ac.jp",
+		Subject: "【時間割変更】3-2 2限「数学」が休講になりました",
+		Text:    "山田 先生\n\n" + strings.Repeat("時間割が変更されました。", 20),
+		HTML:    "<p>山田 先生</p>",
+	}
+
+	body, err := m.build(msg)
+	if err != nil {
+		t.Fatalf("build() error = %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+
+	if from := parsed.Header.Get("From"); from != m.From {
+		t.Errorf("From = %q, want %q", from, m.From)
+	}
+	if to := parsed.Header.Get("To"); to != msg.To {
+		t.Errorf("To = %q, want %q", to, msg.To)
+	}
+	rawSubject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?UTF-8?b?") {
+		t.Errorf("Subject should be B-encoded: %q", rawSubject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil {
+		t.Fatalf("DecodeHeader() error = %v", err)
+	}
+	if subject != msg.Subject {
+		t.Errorf("Subject = %q, want %q", subject, msg.Subject)
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Date header is invalid: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("ParseMediaType() error = %v", err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", mediaType)
+	}
+
+	want := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for i, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != w.contentType {
+			t.Errorf("part %d Content-Type = %q, want %q", i, ct, w.contentType)
+		}
+		if cte := part.Header.Get("Content-Transfer-Encoding"); cte != "base64" {
+			t.Errorf("part %d Content-Transfer-Encoding = %q, want base64", i, cte)
+		}
+		raw, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\r\n", ""))
+		if err != nil {
+			t.Fatalf("part %d is not base64: %v", i, err)
+		}
+		if string(decoded) != w.body {
+			t.Errorf("part %d body = %q, want %q", i, decoded, w.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected exactly two parts, got err = %v", err)
+	}
+}
+
+func TestWrapBase64(t *testing.T) {
+	for _, n := range []int{0, 1, 56, 57, 58, 200} {
+		s := strings.Repeat("あ", n)
+		wrapped := wrapBase64(s)
+
+		if !strings.HasSuffix(wrapped, "\r\n") {
+			t.Errorf("n=%d: should end with CRLF", n)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(wrapped, "\r\n"), "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("n=%d: line is %d characters", n, len(line))
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(wrapped, "\r\n", ""))
+		if err != nil {
+			t.Fatalf("n=%d: %v", n, err)
+		}
+		if string(decoded) != s {
+			t.Errorf("n=%d: decoded = %q", n, decoded)
+		}
+	}
+}
+
+// TestSend - ローカルの SMTP サーバー（受け取った内容を記録するだけ）に送る
+func TestSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type received struct {
+		from, to, data string
+	}
+	done := make(chan received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		var got received
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL":
+				got.from = line
+				reply("250 OK")
+			case "RCPT":
+				got.to = line
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				got.data = data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				done <- got
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	m := New(host, port, "", "", "noreply@example.ac.jp")
+	err = m.Send(&Message{To: "student@example.ac.jp", Subject: "休講のお知らせ", Text: "本文", HTML: "<p>本文</p>"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got := <-done
+	if got.from != "MAIL FROM:<noreply@example.ac.jp>" {
+		t.Errorf("MAIL = %q", got.from)
+	}
+	if got.to != "RCPT TO:<student@example.ac.jp>" {
+		t.Errorf("RCPT = %q", got.to)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "休講のお知らせ" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+}
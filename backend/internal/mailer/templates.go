@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+)
+
+// NotificationData - 通知メールに埋め込む内容
+type NotificationData struct {
+	Name    string // 宛先のユーザー名
+	Title   string
+	Message string
+	URL     string // 詳細を確認する画面（空なら載せない）
+}
+
+// RenderNotification - 通知をメールにする（件名には通知のタイトルを使う）
+func RenderNotification(to string, data *NotificationData) (*Message, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, "notification.txt.tmpl", data); err != nil {
+		return nil, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, "notification.html.tmpl", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: "【時間割変更システム】" + data.Title,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"kosen-schedule-system/internal/services"
 
@@ -10,12 +11,14 @@ import (
 )
 
 type AuthMiddleware struct {
-	authService *services.AuthService
+	authService       *services.AuthService
+	permissionService *services.PermissionService
 }
 
-func NewAuthMiddleware(authService *services.AuthService) *AuthMiddleware {
+func NewAuthMiddleware(authService *services.AuthService, permissionService *services.PermissionService) *AuthMiddleware {
 	return &AuthMiddleware{
-		authService: authService,
+		authService:       authService,
+		permissionService: permissionService,
 	}
 }
 
@@ -55,6 +58,34 @@ func (m *AuthMiddleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// JWT認証ミドルウェア（Authorization ヘッダーを付けられない EventSource などのため、?ticket= の配信用チケットも受け付ける）
+// アクセストークンは URL に載せるとログに残るため受け付けない
+func (m *AuthMiddleware) RequireAuthOrStreamTicket(tickets *services.StreamTicketStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		requireAuth := m.RequireAuth(next)
+		return func(c echo.Context) error {
+			token := c.QueryParam("ticket")
+			if c.Request().Header.Get("Authorization") != "" || token == "" {
+				return requireAuth(c)
+			}
+
+			ticket, ok := tickets.Redeem(token, time.Now())
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"success": false,
+					"message": "Invalid or expired ticket",
+				})
+			}
+
+			c.Set("user_id", ticket.UserID)
+			c.Set("user_email", ticket.Email)
+			c.Set("user_role", ticket.Role)
+
+			return next(c)
+		}
+	}
+}
+
 // 管理者権限チェック
 func (m *AuthMiddleware) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
 	return m.RequireAuth(func(c echo.Context) error {
@@ -97,6 +128,30 @@ func (m *AuthMiddleware) RequireStudent(next echo.HandlerFunc) echo.HandlerFunc
 	})
 }
 
+// 権限チェック（いずれかの範囲で権限を持っていれば通過し、範囲の確認はサービス側で行う）
+// 範囲を限定できない権限は、全体に対する割り当てで持つ場合のみ通過する
+func (m *AuthMiddleware) RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return m.RequireAuth(func(c echo.Context) error {
+			userID := c.Get("user_id").(int)
+			ok, err := m.permissionService.HasPermission(userID, permission, nil)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+					"success": false,
+					"message": "Failed to check permission",
+				})
+			}
+			if !ok {
+				return c.JSON(http.StatusForbidden, map[string]interface{}{
+					"success": false,
+					"message": "Permission required: " + permission,
+				})
+			}
+			return next(c)
+		})
+	}
+}
+
 // 権限チェックミドルウェア追加
 
 func RequireRole(requiredRole string) echo.MiddlewareFunc {
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// 承認ポリシーで使う申請の分類（申請データから判定する）
+const (
+	RequestKindMove       = "move"        // 授業の移動・変更
+	RequestKindSwap       = "swap"        // 2コマの入れ替え
+	RequestKindSubstitute = "substitute"  // 代講
+	RequestKindRoomChange = "room_change" // 教室のみの変更
+	RequestKindCancel     = "cancel"      // 休講
+)
+
+// 承認段階の状態
+const (
+	ApprovalStepPending  = "pending"
+	ApprovalStepApproved = "approved"
+	ApprovalStepRejected = "rejected"
+)
+
+// ApprovalPolicy - 申請の分類・対象クラスの範囲ごとに、承認が必要なロールの順番を定める
+// RequestKind・Grade・DepartmentID が空の場合は絞り込まない。複数該当する場合は Priority の大きいものを使う
+type ApprovalPolicy struct {
+	ID           int       `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	RequestKind  string    `json:"request_kind" db:"request_kind"`
+	Grade        *int      `json:"grade" db:"grade"`
+	DepartmentID *int      `json:"department_id" db:"department_id"`
+	Priority     int       `json:"priority" db:"priority"`
+	Steps        []string  `json:"steps"` // 承認するロール（順番どおり）
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateApprovalPolicyRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	RequestKind  string   `json:"request_kind"`
+	Grade        *int     `json:"grade"`
+	DepartmentID *int     `json:"department_id"`
+	Priority     int      `json:"priority"`
+	Steps        []string `json:"steps" validate:"required"`
+}
+
+// ApprovalStep - 申請ごとの承認段階（提出時にポリシーから作成する）
+type ApprovalStep struct {
+	ID              int        `json:"id" db:"id"`
+	ChangeRequestID int        `json:"change_request_id" db:"change_request_id"`
+	StepOrder       int        `json:"step_order" db:"step_order"`
+	Role            string     `json:"role" db:"role"`
+	Status          string     `json:"status" db:"status"`
+	ApproverID      *int       `json:"approver_id" db:"approver_id"`
+	ApproverName    string     `json:"approver_name" db:"approver_name"`
+	Comment         string     `json:"comment" db:"comment"`
+	DecidedAt       *time.Time `json:"decided_at" db:"decided_at"`
+}
@@ -18,3 +18,15 @@ type AuthResponse struct {
 }
 
 // User型とChangePasswordRequest型は削除（user.goで定義済み）
+
+// OIDCAuthorizationResponse - OIDCログイン開始レスポンス
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+// OIDCCallbackRequest - OIDCコールバック（フロントエンドから受け渡し）
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// RecurrenceRule - 繰り返しの変更（From〜To の期間で、対象の授業がある日ごとに同じ変更を行う）
+type RecurrenceRule struct {
+	From          string   `json:"from"`           // 開始日（YYYY-MM-DD）
+	To            string   `json:"to"`             // 終了日（YYYY-MM-DD）
+	IntervalWeeks int      `json:"interval_weeks"` // 何週ごとか（省略時は毎週）
+	Except        []string `json:"except"`         // 除外する日付（YYYY-MM-DD）
+}
+
+// BatchItemResult - まとめた申請の変更1件ごとの確認・反映結果
+type BatchItemResult struct {
+	Index        int                 `json:"index"`        // 展開後の変更の番号（0 始まり）
+	SourceIndex  int                 `json:"source_index"` // items での番号（繰り返しの変更は同じ番号になる）
+	Item         TimetableChangeData `json:"item"`
+	HasConflicts bool                `json:"has_conflicts"`
+	Conflicts    []Conflict          `json:"conflicts,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// iCalendar 配信の対象（calendar_feeds.scope）
+const (
+	CalendarScopeClass   = "class"
+	CalendarScopeTeacher = "teacher"
+	CalendarScopeRoom    = "room"
+)
+
+// BellPeriod - 時限の開始・終了時刻（"08:50" の形式）
+type BellPeriod struct {
+	Period    int    `json:"period" db:"period"`
+	StartTime string `json:"start_time" db:"start_time"`
+	EndTime   string `json:"end_time" db:"end_time"`
+}
+
+type UpdateBellScheduleRequest struct {
+	Periods []BellPeriod `json:"periods" validate:"required"`
+}
+
+// CalendarFeed - クラス・教員・教室の時間割の iCalendar 配信
+// Token は配信の URL に含める秘密の値で、本人の一覧でのみ返す
+type CalendarFeed struct {
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"user_id" db:"user_id"`
+	Scope          string     `json:"scope" db:"scope"`
+	ClassID        *int       `json:"class_id,omitempty" db:"class_id"`
+	TeacherID      *int       `json:"teacher_id,omitempty" db:"teacher_id"`
+	Room           string     `json:"room,omitempty" db:"room"`
+	Name           string     `json:"name" db:"name"`
+	Token          string     `json:"token" db:"token"`
+	URL            string     `json:"url,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at" db:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateCalendarFeedRequest struct {
+	Scope     string `json:"scope" validate:"required"`
+	ClassID   *int   `json:"class_id"`
+	TeacherID *int   `json:"teacher_id"`
+	Room      string `json:"room"`
+}
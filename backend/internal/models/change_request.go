@@ -14,32 +14,62 @@ type ChangeRequest struct {
 	RequestData json.RawMessage `json:"request_data" db:"request_data"`
 	ApproverID  *int            `json:"approver_id" db:"approver_id"`
 	ApprovedAt  *time.Time      `json:"approved_at" db:"approved_at"`
+	Comment     string          `json:"comment" db:"approver_comment"` // 承認・却下時のコメント
+	Version     int             `json:"version" db:"version"`           // 更新のたびに増える（楽観的ロック）
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+
+	// 実施日と判断期限（判断期限が近づくとリマインドし、過ぎると管理者に通知、実施日を過ぎると期限切れになる）
+	EffectiveDate    string     `json:"effective_date,omitempty" db:"effective_date"` // YYYY-MM-DD
+	DecisionDeadline *time.Time `json:"decision_deadline" db:"decision_deadline"`
 	
 	// 関連データ
-	Requester *User `json:"requester,omitempty"`
-	Approver  *User `json:"approver,omitempty"`
+	Requester *User                 `json:"requester,omitempty"`
+	Approver  *User                 `json:"approver,omitempty"`
+	Consents  []ChangeRequestConsent `json:"consents,omitempty"` // 相手教員の同意
+
+	ApprovalSteps []ApprovalStep `json:"approval_steps,omitempty"` // 承認段階（順番どおり）
 }
 
 type CreateChangeRequestRequest struct {
 	Title       string      `json:"title" validate:"required"`
 	Description string      `json:"description" validate:"required"`
 	RequestData interface{} `json:"request_data" validate:"required"`
+	Draft       bool        `json:"draft"` // 下書きとして保存（提出するまで競合チェック・同意依頼を行わない）
+
+	// 省略した場合、実施日は申請内容の最も早い日付、判断期限は実施日の前日17時になる
+	EffectiveDate    string     `json:"effective_date"`
+	DecisionDeadline *time.Time `json:"decision_deadline"`
 }
 
 type UpdateChangeRequestRequest struct {
 	Title       string      `json:"title"`
 	Description string      `json:"description"`
 	RequestData interface{} `json:"request_data"`
+	Version     *int        `json:"version"`
+
+	EffectiveDate    string     `json:"effective_date"`
+	DecisionDeadline *time.Time `json:"decision_deadline"`
 }
 
 type ApproveRequestRequest struct {
 	Comment string `json:"comment"`
+	Version *int   `json:"version"` // 画面に表示していた申請の version（他のユーザーが先に更新していれば失敗する）
 }
 
 type RejectRequestRequest struct {
 	Comment string `json:"comment" validate:"required"`
+	Version *int   `json:"version"`
+}
+
+type RevertRequestRequest struct {
+	Comment string `json:"comment"`
+	Version *int   `json:"version"`
+}
+
+// ChangeRequestActionRequest - 提出・取り下げなど、内容を伴わない操作
+type ChangeRequestActionRequest struct {
+	Version *int `json:"version"`
 }
 
 // 申請ステータスの定数
@@ -48,6 +78,21 @@ const (
 	StatusApproved = "approved"
 	StatusRejected = "rejected"
 	StatusCanceled = "canceled"
+
+	StatusDraft           = "draft"            // 下書き（提出前）
+	StatusAwaitingConsent = "awaiting_consent" // 相手教員の同意待ち（同意後に承認待ちになる）
+	StatusApplied         = "applied"          // 時間割へ反映済み
+	StatusReverted        = "reverted"         // 反映を取り消し済み
+	StatusExpired         = "expired"          // 判断されないまま実施日を過ぎた
+)
+
+// 申請の種類
+const (
+	RequestTypeMove = "move" // 1コマの変更（既定）
+	RequestTypeSwap = "swap" // 2コマの入れ替え
+
+	RequestTypeSubstitute = "substitute" // 出張などの期間中の代講
+	RequestTypeBatch      = "batch"      // 複数の変更をまとめた申請（まとめて承認・反映する）
 )
 
 // 申請データの構造体
@@ -60,13 +105,106 @@ type TimetableChangeData struct {
 	NewPeriod           int    `json:"new_period"`
 	NewRoom             string `json:"new_room"`
 	Reason              string `json:"reason"`
+
+	// 日付指定の変更（Date を指定すると週間時間割は変えずにその日の授業だけを変更する）
+	ChangeType string `json:"change_type,omitempty"` // cancel / move / substitute / room_change
+	Date       string `json:"date,omitempty"`        // 変更対象の授業の日付（YYYY-MM-DD）
+	NewDate    string `json:"new_date,omitempty"`    // 移動先の日付（YYYY-MM-DD）
+
+	// 入れ替え（RequestType が swap の場合、OriginalTimetableID の授業と SwapTimetableID の授業の日時を入れ替える）
+	RequestType     string `json:"request_type,omitempty"`      // move / swap / substitute / batch
+	SwapTimetableID int    `json:"swap_timetable_id,omitempty"` // 入れ替え相手の授業
+	SwapDate        string `json:"swap_date,omitempty"`         // 日付指定の入れ替えで、相手の授業の日付（YYYY-MM-DD）
+
+	// 代講（RequestType が substitute の場合、Date〜DateTo の期間の授業をそれぞれの教員が代講する）
+	DateTo        string                 `json:"date_to,omitempty"`
+	Substitutions []SubstituteAssignment `json:"substitutions,omitempty"`
+
+	// まとめた申請（RequestType が batch の場合、Items の変更をすべて反映するか、どれも反映しない）
+	Items []TimetableChangeData `json:"items,omitempty"`
+	// 繰り返し（Items の中の日付指定の変更で、Date の代わりに期間を指定する）
+	Recurrence *RecurrenceRule `json:"recurrence,omitempty"`
+}
+
+// TimetableSnapshot - 申請の反映前後の時間割（1コマ）の内容
+type TimetableSnapshot struct {
+	ID        int    `json:"id"`
+	ClassID   int    `json:"class_id"`
+	GroupID   *int   `json:"group_id"`
+	SubjectID int    `json:"subject_id"`
+	TeacherID int    `json:"teacher_id"`
+	DayOfWeek string `json:"day_of_week"`
+	Period    int    `json:"period"`
+	Room      string `json:"room"`
+
+	CoTeacherIDs []int `json:"co_teacher_ids,omitempty"` // 共同担当教員
 }
 
-// RequestFilter - 申請フィルター
-type RequestFilter struct {
-	Status      *string `json:"status,omitempty"`
-	RequesterID *int    `json:"requester_id,omitempty"`
-	DateFrom    *string `json:"date_from,omitempty"`
-	DateTo      *string `json:"date_to,omitempty"`
+// ChangeRequestApplication - 承認時に時間割へ反映した変更の記録
+type ChangeRequestApplication struct {
+	ID              int                `json:"id" db:"id"`
+	ChangeRequestID int                `json:"change_request_id" db:"change_request_id"`
+	TimetableID     *int               `json:"timetable_id" db:"timetable_id"`
+	OverrideID      *int               `json:"override_id,omitempty" db:"override_id"` // 日付指定の変更の場合
+	Before          *TimetableSnapshot `json:"before" db:"before_data"`
+	After           *TimetableSnapshot `json:"after" db:"after_data"`
+	AppliedAt       time.Time          `json:"applied_at" db:"applied_at"`
+	RevertedAt      *time.Time         `json:"reverted_at" db:"reverted_at"` // 反映を取り消した日時
+}
+
+// 同意の状態
+const (
+	ConsentPending  = "pending"
+	ConsentAccepted = "accepted"
+	ConsentDeclined = "declined"
+)
+
+// ChangeRequestConsent - 申請に対する相手教員の同意（入れ替え申請などで承認者に回る前に必要）
+type ChangeRequestConsent struct {
+	ID              int        `json:"id" db:"id"`
+	ChangeRequestID int        `json:"change_request_id" db:"change_request_id"`
+	TeacherID       int        `json:"teacher_id" db:"teacher_id"`
+	TeacherName     string     `json:"teacher_name" db:"teacher_name"`
+	Status          string     `json:"status" db:"status"`
+	Comment         string     `json:"comment" db:"comment"`
+	RespondedAt     *time.Time `json:"responded_at" db:"responded_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+
+	Request *ChangeRequest `json:"request,omitempty"`
 }
 
+type RespondConsentRequest struct {
+	Accept  bool   `json:"accept"`
+	Comment string `json:"comment"`
+}
+
+// 申請の履歴の種類
+const (
+	EventCreated = "created" // 作成
+	EventComment = "comment" // コメント
+	EventStatus  = "status"  // 状態の変更
+	EventEdit    = "edit"    // 申請内容の修正
+	EventConsent = "consent" // 相手教員の同意・不同意
+
+	EventApproval = "approval" // 承認段階ごとの承認・却下
+	EventDeadline = "deadline" // 判断期限のリマインド・期限超過
+)
+
+// ChangeRequestEvent - 申請の履歴（誰が・いつ・何をしたか）
+type ChangeRequestEvent struct {
+	ID              int             `json:"id" db:"id"`
+	ChangeRequestID int             `json:"change_request_id" db:"change_request_id"`
+	ActorID         *int            `json:"actor_id" db:"actor_id"` // 自動処理の場合は null
+	ActorName       string          `json:"actor_name" db:"actor_name"`
+	Type            string          `json:"type" db:"event_type"`
+	FromStatus      string          `json:"from_status,omitempty" db:"from_status"`
+	ToStatus        string          `json:"to_status,omitempty" db:"to_status"`
+	Comment         string          `json:"comment,omitempty" db:"comment"`
+	Before          json.RawMessage `json:"before,omitempty" db:"before_data"`
+	After           json.RawMessage `json:"after,omitempty" db:"after_data"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+}
+
+type CreateCommentRequest struct {
+	Comment string `json:"comment" validate:"required"`
+}
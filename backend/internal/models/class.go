@@ -5,19 +5,34 @@ import (
 )
 
 type Class struct {
-	ID        int       `json:"id" db:"id"`
-	Grade     int       `json:"grade" db:"grade"`
-	ClassName string    `json:"class_name" db:"class_name"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID                int       `json:"id" db:"id"`
+	Grade             int       `json:"grade" db:"grade"`
+	ClassName         string    `json:"class_name" db:"class_name"`
+	DepartmentID      *int      `json:"department_id" db:"department_id"`
+	HomeroomTeacherID *int      `json:"homeroom_teacher_id" db:"homeroom_teacher_id"` // 担任
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+
+	// 関連データ
+	DepartmentName      string `json:"department_name,omitempty" db:"department_name"`
+	HomeroomTeacherName string `json:"homeroom_teacher_name,omitempty" db:"homeroom_teacher_name"`
 }
 
 type CreateClassRequest struct {
-	Grade     int    `json:"grade" validate:"required,min=1,max=5"`
-	ClassName string `json:"class_name" validate:"required"`
+	Grade             int    `json:"grade" validate:"required,min=1,max=5"`
+	ClassName         string `json:"class_name" validate:"required"`
+	DepartmentID      *int   `json:"department_id"`
+	HomeroomTeacherID *int   `json:"homeroom_teacher_id"`
 }
 
 type UpdateClassRequest struct {
-	Grade     int    `json:"grade"`
-	ClassName string `json:"class_name"`
-}
\ No newline at end of file
+	Grade             int    `json:"grade"`
+	ClassName         string `json:"class_name"`
+	DepartmentID      *int   `json:"department_id"`       // 省略時は変更しない、0 で学科なし
+	HomeroomTeacherID *int   `json:"homeroom_teacher_id"` // 省略時は変更しない、0 で担任なし
+}
+
+type ClassFilter struct {
+	Grade        *int `json:"grade"`
+	DepartmentID *int `json:"department_id"`
+}
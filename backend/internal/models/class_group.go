@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// ClassGroup - クラス内のグループ（選択A/選択B、実験の前半/後半など）
+type ClassGroup struct {
+	ID          int       `json:"id" db:"id"`
+	ClassID     int       `json:"class_id" db:"class_id"`
+	Name        string    `json:"name" db:"name"`
+	MemberCount int       `json:"member_count" db:"member_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateClassGroupRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type AddClassGroupMemberRequest struct {
+	StudentID int `json:"student_id" validate:"required"`
+}
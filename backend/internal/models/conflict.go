@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// 競合の種類
+const (
+	ConflictClass       = "class"       // クラス（グループ）の時間重複
+	ConflictTeacher     = "teacher"     // 教員の時間重複（共同担当を含む）
+	ConflictRoom        = "room"        // 授業場所の重複
+	ConflictUnavailable = "unavailable" // 教員の都合の悪い時間
+)
+
+// Conflict - 時間割の競合
+type Conflict struct {
+	Type                   string `json:"type"`
+	Message                string `json:"message"`
+	DayOfWeek              string `json:"day_of_week"`
+	Period                 int    `json:"period"`
+	TimetableID            int    `json:"timetable_id,omitempty"`             // 確認対象のコマ（新規の場合は 0）
+	ConflictingTimetableID int    `json:"conflicting_timetable_id,omitempty"` // 競合相手のコマ
+	ClassID                int    `json:"class_id,omitempty"`
+	TeacherID              int    `json:"teacher_id,omitempty"`
+	Room                   string `json:"room,omitempty"`
+}
+
+// ConflictCheckResult - 競合チェック結果
+type ConflictCheckResult struct {
+	HasConflicts bool       `json:"has_conflicts"`
+	Conflicts    []Conflict `json:"conflicts"`
+}
+
+// TeacherUnavailability - 教員の都合の悪い時間（Period が nil の場合は終日）
+type TeacherUnavailability struct {
+	ID        int       `json:"id" db:"id"`
+	TeacherID int       `json:"teacher_id" db:"teacher_id"`
+	DayOfWeek string    `json:"day_of_week" db:"day_of_week"`
+	Period    *int      `json:"period" db:"period"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateTeacherUnavailabilityRequest struct {
+	DayOfWeek string `json:"day_of_week" validate:"required"`
+	Period    *int   `json:"period"`
+	Reason    string `json:"reason"`
+}
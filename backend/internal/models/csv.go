@@ -12,6 +12,7 @@ type SubjectCSV struct {
 	Teacher1    string `csv:"教員１" json:"teacher1"`
 	Teacher2    string `csv:"教員２" json:"teacher2"`
 	Teacher3    string `csv:"教員３" json:"teacher3"`
+	Department  string `csv:"学科" json:"department"` // 任意（学科コードまたは学科名）
 }
 
 // CSV時間割データ構造
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+type Department struct {
+	ID        int       `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Name      string    `json:"name" db:"name"`
+	ShortName string    `json:"short_name" db:"short_name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateDepartmentRequest struct {
+	Code      string `json:"code" validate:"required"`
+	Name      string `json:"name" validate:"required"`
+	ShortName string `json:"short_name"`
+}
+
+type UpdateDepartmentRequest struct {
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	ShortName *string `json:"short_name"` // 省略時は変更しない、"" で略称なし
+}
+
+// 学科メンバー追加用構造体
+type AddDepartmentTeacherRequest struct {
+	UserID int `json:"user_id" validate:"required"`
+}
+
+type AddDepartmentSubjectRequest struct {
+	SubjectID int `json:"subject_id" validate:"required"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// Enrollment - 学生の所属クラス（年度ごと）
+type Enrollment struct {
+	ID            int       `json:"id" db:"id"`
+	StudentID     int       `json:"student_id" db:"student_id"`
+	ClassID       int       `json:"class_id" db:"class_id"`
+	AcademicYear  int       `json:"academic_year" db:"academic_year"`
+	StudentNumber string    `json:"student_number" db:"student_number"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+
+	// 関連データ
+	StudentName  string `json:"student_name" db:"student_name"`
+	StudentEmail string `json:"student_email" db:"student_email"`
+	Grade        int    `json:"grade" db:"grade"`
+	ClassName    string `json:"class_name" db:"class_name"`
+}
+
+type CreateEnrollmentRequest struct {
+	StudentID     int    `json:"student_id" validate:"required"`
+	ClassID       int    `json:"class_id" validate:"required"`
+	AcademicYear  int    `json:"academic_year"`
+	StudentNumber string `json:"student_number"`
+}
+
+// CSV学生名簿データ構造
+type EnrollmentCSV struct {
+	StudentNumber string `csv:"学籍番号" json:"student_number"`
+	Name          string `csv:"氏名" json:"name"`
+	Email         string `csv:"メールアドレス" json:"email"`
+	Class         string `csv:"クラス" json:"class"`
+}
+
+// StudentTimetable - 学生用の週間時間割
+type StudentTimetable struct {
+	Enrollment Enrollment        `json:"enrollment"`
+	WeekStart  string            `json:"week_start"`
+	WeekEnd    string            `json:"week_end"`
+	Timetable  WeeklyTimetable   `json:"timetable"`
+	Lessons    []EffectiveLesson `json:"lessons"` // 今週の日付ごとの授業（日付指定の変更を反映）
+	Changes    []ChangeRequest   `json:"changes"`
+}
+
+// AcademicYearOf - 日付が属する年度（4月始まり）
+func AcademicYearOf(t time.Time) int {
+	if t.Month() < time.April {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
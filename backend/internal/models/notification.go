@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// 通知の重要度（notifications.type）
+const (
+	NotificationInfo    = "info"
+	NotificationWarning = "warning"
+	NotificationSuccess = "success"
+	NotificationError   = "error"
+)
+
+// 通知の原因となった出来事（notifications.event_type）
+const (
+	NotificationRequestSubmitted = "request_submitted" // 申請が承認待ちになった（承認者へ）
+	NotificationConsentRequested = "consent_requested" // 申請への同意を求められた（相手教員へ）
+	NotificationRequestApproved  = "request_approved"  // 申請が承認され反映された
+	NotificationRequestRejected  = "request_rejected"  // 申請が却下された
+	NotificationTimetableChanged = "timetable_changed" // 自分の授業・クラスの時間割が変わった
+	NotificationRequestReverted  = "request_reverted"  // 反映済みの申請の取り消し
+	NotificationRequestExpired   = "request_expired"   // 判断されないまま実施日を過ぎた
+	NotificationRequestOverdue   = "request_overdue"   // 判断期限を過ぎた（管理者へのエスカレーション）
+	NotificationDeadlineReminder = "deadline_reminder" // 判断期限が近い
+	NotificationDailyDigest      = "daily_digest"      // 翌授業日の時間割の変更のまとめ
+)
+
+// Notification - ユーザーへの通知
+type Notification struct {
+	ID              int        `json:"id" db:"id"`
+	UserID          int        `json:"user_id" db:"user_id"`
+	Title           string     `json:"title" db:"title"`
+	Message         string     `json:"message" db:"message"`
+	Type            string     `json:"type" db:"type"`
+	Event           string     `json:"event" db:"event_type"`
+	ChangeRequestID *int       `json:"change_request_id" db:"change_request_id"`
+	IsRead          bool       `json:"is_read" db:"is_read"`
+	ReadAt          *time.Time `json:"read_at" db:"read_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+// NotificationPreferenceAll - すべての種類の通知メールをまとめて止める設定（notification_preferences.event_type）
+const NotificationPreferenceAll = "all"
+
+// NotificationPreference - 通知の種類ごとのメール受信設定
+type NotificationPreference struct {
+	Event        string `json:"event"`
+	Label        string `json:"label"`
+	EmailEnabled bool   `json:"email_enabled"`
+}
+
+// UpdateNotificationPreferencesRequest - メール受信設定の変更（通知の種類 → 受信するか。"all" で全体）
+type UpdateNotificationPreferencesRequest struct {
+	Email map[string]bool `json:"email"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// 権限の定数
+const (
+	PermissionRequestCreate  = "request.create"
+	PermissionRequestApprove = "request.approve"
+	PermissionTimetableEdit  = "timetable.edit"
+	PermissionMasterEdit     = "master.edit"
+	PermissionCSVImport      = "csv.import"
+	PermissionCSVExport      = "csv.export"
+	PermissionUserManage     = "user.manage"
+)
+
+// 追加ロールの定数（users.role とは別に割り当てる）
+const (
+	RoleDepartmentHead  = "department_head"  // 学科長
+	RoleAcademicAffairs = "academic_affairs" // 教務（教務係）
+	RoleAcademicDean    = "academic_dean"    // 教務主事
+)
+
+// AllPermissions - 定義済みの全権限
+var AllPermissions = []string{
+	PermissionRequestCreate,
+	PermissionRequestApprove,
+	PermissionTimetableEdit,
+	PermissionMasterEdit,
+	PermissionCSVImport,
+	PermissionCSVExport,
+	PermissionUserManage,
+}
+
+// ScopedPermissions - 学年・学科を限定して与えられる権限（申請の対象クラスで範囲を確認する）
+// それ以外の権限は全体に対する操作のため、範囲を限定した割り当てでは与えない
+var ScopedPermissions = []string{
+	PermissionRequestCreate,
+	PermissionRequestApprove,
+}
+
+// IsScopedPermission - 範囲を限定して与えられる権限か
+func IsScopedPermission(permission string) bool {
+	for _, p := range ScopedPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions - ロールごとの初期権限（role_permissions の初期値と同じ）
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin:           AllPermissions,
+	RoleTeacher:         {PermissionRequestCreate},
+	RoleStudent:         {},
+	RoleDepartmentHead:  {PermissionRequestCreate, PermissionRequestApprove},
+	RoleAcademicAffairs: {PermissionRequestApprove, PermissionTimetableEdit, PermissionMasterEdit, PermissionCSVImport, PermissionCSVExport},
+	RoleAcademicDean:    {PermissionRequestCreate, PermissionRequestApprove},
+}
+
+// PermissionScope - 権限の適用範囲（nil は全体）
+type PermissionScope struct {
+	Grade        *int `json:"grade,omitempty"`
+	DepartmentID *int `json:"department_id,omitempty"`
+}
+
+// IsGlobal - 範囲を限定していないか
+func (s PermissionScope) IsGlobal() bool {
+	return s.Grade == nil && s.DepartmentID == nil
+}
+
+// Covers - このスコープが target を含むか（target が nil なら常に true）
+func (s PermissionScope) Covers(target *PermissionScope) bool {
+	if target == nil {
+		return true
+	}
+	if s.Grade != nil && (target.Grade == nil || *s.Grade != *target.Grade) {
+		return false
+	}
+	if s.DepartmentID != nil && (target.DepartmentID == nil || *s.DepartmentID != *target.DepartmentID) {
+		return false
+	}
+	return true
+}
+
+// PermissionGrant - ユーザーが持つ権限とその範囲
+type PermissionGrant struct {
+	Permission string `json:"permission"`
+	Role       string `json:"role"`
+	PermissionScope
+}
+
+// RoleAssignment - ユーザーへの追加ロール割り当て
+type RoleAssignment struct {
+	ID           int       `json:"id" db:"id"`
+	UserID       int       `json:"user_id" db:"user_id"`
+	Role         string    `json:"role" db:"role"`
+	Grade        *int      `json:"grade" db:"grade"`
+	DepartmentID *int      `json:"department_id" db:"department_id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateRoleAssignmentRequest struct {
+	Role         string `json:"role" validate:"required"`
+	Grade        *int   `json:"grade"`
+	DepartmentID *int   `json:"department_id"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// 教室の種類（rooms.room_type）
+const (
+	RoomTypeHR      = "hr"      // ホームルーム教室
+	RoomTypeLecture = "lecture" // 講義室
+	RoomTypeLab     = "lab"     // 実験・実習室
+	RoomTypeGym     = "gym"     // 体育館・グラウンド
+	RoomTypeSpecial = "special" // 特別教室（コンピュータ室など）
+	RoomTypeOther   = "other"
+)
+
+// RoomUndecided - 教室が決まっていない授業の授業場所（教室としては登録しない）
+const RoomUndecided = "未定"
+
+type Room struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Building  string    `json:"building" db:"building"`
+	Capacity  *int      `json:"capacity" db:"capacity"`
+	RoomType  string    `json:"room_type" db:"room_type"`
+	Aliases   []string  `json:"aliases"` // 別表記
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateRoomRequest struct {
+	Name     string   `json:"name" validate:"required"`
+	Building string   `json:"building"`
+	Capacity *int     `json:"capacity"`
+	RoomType string   `json:"room_type"` // 省略時は other
+	Aliases  []string `json:"aliases"`
+}
+
+type UpdateRoomRequest struct {
+	Name     string   `json:"name"` // 変更すると時間割などの授業場所も変更し、元の名前は別表記に残す
+	Building *string  `json:"building"`
+	Capacity *int     `json:"capacity"`
+	RoomType string   `json:"room_type"`
+	Aliases  []string `json:"aliases"` // 指定した場合は置き換える
+}
+
+// MergeRoomRequest - 同じ教室の別の登録をまとめる（SourceRoomID の教室は削除し、名前を別表記に残す）
+type MergeRoomRequest struct {
+	SourceRoomID int `json:"source_room_id" validate:"required"`
+}
+
+type RoomFilter struct {
+	RoomType *string
+	Building *string
+}
+
+// RoomOccupancy - 教室の1週間の使用状況
+type RoomOccupancy struct {
+	Room            Room                                 `json:"room"`
+	WeekStart       string                               `json:"week_start"`
+	WeekEnd         string                               `json:"week_end"`
+	Timetable       WeeklyTimetable                      `json:"timetable"`        // 週間時間割でこの教室を使う授業
+	Days            map[string]map[int][]EffectiveLesson `json:"days"`             // 曜日 -> 時限 -> その週の授業（日付指定の変更を反映）
+	OccupiedPeriods int                                  `json:"occupied_periods"` // その週に使われるコマ数（休講・移動元・他の教室への変更を除く）
+	TotalPeriods    int                                  `json:"total_periods"`    // その週のコマ数（平日 × 時限）
+}
@@ -0,0 +1,23 @@
+package models
+
+// SubstituteAssignment - 代講申請の1コマ分（対象の授業・日付と代講する教員）
+type SubstituteAssignment struct {
+	TimetableID int    `json:"timetable_id"`
+	Date        string `json:"date"` // YYYY-MM-DD
+	TeacherID   int    `json:"teacher_id"`
+}
+
+// SubstituteCandidate - 代講できる教員の候補
+type SubstituteCandidate struct {
+	TeacherID   int    `json:"teacher_id"`
+	TeacherName string `json:"teacher_name"`
+	SameSubject bool   `json:"same_subject"` // 同じ科目を担当している
+	SameClass   bool   `json:"same_class"`   // 同じクラスの科目を担当している
+	LessonCount int    `json:"lesson_count"` // その日の担当授業数
+}
+
+// SubstituteSlot - 代講が必要な授業と、そのコマに空いている教員
+type SubstituteSlot struct {
+	Lesson     EffectiveLesson       `json:"lesson"`
+	Candidates []SubstituteCandidate `json:"candidates"`
+}
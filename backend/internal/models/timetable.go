@@ -7,6 +7,7 @@ import (
 type Timetable struct {
 	ID          int       `json:"id" db:"id"`
 	ClassID     int       `json:"class_id" db:"class_id"`
+	GroupID     *int      `json:"group_id" db:"group_id"` // NULL はクラス全体の授業
 	SubjectID   int       `json:"subject_id" db:"subject_id"`
 	TeacherID   int       `json:"teacher_id" db:"teacher_id"`
 	DayOfWeek   string    `json:"day_of_week" db:"day_of_week"`
@@ -15,24 +16,35 @@ type Timetable struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	
 	// 関連データ
-	ClassName   string `json:"class_name" db:"class_name"`
-	Grade       int    `json:"grade" db:"grade"`
-	SubjectName string `json:"subject_name" db:"subject_name"`
-	TeacherName string `json:"teacher_name" db:"teacher_name"`
+	ClassName      string `json:"class_name" db:"class_name"`
+	Grade          int    `json:"grade" db:"grade"`
+	GroupName      string `json:"group_name,omitempty" db:"group_name"`
+	DepartmentID   *int   `json:"department_id" db:"department_id"`
+	DepartmentName string `json:"department_name,omitempty" db:"department_name"`
+	SubjectName    string `json:"subject_name" db:"subject_name"`
+	TeacherName    string `json:"teacher_name" db:"teacher_name"`
+	CoTeacherIDs   []int  `json:"co_teacher_ids,omitempty"` // 共同担当教員
+
+	// 承認済みの変更が反映されたコマ
+	IsChanged bool `json:"is_changed,omitempty"`
 }
 
-type WeeklyTimetable map[string]map[int]*Timetable // day -> period -> timetable
+type WeeklyTimetable map[string]map[int][]*Timetable // day -> period -> timetables（グループ別の並行授業を含む）
 
 type TimetableFilter struct {
-	Grade     *int    `json:"grade"`
-	ClassID   *int    `json:"class_id"`        // 修正: ClassID に統一
-	ClassName *string `json:"class_name"`
-	DayOfWeek *string `json:"day_of_week"`
-	TeacherID *int    `json:"teacher_id"`
+	ID           *int    `json:"id"`
+	Grade        *int    `json:"grade"`
+	ClassID      *int    `json:"class_id"`        // 修正: ClassID に統一
+	GroupID      *int    `json:"group_id"`
+	ClassName    *string `json:"class_name"`
+	DayOfWeek    *string `json:"day_of_week"`
+	TeacherID    *int    `json:"teacher_id"`
+	DepartmentID *int    `json:"department_id"`
 }
 
 type CreateTimetableRequest struct {
 	ClassID   int    `json:"class_id" validate:"required"`
+	GroupID   *int   `json:"group_id"`
 	SubjectID int    `json:"subject_id" validate:"required"`
 	TeacherID int    `json:"teacher_id" validate:"required"`
 	Day       string `json:"day" validate:"required"`
@@ -42,6 +54,7 @@ type CreateTimetableRequest struct {
 
 type UpdateTimetableRequest struct {
 	ClassID   int    `json:"class_id"`
+	GroupID   *int   `json:"group_id"`
 	SubjectID int    `json:"subject_id"`
 	TeacherID int    `json:"teacher_id"`
 	Day       string `json:"day"`
@@ -64,4 +77,13 @@ const (
 	Period2 = 2
 	Period3 = 3
 	Period4 = 4
-)
\ No newline at end of file
+)
+// TeacherTimetable - 教員の1週間の時間割（担当するすべてのクラスの授業）
+type TeacherTimetable struct {
+	TeacherID   int                                  `json:"teacher_id"`
+	TeacherName string                               `json:"teacher_name"`
+	WeekStart   string                               `json:"week_start"`
+	WeekEnd     string                               `json:"week_end"`
+	Timetable   WeeklyTimetable                      `json:"timetable"` // 週間時間割（共同担当の授業を含む）
+	Days        map[string]map[int][]EffectiveLesson `json:"days"`      // 曜日 -> 時限 -> その週の授業（日付指定の変更を反映、代講する授業を含む）
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// 時間割の変更イベントの種類（SSE の event）
+const (
+	TimetableEventChanged  = "timetable_changed"  // 申請の承認・日付指定の変更で時間割が変わった
+	TimetableEventReverted = "timetable_reverted" // 反映済みの申請を取り消して時間割が戻った
+)
+
+// TimetableEvent - 購読しているクライアントへ送る時間割の変更
+// UserIDs は影響を受ける教員・学生で、送信先の判定にのみ使う
+type TimetableEvent struct {
+	Type            string    `json:"type"`
+	ChangeRequestID *int      `json:"change_request_id,omitempty"`
+	Title           string    `json:"title"`
+	ClassIDs        []int     `json:"class_ids"`
+	TeacherIDs      []int     `json:"teacher_ids"`
+	Rooms           []string  `json:"rooms"`
+	Dates           []string  `json:"dates,omitempty"` // 日付指定の変更の日付（週間時間割の変更は空）
+	OccurredAt      time.Time `json:"occurred_at"`
+
+	UserIDs []int `json:"-"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+)
+
+// 日付指定の授業変更の種類
+const (
+	OverrideCancel     = "cancel"      // 休講
+	OverrideMove       = "move"        // 日付・時限の移動
+	OverrideSubstitute = "substitute"  // 代講
+	OverrideRoomChange = "room_change" // 教室変更
+)
+
+// 実際の授業の状態
+const (
+	LessonRegular     = "regular"      // 通常どおり
+	LessonCanceled    = "canceled"     // 休講
+	LessonMovedOut    = "moved_out"    // 別の日時へ移動（元のコマ）
+	LessonMovedIn     = "moved_in"     // 別の日時から移動（移動先のコマ）
+	LessonSubstituted = "substituted"  // 代講
+	LessonRoomChanged = "room_changed" // 教室変更
+)
+
+// TimetableOverride - 日付指定の授業変更（週間時間割に重ねて適用する）
+type TimetableOverride struct {
+	ID              int    `json:"id" db:"id"`
+	TimetableID     int    `json:"timetable_id" db:"timetable_id"`
+	ChangeRequestID *int   `json:"change_request_id" db:"change_request_id"`
+	Type            string `json:"type" db:"override_type"`
+	Date            string `json:"date" db:"date"` // 変更対象の授業の日付（YYYY-MM-DD）
+	NewDate         string `json:"new_date,omitempty" db:"new_date"`
+	NewPeriod       *int   `json:"new_period,omitempty" db:"new_period"`
+	NewTeacherID    *int   `json:"new_teacher_id,omitempty" db:"new_teacher_id"`
+	// 代講で交代する教員（nil は主担当。共同担当を指定するとその教員だけを入れ替える）
+	ReplacedTeacherID *int      `json:"replaced_teacher_id,omitempty" db:"replaced_teacher_id"`
+	NewRoom           string    `json:"new_room,omitempty" db:"new_room"`
+	Note              string    `json:"note" db:"note"`
+	CreatedBy         *int      `json:"created_by" db:"created_by"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateTimetableOverrideRequest struct {
+	TimetableID       int    `json:"timetable_id" validate:"required"`
+	Type              string `json:"type" validate:"required"`
+	Date              string `json:"date" validate:"required"`
+	NewDate           string `json:"new_date"`
+	NewPeriod         *int   `json:"new_period"`
+	NewTeacherID      *int   `json:"new_teacher_id"`
+	ReplacedTeacherID *int   `json:"replaced_teacher_id"`
+	NewRoom           string `json:"new_room"`
+	Note              string `json:"note"`
+}
+
+// EffectiveLesson - 日付指定の変更を反映した、特定の日の授業
+type EffectiveLesson struct {
+	Timetable
+	Date           string `json:"date"`
+	Status         string `json:"status"`
+	OverrideIDs    []int  `json:"override_ids,omitempty"`
+	OriginalDate   string `json:"original_date,omitempty"`   // 移動・変更前の日付
+	OriginalPeriod int    `json:"original_period,omitempty"` // 移動・変更前の時限
+	Note           string `json:"note,omitempty"`
+}
+
+// EffectiveTimetableFilter - 実際の時間割の取得条件
+type EffectiveTimetableFilter struct {
+	From      time.Time
+	To        time.Time
+	ClassID   *int
+	TeacherID *int
+	Room      *string
+}
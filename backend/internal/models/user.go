@@ -53,11 +53,3 @@ func (u *User) IsTeacher() bool {
 func (u *User) IsStudent() bool {
 	return u.Role == RoleStudent
 }
-
-func (u *User) CanCreateRequest() bool {
-	return u.Role == RoleTeacher || u.Role == RoleAdmin
-}
-
-func (u *User) CanApproveRequest() bool {
-	return u.Role == RoleAdmin
-}
\ No newline at end of file
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook で送る出来事（webhook_deliveries.event_type）
+// 申請の出来事は "request." に申請の履歴の種類（created・status・edit・comment・consent・approval・deadline）を付ける
+const (
+	WebhookEventRequestPrefix     = "request."
+	WebhookEventTimetableChanged  = "timetable.changed"
+	WebhookEventTimetableReverted = "timetable.reverted"
+	WebhookEventPing              = "ping" // 設定確認用
+)
+
+// Webhook の送信状態
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead" // 再送をやめた（dead letter）
+)
+
+// Webhook - 出来事を通知する外部の URL
+// Secret は署名の鍵で、作成時と鍵の再発行時のみ返す
+type Webhook struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	Events    []string  `json:"events" db:"events"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedBy *int      `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	Name     string   `json:"name" validate:"required"`
+	URL      string   `json:"url" validate:"required"`
+	Events   []string `json:"events"` // 省略時はすべて
+	IsActive *bool    `json:"is_active"`
+}
+
+type UpdateWebhookRequest struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	Events       []string `json:"events"`
+	IsActive     *bool    `json:"is_active"`
+	RotateSecret bool     `json:"rotate_secret"` // 署名の鍵を再発行する
+}
+
+// WebhookPayload - Webhook で送る本文
+// 送信記録の ID は X-Webhook-Delivery ヘッダーで送る（受信側での重複排除用）
+type WebhookPayload struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookDelivery - Webhook の送信記録
+type WebhookDelivery struct {
+	ID             int             `json:"id" db:"id"`
+	WebhookID      int             `json:"webhook_id" db:"webhook_id"`
+	Event          string          `json:"event" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	LastError      string          `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,587 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// ErrAlreadyApprovedStep - 同じ申請の別の承認段階を既に承認している
+var ErrAlreadyApprovedStep = errors.New("同じ申請の複数の段階を一人で承認することはできません")
+
+var requestKinds = []string{
+	models.RequestKindMove,
+	models.RequestKindSwap,
+	models.RequestKindSubstitute,
+	models.RequestKindRoomChange,
+	models.RequestKindCancel,
+}
+
+var roleLabels = map[string]string{
+	models.RoleAdmin:           "管理者",
+	models.RoleTeacher:         "教員",
+	models.RoleDepartmentHead:  "学科長",
+	models.RoleAcademicAffairs: "教務係",
+	models.RoleAcademicDean:    "教務主事",
+}
+
+func roleLabel(role string) string {
+	if label, ok := roleLabels[role]; ok {
+		return label
+	}
+	return role
+}
+
+type ApprovalPolicyService struct {
+	db *sql.DB
+}
+
+func NewApprovalPolicyService(db *sql.DB) *ApprovalPolicyService {
+	return &ApprovalPolicyService{db: db}
+}
+
+// GetPolicies - 承認ポリシー一覧取得（優先度の高い順）
+func (s *ApprovalPolicyService) GetPolicies() ([]models.ApprovalPolicy, error) {
+	query := squirrel.Select("id", "name", "COALESCE(request_kind, '')", "grade", "department_id", "priority", "created_at", "updated_at").
+		From("approval_policies").
+		OrderBy("priority DESC", "id").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	policies := []models.ApprovalPolicy{}
+	for rows.Next() {
+		p, err := scanApprovalPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		policies = append(policies, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range policies {
+		policies[i].Steps, err = loadPolicySteps(s.db, policies[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return policies, nil
+}
+
+// GetPolicyByID - 承認ポリシー取得
+func (s *ApprovalPolicyService) GetPolicyByID(id int) (*models.ApprovalPolicy, error) {
+	row := s.db.QueryRow(`
+		SELECT id, name, COALESCE(request_kind, ''), grade, department_id, priority, created_at, updated_at
+		FROM approval_policies WHERE id = ?
+	`, id)
+	p, err := scanApprovalPolicy(row)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Steps, err = loadPolicySteps(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// CreatePolicy - 承認ポリシー作成
+func (s *ApprovalPolicyService) CreatePolicy(req *models.CreateApprovalPolicyRequest) (*models.ApprovalPolicy, error) {
+	if err := validateApprovalPolicy(req); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO approval_policies (name, request_kind, grade, department_id, priority)
+		VALUES (?, ?, ?, ?, ?)
+	`, req.Name, nullableString(req.RequestKind), req.Grade, req.DepartmentID, req.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertPolicySteps(tx, int(id), req.Steps); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetPolicyByID(int(id))
+}
+
+// UpdatePolicy - 承認ポリシー更新（承認段階は置き換え、提出済みの申請の承認段階は変わらない）
+func (s *ApprovalPolicyService) UpdatePolicy(id int, req *models.CreateApprovalPolicyRequest) (*models.ApprovalPolicy, error) {
+	if err := validateApprovalPolicy(req); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE approval_policies
+		SET name = ?, request_kind = ?, grade = ?, department_id = ?, priority = ?
+		WHERE id = ?
+	`, req.Name, nullableString(req.RequestKind), req.Grade, req.DepartmentID, req.Priority, id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		// 値が変わらない場合も 0 件になるため存在を確認する
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM approval_policies WHERE id = ?", id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			return nil, sql.ErrNoRows
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM approval_policy_steps WHERE policy_id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := insertPolicySteps(tx, id, req.Steps); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetPolicyByID(id)
+}
+
+// DeletePolicy - 承認ポリシー削除
+func (s *ApprovalPolicyService) DeletePolicy(id int) error {
+	result, err := s.db.Exec("DELETE FROM approval_policies WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func validateApprovalPolicy(req *models.CreateApprovalPolicyRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("ポリシー名を入力してください")
+	}
+	if req.RequestKind != "" && !containsString(requestKinds, req.RequestKind) {
+		return fmt.Errorf("未定義の申請の分類です: %s", req.RequestKind)
+	}
+	if len(req.Steps) == 0 {
+		return fmt.Errorf("承認段階を1つ以上指定してください")
+	}
+	for _, role := range req.Steps {
+		if _, ok := models.DefaultRolePermissions[role]; !ok {
+			return fmt.Errorf("未定義のロールです: %s", role)
+		}
+	}
+	return nil
+}
+
+func scanApprovalPolicy(scanner interface{ Scan(...interface{}) error }) (*models.ApprovalPolicy, error) {
+	var p models.ApprovalPolicy
+	var grade, departmentID sql.NullInt64
+	err := scanner.Scan(&p.ID, &p.Name, &p.RequestKind, &grade, &departmentID, &p.Priority, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Grade = nullIntPtr(grade)
+	p.DepartmentID = nullIntPtr(departmentID)
+	return &p, nil
+}
+
+func loadPolicySteps(q queryer, policyID int) ([]string, error) {
+	rows, err := q.Query("SELECT role FROM approval_policy_steps WHERE policy_id = ? ORDER BY step_order", policyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		steps = append(steps, role)
+	}
+	return steps, rows.Err()
+}
+
+func insertPolicySteps(tx *sql.Tx, policyID int, steps []string) error {
+	insert := squirrel.Insert("approval_policy_steps").
+		Columns("policy_id", "step_order", "role").
+		PlaceholderFormat(squirrel.Question)
+	for i, role := range steps {
+		insert = insert.Values(policyID, i+1, role)
+	}
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %v", err)
+	}
+	_, err = tx.Exec(sqlStr, args...)
+	return err
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// requestKind - 承認ポリシーを選ぶための申請の分類
+func requestKind(data *models.TimetableChangeData) string {
+	switch data.RequestType {
+	case models.RequestTypeSwap:
+		return models.RequestKindSwap
+	case models.RequestTypeSubstitute:
+		return models.RequestKindSubstitute
+	case models.RequestTypeBatch:
+		return batchKind(data)
+	}
+
+	if data.Date != "" || data.Recurrence != nil {
+		switch overrideFromChangeData(data).Type {
+		case models.OverrideRoomChange:
+			return models.RequestKindRoomChange
+		case models.OverrideCancel:
+			return models.RequestKindCancel
+		case models.OverrideSubstitute:
+			return models.RequestKindSubstitute
+		}
+		return models.RequestKindMove
+	}
+
+	// 週間時間割の変更で教室だけを変える場合
+	if data.NewRoom != "" && data.NewClassID == 0 && data.NewSubjectID == 0 && data.NewTeacherID == 0 &&
+		data.NewDay == "" && data.NewPeriod == 0 {
+		return models.RequestKindRoomChange
+	}
+	return models.RequestKindMove
+}
+
+// matchApprovalPolicy - 申請の分類・範囲に該当するポリシーの承認段階（該当なしの場合は nil）
+func matchApprovalPolicy(q queryer, kind string, scope *models.PermissionScope) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT id, name, COALESCE(request_kind, ''), grade, department_id, priority, created_at, updated_at
+		FROM approval_policies
+	`)
+	if err != nil {
+		return nil, err
+	}
+	policies := []models.ApprovalPolicy{}
+	for rows.Next() {
+		p, err := scanApprovalPolicy(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		policies = append(policies, *p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	policy := selectApprovalPolicy(policies, kind, scope)
+	if policy == nil {
+		return nil, nil
+	}
+	return loadPolicySteps(q, policy.ID)
+}
+
+// selectApprovalPolicy - 申請の分類・範囲に該当するポリシーのうち優先度が最も高いもの（該当なしの場合は nil）
+// 優先度が同じ場合は条件を多く指定したポリシーを、それも同じなら先に作成したポリシーを優先する
+func selectApprovalPolicy(policies []models.ApprovalPolicy, kind string, scope *models.PermissionScope) *models.ApprovalPolicy {
+	var grade, departmentID *int
+	if scope != nil {
+		grade = scope.Grade
+		departmentID = scope.DepartmentID
+	}
+	matches := func(condition, value *int) bool {
+		return condition == nil || (value != nil && *condition == *value)
+	}
+	conditions := func(p *models.ApprovalPolicy) int {
+		n := 0
+		if p.RequestKind != "" {
+			n++
+		}
+		if p.Grade != nil {
+			n++
+		}
+		if p.DepartmentID != nil {
+			n++
+		}
+		return n
+	}
+
+	var best *models.ApprovalPolicy
+	for i := range policies {
+		p := &policies[i]
+		if (p.RequestKind != "" && p.RequestKind != kind) || !matches(p.Grade, grade) || !matches(p.DepartmentID, departmentID) {
+			continue
+		}
+		switch {
+		case best == nil, p.Priority > best.Priority:
+			best = p
+		case p.Priority < best.Priority:
+		case conditions(p) > conditions(best), conditions(p) == conditions(best) && p.ID < best.ID:
+			best = p
+		}
+	}
+	return best
+}
+
+// assignApprovalSteps - 提出された申請に、該当するポリシーの承認段階を作り直す（呼び出し側のトランザクション内で実行）
+// 該当するポリシーがなければ承認段階を作らず、承認権限を持つ誰か一人の承認で反映する
+func (s *ChangeRequestService) assignApprovalSteps(tx *sql.Tx, requestID int, requestData json.RawMessage) error {
+	if _, err := tx.Exec("DELETE FROM change_request_approval_steps WHERE change_request_id = ?", requestID); err != nil {
+		return err
+	}
+
+	var data models.TimetableChangeData
+	if err := json.Unmarshal(requestData, &data); err != nil {
+		return fmt.Errorf("申請データが不正です: %v", err)
+	}
+	scope, err := s.requestScope(requestData)
+	if err != nil {
+		return err
+	}
+
+	steps, err := matchApprovalPolicy(tx, requestKind(&data), scope)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	noDepartment, err := s.withoutDepartment(&data)
+	if err != nil {
+		return err
+	}
+	if noDepartment {
+		steps = stepsWithoutDepartment(steps)
+	}
+
+	insert := squirrel.Insert("change_request_approval_steps").
+		Columns("change_request_id", "step_order", "role", "status").
+		PlaceholderFormat(squirrel.Question)
+	for i, role := range steps {
+		insert = insert.Values(requestID, i+1, role, models.ApprovalStepPending)
+	}
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %v", err)
+	}
+	_, err = tx.Exec(sqlStr, args...)
+	return err
+}
+
+// withoutDepartment - 申請の対象がすべて学科のないクラス（1年生の混合学級）の授業か
+// 学科のあるクラスを含む場合や、対象のクラスが分からない場合は false
+func (s *ChangeRequestService) withoutDepartment(data *models.TimetableChangeData) (bool, error) {
+	requestData, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+	scopes, err := s.requestClassScopes(requestData)
+	if err != nil {
+		return false, err
+	}
+	if len(scopes) == 0 {
+		return false, nil
+	}
+
+	for _, scope := range scopes {
+		if scope.Grade == nil || scope.DepartmentID != nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// stepsWithoutDepartment - 学科のないクラスの申請の承認段階（学科長の段階を除く）
+// 学科を限定した学科長は学科のないクラスを担当しないため、そのままでは管理者しか判断できなくなる
+// 学科長の段階しかない場合は教務主事の段階に置き換える
+func stepsWithoutDepartment(steps []string) []string {
+	var result []string
+	for _, role := range steps {
+		if role != models.RoleDepartmentHead {
+			result = append(result, role)
+		}
+	}
+	if len(result) == 0 {
+		return []string{models.RoleAcademicDean}
+	}
+	return result
+}
+
+// loadApprovalSteps - 申請の承認段階（順番どおり）
+func loadApprovalSteps(q queryer, requestID int) ([]models.ApprovalStep, error) {
+	rows, err := q.Query(`
+		SELECT s.id, s.change_request_id, s.step_order, s.role, s.status, s.approver_id, COALESCE(u.name, ''),
+		       COALESCE(s.comment, ''), s.decided_at
+		FROM change_request_approval_steps s
+		LEFT JOIN users u ON s.approver_id = u.id
+		WHERE s.change_request_id = ?
+		ORDER BY s.step_order
+	`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []models.ApprovalStep{}
+	for rows.Next() {
+		var step models.ApprovalStep
+		var approverID sql.NullInt64
+		var decidedAt sql.NullTime
+		err := rows.Scan(&step.ID, &step.ChangeRequestID, &step.StepOrder, &step.Role, &step.Status, &approverID,
+			&step.ApproverName, &step.Comment, &decidedAt)
+		if err != nil {
+			return nil, err
+		}
+		step.ApproverID = nullIntPtr(approverID)
+		if decidedAt.Valid {
+			step.DecidedAt = &decidedAt.Time
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+// currentApprovalStep - 次に判断する承認段階（全て承認済みなら nil）
+func currentApprovalStep(steps []models.ApprovalStep) *models.ApprovalStep {
+	for i := range steps {
+		if steps[i].Status == models.ApprovalStepPending {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+// checkStepApprover - 承認段階のロールを申請対象の範囲で持つか確認する
+// 多段階の承認にするため、同じ申請の別の段階を承認していれば判断できない
+// ただし管理者は、その段階のロールを対象範囲で持つユーザーが他にいない場合に限り続けて判断できる
+// （学科長・教務主事を割り当てていない運用で、申請を反映できなくならないように）
+func (s *ChangeRequestService) checkStepApprover(step *models.ApprovalStep, steps []models.ApprovalStep, approverID int, scope *models.PermissionScope) error {
+	ok, err := s.permissionService.HasRole(approverID, step.Role, scope)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w（%sの承認待ちです）", ErrPermissionDenied, roleLabel(step.Role))
+	}
+
+	for _, other := range steps {
+		if other.Status == models.ApprovalStepApproved && other.ApproverID != nil && *other.ApproverID == approverID {
+			return s.checkAdminOverride(step, approverID, scope)
+		}
+	}
+	return nil
+}
+
+// checkAdminOverride - 別の段階を承認済みのユーザーが続けて判断できるか（管理者で、段階のロールを持つ他のユーザーがいない場合のみ）
+func (s *ChangeRequestService) checkAdminOverride(step *models.ApprovalStep, approverID int, scope *models.PermissionScope) error {
+	isAdmin, err := s.permissionService.HasRole(approverID, models.RoleAdmin, nil)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return ErrAlreadyApprovedStep
+	}
+
+	holders, err := s.permissionService.RoleHolderIDs(step.Role, scope)
+	if err != nil {
+		return err
+	}
+	if len(holders) > 0 {
+		return fmt.Errorf("%w（%sの承認待ちです）", ErrAlreadyApprovedStep, roleLabel(step.Role))
+	}
+	return nil
+}
+
+// decideApprovalStep - 承認段階の承認・却下を記録する（呼び出し側のトランザクション内で実行）
+func decideApprovalStep(tx *sql.Tx, step *models.ApprovalStep, status string, approverID int, comment string) error {
+	now := time.Now()
+	_, err := tx.Exec(`
+		UPDATE change_request_approval_steps
+		SET status = ?, approver_id = ?, comment = ?, decided_at = ?
+		WHERE id = ?
+	`, status, approverID, comment, now, step.ID)
+	if err != nil {
+		return err
+	}
+	step.Status = status
+	step.ApproverID = &approverID
+	step.Comment = comment
+	step.DecidedAt = &now
+
+	action := "承認"
+	if status == models.ApprovalStepRejected {
+		action = "却下"
+	}
+	eventComment := fmt.Sprintf("%d段階目（%s）を%sしました", step.StepOrder, roleLabel(step.Role), action)
+	if comment != "" {
+		eventComment += "：" + comment
+	}
+
+	return recordEvent(tx, &models.ChangeRequestEvent{
+		ChangeRequestID: step.ChangeRequestID,
+		ActorID:         &approverID,
+		Type:            models.EventApproval,
+		ToStatus:        status,
+		Comment:         eventComment,
+	})
+}
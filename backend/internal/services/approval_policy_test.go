@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+
+	"kosen-schedule-system/internal/models"
+)
+
+func TestSelectApprovalPolicy(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	policies := []models.ApprovalPolicy{
+		{ID: 1, Name: "全体", Priority: 0},
+		{ID: 2, Name: "代講", RequestKind: models.RequestKindSubstitute, Priority: 0},
+		{ID: 3, Name: "1年", Grade: intPtr(1), Priority: 0},
+		{ID: 4, Name: "1年の代講", RequestKind: models.RequestKindSubstitute, Grade: intPtr(1), Priority: 0},
+		{ID: 5, Name: "情報工学科", DepartmentID: intPtr(3), Priority: 10},
+		{ID: 6, Name: "5年", Grade: intPtr(5), Priority: 0},
+		{ID: 7, Name: "5年（後から作成）", Grade: intPtr(5), Priority: 0},
+	}
+
+	tests := []struct {
+		name  string
+		kind  string
+		scope *models.PermissionScope
+		want  int
+	}{
+		{"条件なしのポリシーが該当", models.RequestKindMove, nil, 1},
+		{"分類が一致するポリシーを優先", models.RequestKindSubstitute, nil, 2},
+		{"学年が一致するポリシーを優先", models.RequestKindMove, &models.PermissionScope{Grade: intPtr(1)}, 3},
+		{"条件を多く指定したポリシーを優先", models.RequestKindSubstitute, &models.PermissionScope{Grade: intPtr(1)}, 4},
+		{"優先度は条件の数より優先", models.RequestKindSubstitute, &models.PermissionScope{Grade: intPtr(1), DepartmentID: intPtr(3)}, 5},
+		{"学年が違うポリシーは該当しない", models.RequestKindMove, &models.PermissionScope{Grade: intPtr(2)}, 1},
+		{"範囲のない申請に学年のポリシーは該当しない", models.RequestKindCancel, &models.PermissionScope{}, 1},
+		{"優先度と条件の数が同じなら先に作成したもの", models.RequestKindMove, &models.PermissionScope{Grade: intPtr(5)}, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectApprovalPolicy(policies, tt.kind, tt.scope)
+			if got == nil || got.ID != tt.want {
+				t.Errorf("selectApprovalPolicy() = %+v, want policy %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("該当なし", func(t *testing.T) {
+		if got := selectApprovalPolicy(policies[5:], models.RequestKindMove, nil); got != nil {
+			t.Errorf("selectApprovalPolicy() = %+v, want nil", got)
+		}
+	})
+}
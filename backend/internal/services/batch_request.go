@@ -0,0 +1,290 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// まとめた申請で一度に扱える変更の件数（繰り返しを展開した後）
+const maxBatchItems = 200
+
+// BatchConflictError - まとめた申請の一部の変更を反映できない（Items は全件の結果）
+type BatchConflictError struct {
+	Items []models.BatchItemResult
+}
+
+func (e *BatchConflictError) Error() string {
+	failed := 0
+	for _, item := range e.Items {
+		if item.HasConflicts || item.Error != "" {
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d件中%d件の変更を反映できません", len(e.Items), failed)
+}
+
+// batchItem - 展開後の変更と、items での番号
+type batchItem struct {
+	source int
+	data   models.TimetableChangeData
+}
+
+// expandBatchItems - まとめた申請の変更を、繰り返しを日付ごとに展開して並べる
+func expandBatchItems(q queryer, data *models.TimetableChangeData) ([]batchItem, error) {
+	if len(data.Items) == 0 {
+		return nil, fmt.Errorf("まとめて申請する変更を指定してください")
+	}
+
+	var items []batchItem
+	for i, item := range data.Items {
+		if item.RequestType == models.RequestTypeBatch {
+			return nil, fmt.Errorf("%d件目：まとめた申請の中にまとめた申請は指定できません", i+1)
+		}
+
+		if item.Recurrence == nil {
+			items = append(items, batchItem{source: i, data: item})
+		} else {
+			expanded, err := expandRecurrence(q, &item)
+			if err != nil {
+				return nil, fmt.Errorf("%d件目：%v", i+1, err)
+			}
+			for _, e := range expanded {
+				items = append(items, batchItem{source: i, data: e})
+			}
+		}
+
+		if len(items) > maxBatchItems {
+			return nil, fmt.Errorf("一度に申請できる変更は%d件までです", maxBatchItems)
+		}
+	}
+
+	return items, nil
+}
+
+// expandRecurrence - 繰り返しの変更を、期間内で対象の授業がある日ごとの日付指定の変更にする
+func expandRecurrence(q queryer, item *models.TimetableChangeData) ([]models.TimetableChangeData, error) {
+	rule := item.Recurrence
+	if item.RequestType != "" && item.RequestType != models.RequestTypeMove {
+		return nil, fmt.Errorf("繰り返しは1コマの日付指定の変更にのみ指定できます")
+	}
+	if item.OriginalTimetableID == 0 {
+		return nil, fmt.Errorf("変更対象の時間割が指定されていません")
+	}
+	if item.NewDate != "" || item.NewDay != "" {
+		return nil, fmt.Errorf("繰り返しの変更では移動先の日付・曜日は指定できません")
+	}
+
+	from, err := time.Parse(dateLayout, rule.From)
+	if err != nil {
+		return nil, fmt.Errorf("開始日が不正です: %s", rule.From)
+	}
+	to, err := time.Parse(dateLayout, rule.To)
+	if err != nil {
+		return nil, fmt.Errorf("終了日が不正です: %s", rule.To)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("終了日は開始日以降にしてください")
+	}
+	interval := rule.IntervalWeeks
+	if interval == 0 {
+		interval = 1
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("繰り返しの間隔が不正です: %d", interval)
+	}
+
+	except := make(map[string]bool)
+	for _, d := range rule.Except {
+		except[d] = true
+	}
+
+	var dayOfWeek string
+	err = q.QueryRow("SELECT day_of_week FROM timetables WHERE id = ?", item.OriginalTimetableID).Scan(&dayOfWeek)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("変更対象の時間割が見つかりません")
+		}
+		return nil, err
+	}
+
+	// 期間内で最初に授業がある日から interval 週ごと
+	first := from
+	for weekdayName(first) != dayOfWeek {
+		first = first.AddDate(0, 0, 1)
+		if first.After(to) {
+			break
+		}
+	}
+
+	var items []models.TimetableChangeData
+	for d := first; !d.After(to); d = d.AddDate(0, 0, 7*interval) {
+		date := d.Format(dateLayout)
+		if except[date] {
+			continue
+		}
+		expanded := *item
+		expanded.Recurrence = nil
+		expanded.Date = date
+		items = append(items, expanded)
+
+		if len(items) > maxBatchItems {
+			return nil, fmt.Errorf("一度に申請できる変更は%d件までです", maxBatchItems)
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("期間内に対象の授業がありません")
+	}
+
+	return items, nil
+}
+
+// prepareBatchItems - まとめた申請の各変更を検証し、同意が必要な教員をまとめて返す（競合は checkBatch で確認する）
+func prepareBatchItems(q queryer, requesterID int, data *models.TimetableChangeData) ([]int, error) {
+	items, err := expandBatchItems(q, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var teacherIDs []int
+	seen := make(map[int]bool)
+	for i := range items {
+		item := &items[i].data
+		if item.RequestType == models.RequestTypeSubstitute {
+			if err := validateSubstituteLessons(q, requesterID, item); err != nil {
+				return nil, fmt.Errorf("%d件目：%v", items[i].source+1, err)
+			}
+		}
+		if item.RequestType == models.RequestTypeSwap {
+			if err := validateSwapLessons(q, requesterID, item); err != nil {
+				return nil, fmt.Errorf("%d件目：%v", items[i].source+1, err)
+			}
+		}
+
+		ids, err := consentTeacherIDs(q, requesterID, item)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				teacherIDs = append(teacherIDs, id)
+			}
+		}
+	}
+
+	return teacherIDs, nil
+}
+
+// checkBatch - まとめた申請の変更を順に反映してみて、反映できない変更があれば BatchConflictError を返す
+// 反映した内容は取り消す（申請が登録済みのトランザクション内で実行）
+func (s *ChangeRequestService) checkBatch(tx *sql.Tx, requestID int, requestData json.RawMessage) error {
+	var data models.TimetableChangeData
+	if err := json.Unmarshal(requestData, &data); err != nil {
+		return fmt.Errorf("申請データが不正です: %v", err)
+	}
+	if data.RequestType != models.RequestTypeBatch {
+		return nil
+	}
+
+	if _, err := tx.Exec("SAVEPOINT batch_check"); err != nil {
+		return err
+	}
+	_, err := s.applyBatch(tx, requestID, &data)
+	if _, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT batch_check"); rollbackErr != nil {
+		return rollbackErr
+	}
+	return err
+}
+
+// applyBatch - まとめた申請の変更を順に反映する（1件でも反映できなければ BatchConflictError）
+// 前の変更を反映した後の時間割で次の変更を確認するため、変更同士の競合も検出できる
+func (s *ChangeRequestService) applyBatch(tx *sql.Tx, requestID int, data *models.TimetableChangeData) ([]models.ChangeRequestApplication, error) {
+	items, err := expandBatchItems(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	applications := []models.ChangeRequestApplication{}
+	results := make([]models.BatchItemResult, 0, len(items))
+	failed := false
+	for i, item := range items {
+		result := models.BatchItemResult{Index: i, SourceIndex: item.source, Item: item.data}
+
+		if _, err := tx.Exec("SAVEPOINT batch_item"); err != nil {
+			return nil, err
+		}
+		applied, err := s.applyChangeData(tx, requestID, &item.data)
+		if err != nil {
+			// この変更だけを取り消して、残りの変更も確認する
+			if _, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT batch_item"); rollbackErr != nil {
+				return nil, rollbackErr
+			}
+			var conflictErr *ConflictError
+			if errors.As(err, &conflictErr) {
+				result.HasConflicts = true
+				result.Conflicts = conflictErr.Conflicts
+			} else {
+				result.Error = err.Error()
+			}
+			failed = true
+		} else {
+			if _, err := tx.Exec("RELEASE SAVEPOINT batch_item"); err != nil {
+				return nil, err
+			}
+			applications = append(applications, applied...)
+		}
+
+		results = append(results, result)
+	}
+
+	if failed {
+		return nil, &BatchConflictError{Items: results}
+	}
+	return applications, nil
+}
+
+// mergeScopes - 各クラスの範囲に共通する範囲
+// 学年・学科がクラスごとに異なる場合はその項目を空にし、範囲を限定した権限では扱えないようにする
+func mergeScopes(scopes []*models.PermissionScope) *models.PermissionScope {
+	if len(scopes) == 0 {
+		return &models.PermissionScope{}
+	}
+	scope := *scopes[0]
+	for _, other := range scopes[1:] {
+		if !sameIntPtr(scope.Grade, other.Grade) {
+			scope.Grade = nil
+		}
+		if !sameIntPtr(scope.DepartmentID, other.DepartmentID) {
+			scope.DepartmentID = nil
+		}
+	}
+	return &scope
+}
+
+// batchKind - まとめた申請の分類（すべて同じ分類ならその分類、異なる場合は移動として扱う）
+func batchKind(data *models.TimetableChangeData) string {
+	kind := ""
+	for i := range data.Items {
+		k := requestKind(&data.Items[i])
+		if kind != "" && k != kind {
+			return models.RequestKindMove
+		}
+		kind = k
+	}
+	if kind == "" {
+		return models.RequestKindMove
+	}
+	return kind
+}
+
+func sameIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
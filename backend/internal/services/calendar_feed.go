@@ -0,0 +1,567 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// 配信する予定の時刻のタイムゾーン（時限の時刻は学校の時刻として扱う）
+const calendarTimeZone = "Asia/Tokyo"
+
+// 時刻をタイムゾーン込みで扱うため、Asia/Tokyo が読み込めない環境でも同じ時差にする
+var calendarLocation = func() *time.Location {
+	if loc, err := time.LoadLocation(calendarTimeZone); err == nil {
+		return loc
+	}
+	return time.FixedZone("JST", 9*60*60)
+}()
+
+type CalendarFeedService struct {
+	db               *sql.DB
+	timetableService *TimetableService
+}
+
+func NewCalendarFeedService(db *sql.DB, timetableService *TimetableService) *CalendarFeedService {
+	return &CalendarFeedService{db: db, timetableService: timetableService}
+}
+
+// GetBellSchedule - 時限の開始・終了時刻
+func (s *CalendarFeedService) GetBellSchedule() ([]models.BellPeriod, error) {
+	return loadBellSchedule(s.db)
+}
+
+// UpdateBellSchedule - 時限の開始・終了時刻を変更する（指定した時限のみ）
+func (s *CalendarFeedService) UpdateBellSchedule(req *models.UpdateBellScheduleRequest) ([]models.BellPeriod, error) {
+	if len(req.Periods) == 0 {
+		return nil, fmt.Errorf("変更する時限を指定してください")
+	}
+	for _, p := range req.Periods {
+		if p.Period < models.Period1 || p.Period > models.Period4 {
+			return nil, fmt.Errorf("時限が不正です: %d", p.Period)
+		}
+		start, err := time.Parse("15:04", p.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("%d限の開始時刻は HH:MM の形式で指定してください", p.Period)
+		}
+		end, err := time.Parse("15:04", p.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("%d限の終了時刻は HH:MM の形式で指定してください", p.Period)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("%d限の終了時刻は開始時刻より後にしてください", p.Period)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, p := range req.Periods {
+		_, err := tx.Exec(`
+			INSERT INTO bell_schedule (period, start_time, end_time)
+			VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE start_time = VALUES(start_time), end_time = VALUES(end_time)
+		`, p.Period, p.StartTime, p.EndTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return loadBellSchedule(s.db)
+}
+
+func loadBellSchedule(q queryer) ([]models.BellPeriod, error) {
+	rows, err := q.Query("SELECT period, TIME_FORMAT(start_time, '%H:%i'), TIME_FORMAT(end_time, '%H:%i') FROM bell_schedule ORDER BY period")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []models.BellPeriod{}
+	for rows.Next() {
+		var p models.BellPeriod
+		if err := rows.Scan(&p.Period, &p.StartTime, &p.EndTime); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// GetFeeds - ユーザーが作成した配信の一覧
+func (s *CalendarFeedService) GetFeeds(userID int) ([]models.CalendarFeed, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, scope, class_id, teacher_id, COALESCE(room, ''), name, token, last_accessed_at, created_at
+		FROM calendar_feeds
+		WHERE user_id = ?
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []models.CalendarFeed{}
+	for rows.Next() {
+		f, err := scanCalendarFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, *f)
+	}
+	return feeds, rows.Err()
+}
+
+func scanCalendarFeed(scanner interface{ Scan(...interface{}) error }) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	var classID, teacherID sql.NullInt64
+	var lastAccessedAt sql.NullTime
+	err := scanner.Scan(&f.ID, &f.UserID, &f.Scope, &classID, &teacherID, &f.Room, &f.Name, &f.Token, &lastAccessedAt, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	f.ClassID = nullIntPtr(classID)
+	f.TeacherID = nullIntPtr(teacherID)
+	if lastAccessedAt.Valid {
+		f.LastAccessedAt = &lastAccessedAt.Time
+	}
+	return &f, nil
+}
+
+// CreateFeed - クラス・教員・教室のいずれかの配信を作成し、トークンを発行する
+func (s *CalendarFeedService) CreateFeed(userID int, req *models.CreateCalendarFeedRequest) (*models.CalendarFeed, error) {
+	feed := &models.CalendarFeed{UserID: userID, Scope: req.Scope}
+
+	switch req.Scope {
+	case models.CalendarScopeClass:
+		if req.ClassID == nil {
+			return nil, fmt.Errorf("クラスを指定してください")
+		}
+		var grade int
+		var className string
+		err := s.db.QueryRow("SELECT grade, class_name FROM classes WHERE id = ?", *req.ClassID).Scan(&grade, &className)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("クラスが見つかりません")
+			}
+			return nil, err
+		}
+		feed.ClassID = req.ClassID
+		feed.Name = fmt.Sprintf("%d-%s", grade, className)
+
+	case models.CalendarScopeTeacher:
+		if req.TeacherID == nil {
+			return nil, fmt.Errorf("教員を指定してください")
+		}
+		err := s.db.QueryRow("SELECT name FROM users WHERE id = ? AND role IN ('teacher', 'admin')", *req.TeacherID).Scan(&feed.Name)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("教員が見つかりません")
+			}
+			return nil, err
+		}
+		feed.TeacherID = req.TeacherID
+
+	case models.CalendarScopeRoom:
+		if strings.TrimSpace(req.Room) == "" {
+			return nil, fmt.Errorf("教室を指定してください")
+		}
+		room, err := resolveRoomName(s.db, req.Room)
+		if err != nil {
+			return nil, err
+		}
+		feed.Room = room
+		feed.Name = room
+
+	default:
+		return nil, fmt.Errorf("配信の対象が不正です: %s", req.Scope)
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	feed.Token = token
+
+	result, err := s.db.Exec(`
+		INSERT INTO calendar_feeds (user_id, scope, class_id, teacher_id, room, name, token)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, feed.UserID, feed.Scope, feed.ClassID, feed.TeacherID, nullableString(feed.Room), feed.Name, feed.Token)
+	if err != nil {
+		return nil, fmt.Errorf("配信の作成に失敗しました: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	feed.ID = int(id)
+	feed.CreatedAt = time.Now()
+	return feed, nil
+}
+
+// DeleteFeed - 配信を削除する（トークンは使えなくなる。本人の配信でなければ sql.ErrNoRows）
+func (s *CalendarFeedService) DeleteFeed(userID, id int) error {
+	result, err := s.db.Exec("DELETE FROM calendar_feeds WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetFeedByToken - トークンの配信（見つからなければ sql.ErrNoRows）と、最後に取得された日時の記録
+func (s *CalendarFeedService) GetFeedByToken(token string) (*models.CalendarFeed, error) {
+	feed, err := scanCalendarFeed(s.db.QueryRow(`
+		SELECT id, user_id, scope, class_id, teacher_id, COALESCE(room, ''), name, token, last_accessed_at, created_at
+		FROM calendar_feeds
+		WHERE token = ?
+	`, token))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec("UPDATE calendar_feeds SET last_accessed_at = ? WHERE id = ?", time.Now(), feed.ID); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// GenerateICS - 配信の対象の今年度の時間割を iCalendar にする
+// 週間時間割の授業は毎週繰り返す予定にし、日付指定の変更は繰り返しの例外（休講・移動元は除外、代講・教室変更は
+// その回だけの内容）と、対象に加わる授業（移動先・代講する授業・変更後の教室の授業）の単発の予定にする
+func (s *CalendarFeedService) GenerateICS(feed *models.CalendarFeed, now time.Time) ([]byte, error) {
+	bells, err := loadBellSchedule(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	timetables, err := s.timetableService.GetTimetables(models.TimetableFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	// 今年度の変更を反映した授業のうち、通常どおりでないもの（配信の対象に関わる変更だけから求める）
+	_, from, to := academicYearRange(now)
+	overrides, err := loadFeedOverrides(s.db, feed, from, to)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := loadChangedLessons(s.db, timetables, overrides, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderICS(feed, bells, timetables, changed, now), nil
+}
+
+// loadFeedOverrides - 期間内の日付指定の変更のうち、配信の対象に関わる授業の変更（登録順）
+// 対象の授業に加え、代講・教室変更で対象に加わる授業も含め、同じ授業の変更は重ねる順で結果が変わるためすべて読む
+func loadFeedOverrides(q queryer, feed *models.CalendarFeed, from, to time.Time) ([]models.TimetableOverride, error) {
+	var lessons string
+	var args []interface{}
+	switch {
+	case feed.Scope == models.CalendarScopeClass && feed.ClassID != nil:
+		lessons = "SELECT id FROM timetables WHERE class_id = ?"
+		args = []interface{}{*feed.ClassID}
+	case feed.Scope == models.CalendarScopeTeacher && feed.TeacherID != nil:
+		lessons = `SELECT id FROM timetables WHERE teacher_id = ?
+			UNION SELECT timetable_id FROM timetable_teachers WHERE teacher_id = ?
+			UNION SELECT timetable_id FROM timetable_overrides WHERE new_teacher_id = ?`
+		args = []interface{}{*feed.TeacherID, *feed.TeacherID, *feed.TeacherID}
+	case feed.Scope == models.CalendarScopeRoom:
+		lessons = `SELECT id FROM timetables WHERE room = ?
+			UNION SELECT timetable_id FROM timetable_overrides WHERE new_room = ?`
+		args = []interface{}{feed.Room, feed.Room}
+	default:
+		return []models.TimetableOverride{}, nil
+	}
+
+	args = append(args, from.Format(dateLayout), to.Format(dateLayout), from.Format(dateLayout), to.Format(dateLayout))
+	rows, err := q.Query(`
+		SELECT `+overrideColumns+`
+		FROM timetable_overrides
+		WHERE timetable_id IN (`+lessons+`)
+		  AND (date BETWEEN ? AND ? OR new_date BETWEEN ? AND ?)
+		ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	return scanOverrides(rows)
+}
+
+// academicYearRange - now が属する年度と、その初日・最終日
+func academicYearRange(now time.Time) (int, time.Time, time.Time) {
+	year := models.AcademicYearOf(now)
+	from := time.Date(year, time.April, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(year+1, time.March, 31, 0, 0, 0, 0, time.Local)
+	return year, from, to
+}
+
+// renderICS - 週間時間割と今年度の変更（通常どおりでない授業）から、配信の対象の iCalendar を組み立てる
+func renderICS(feed *models.CalendarFeed, bells []models.BellPeriod, timetables []models.Timetable, changed []models.EffectiveLesson, now time.Time) []byte {
+	bellByPeriod := make(map[int]models.BellPeriod, len(bells))
+	for _, b := range bells {
+		bellByPeriod[b.Period] = b
+	}
+	year, from, to := academicYearRange(now)
+
+	cal := newICalendar(feed.Name + " の時間割")
+	stamp := now.UTC().Format("20060102T150405Z")
+	until := time.Date(to.Year(), to.Month(), to.Day(), 23, 59, 59, 0, calendarLocation).UTC().Format("20060102T150405Z")
+
+	// 週間時間割の授業ごとの繰り返しの予定と、その例外
+	series := make(map[int]*icsSeries)
+	for i := range timetables {
+		t := &timetables[i]
+		if !feedIncludes(feed, t) {
+			continue
+		}
+		bell, ok := bellByPeriod[t.Period]
+		if !ok {
+			continue
+		}
+		first := from
+		for i := 0; i < 7 && weekdayName(first) != t.DayOfWeek; i++ {
+			first = first.AddDate(0, 0, 1)
+		}
+		if weekdayName(first) != t.DayOfWeek {
+			continue
+		}
+		series[t.ID] = &icsSeries{
+			uid:       fmt.Sprintf("timetable-%d-%d@kosen-schedule-system", t.ID, year),
+			timetable: t,
+			bell:      bell,
+			first:     first,
+		}
+	}
+
+	var singles []models.EffectiveLesson
+	for _, lesson := range changed {
+		base := series[lesson.ID]
+		included := feedIncludes(feed, &lesson.Timetable)
+
+		switch lesson.Status {
+		case models.LessonCanceled, models.LessonMovedOut:
+			if base != nil {
+				base.exdates = append(base.exdates, lesson.Date)
+			}
+		case models.LessonMovedIn:
+			if included {
+				singles = append(singles, lesson)
+			}
+		default:
+			switch {
+			case base != nil && included:
+				base.instances = append(base.instances, lesson)
+			case base != nil:
+				base.exdates = append(base.exdates, lesson.Date)
+			case included:
+				singles = append(singles, lesson)
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(series))
+	for id := range series {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		sr := series[id]
+		t := sr.timetable
+
+		cal.begin("VEVENT")
+		cal.prop("UID", sr.uid)
+		cal.prop("DTSTAMP", stamp)
+		cal.prop("DTSTART;TZID="+calendarTimeZone, icsLocalTime(sr.first, sr.bell.StartTime))
+		cal.prop("DTEND;TZID="+calendarTimeZone, icsLocalTime(sr.first, sr.bell.EndTime))
+		cal.prop("RRULE", "FREQ=WEEKLY;UNTIL="+until)
+		for _, date := range sr.exdates {
+			if d, err := time.Parse(dateLayout, date); err == nil {
+				cal.prop("EXDATE;TZID="+calendarTimeZone, icsLocalTime(d, sr.bell.StartTime))
+			}
+		}
+		writeLessonProps(cal, t, "")
+		cal.end("VEVENT")
+
+		// 代講・教室変更の回（繰り返しの中のその回だけの内容）
+		for i := range sr.instances {
+			lesson := &sr.instances[i]
+			d, err := time.Parse(dateLayout, lesson.Date)
+			if err != nil {
+				continue
+			}
+			cal.begin("VEVENT")
+			cal.prop("UID", sr.uid)
+			cal.prop("DTSTAMP", stamp)
+			cal.prop("RECURRENCE-ID;TZID="+calendarTimeZone, icsLocalTime(d, sr.bell.StartTime))
+			cal.prop("DTSTART;TZID="+calendarTimeZone, icsLocalTime(d, sr.bell.StartTime))
+			cal.prop("DTEND;TZID="+calendarTimeZone, icsLocalTime(d, sr.bell.EndTime))
+			writeLessonProps(cal, &lesson.Timetable, lessonChangeLabel(lesson))
+			cal.end("VEVENT")
+		}
+	}
+
+	// 対象に加わる授業（移動先・代講・変更後の教室）の単発の予定
+	for i := range singles {
+		lesson := &singles[i]
+		bell, ok := bellByPeriod[lesson.Period]
+		if !ok {
+			continue
+		}
+		d, err := time.Parse(dateLayout, lesson.Date)
+		if err != nil {
+			continue
+		}
+		overrideIDs := make([]string, len(lesson.OverrideIDs))
+		for j, id := range lesson.OverrideIDs {
+			overrideIDs[j] = fmt.Sprint(id)
+		}
+
+		cal.begin("VEVENT")
+		cal.prop("UID", fmt.Sprintf("override-%s-%s-%d@kosen-schedule-system", strings.Join(overrideIDs, "-"), lesson.Date, lesson.ID))
+		cal.prop("DTSTAMP", stamp)
+		cal.prop("DTSTART;TZID="+calendarTimeZone, icsLocalTime(d, bell.StartTime))
+		cal.prop("DTEND;TZID="+calendarTimeZone, icsLocalTime(d, bell.EndTime))
+		writeLessonProps(cal, &lesson.Timetable, lessonChangeLabel(lesson))
+		cal.end("VEVENT")
+	}
+
+	return cal.bytes()
+}
+
+// icsSeries - 週間時間割の1コマの繰り返しの予定
+type icsSeries struct {
+	uid       string
+	timetable *models.Timetable
+	bell      models.BellPeriod
+	first     time.Time
+	exdates   []string
+	instances []models.EffectiveLesson
+}
+
+// feedIncludes - 授業が配信の対象か（教員は共同担当・代講を含む）
+func feedIncludes(feed *models.CalendarFeed, t *models.Timetable) bool {
+	switch feed.Scope {
+	case models.CalendarScopeClass:
+		return feed.ClassID != nil && t.ClassID == *feed.ClassID
+	case models.CalendarScopeTeacher:
+		return feed.TeacherID != nil && teachesLesson(t, *feed.TeacherID)
+	case models.CalendarScopeRoom:
+		return t.Room == feed.Room
+	}
+	return false
+}
+
+// lessonChangeLabel - 予定の件名に付ける変更の種類
+func lessonChangeLabel(lesson *models.EffectiveLesson) string {
+	switch lesson.Status {
+	case models.LessonMovedIn:
+		return "移動"
+	case models.LessonSubstituted:
+		return "代講"
+	case models.LessonRoomChanged:
+		return "教室変更"
+	}
+	return ""
+}
+
+// writeLessonProps - 授業の件名・場所・説明（label があれば件名の先頭に付ける）
+func writeLessonProps(cal *icalendar, t *models.Timetable, label string) {
+	summary := t.SubjectName
+	if t.GroupName != "" {
+		summary += "（" + t.GroupName + "）"
+	}
+	if label != "" {
+		summary = "【" + label + "】" + summary
+	}
+
+	cal.prop("SUMMARY", icsEscape(summary))
+	if t.Room != "" {
+		cal.prop("LOCATION", icsEscape(t.Room))
+	}
+	cal.prop("DESCRIPTION", icsEscape(fmt.Sprintf("%d-%s %d限\n担当：%s", t.Grade, t.ClassName, t.Period, t.TeacherName)))
+}
+
+// icsLocalTime - 日付と時刻（"08:50"）を TZID 付きの日時の値にする
+func icsLocalTime(date time.Time, clock string) string {
+	return date.Format("20060102") + "T" + strings.ReplaceAll(clock, ":", "") + "00"
+}
+
+// icalendar - iCalendar（RFC 5545）の本文を組み立てる
+type icalendar struct {
+	b strings.Builder
+}
+
+func newICalendar(name string) *icalendar {
+	cal := &icalendar{}
+	cal.begin("VCALENDAR")
+	cal.prop("VERSION", "2.0")
+	cal.prop("PRODID", "-//kosen-schedule-system//timetable//JA")
+	cal.prop("CALSCALE", "GREGORIAN")
+	cal.prop("METHOD", "PUBLISH")
+	cal.prop("X-WR-CALNAME", icsEscape(name))
+	cal.prop("X-WR-TIMEZONE", calendarTimeZone)
+	cal.prop("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	cal.prop("X-PUBLISHED-TTL", "PT1H")
+
+	// 日本標準時（夏時間なし）
+	cal.begin("VTIMEZONE")
+	cal.prop("TZID", calendarTimeZone)
+	cal.begin("STANDARD")
+	cal.prop("DTSTART", "19700101T000000")
+	cal.prop("TZOFFSETFROM", "+0900")
+	cal.prop("TZOFFSETTO", "+0900")
+	cal.prop("TZNAME", "JST")
+	cal.end("STANDARD")
+	cal.end("VTIMEZONE")
+	return cal
+}
+
+func (c *icalendar) begin(component string) { c.prop("BEGIN", component) }
+func (c *icalendar) end(component string)   { c.prop("END", component) }
+
+// prop - 1行を書く（75 オクテットを超える行は折り返す）
+func (c *icalendar) prop(name, value string) {
+	line := name + ":" + value
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			c.b.WriteString("\r\n ")
+			width = 1
+		}
+		c.b.WriteRune(r)
+		width += size
+	}
+	c.b.WriteString("\r\n")
+}
+
+func (c *icalendar) bytes() []byte {
+	c.end("VCALENDAR")
+	return []byte(c.b.String())
+}
+
+// icsEscape - TEXT の値のエスケープ
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"kosen-schedule-system/internal/models"
+)
+
+var updateGolden = flag.Bool("update", false, "testdata の期待する出力を書き換える")
+
+func TestICalendarPropFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ascii", strings.Repeat("a", 200)},
+		{"japanese", strings.Repeat("情報工学実験", 20)},
+		{"mixed", "1-1 " + strings.Repeat("数学Ⅰa", 30)},
+		{"short", "数学"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := &icalendar{}
+			cal.prop("SUMMARY", tt.value)
+			out := cal.b.String()
+
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("line should end with CRLF: %q", out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets: %q", i, len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a character: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d should start with a space: %q", i, line)
+				}
+			}
+
+			// 折り返しを戻すと元の行になる
+			unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", "")
+			if unfolded != "SUMMARY:"+tt.value {
+				t.Errorf("unfolded = %q, want %q", unfolded, "SUMMARY:"+tt.value)
+			}
+		})
+	}
+}
+
+func TestICSEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"数学", "数学"},
+		{"A,B", `A\,B`},
+		{"A;B", `A\;B`},
+		{`C:\path`, `C:\\path`},
+		{"1行目\n2行目", `1行目\n2行目`},
+		{"1行目\r\n2行目", `1行目\n2行目`},
+		{`\,;`, `\\\,\;`},
+	}
+
+	for _, tt := range tests {
+		if got := icsEscape(tt.in); got != tt.want {
+			t.Errorf("icsEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderICS(t *testing.T) {
+	bells := []models.BellPeriod{
+		{Period: 1, StartTime: "08:50", EndTime: "10:20"},
+		{Period: 2, StartTime: "10:30", EndTime: "12:00"},
+		{Period: 3, StartTime: "12:50", EndTime: "14:20"},
+		{Period: 4, StartTime: "14:30", EndTime: "16:00"},
+	}
+	math := models.Timetable{
+		ID: 10, ClassID: 1, TeacherID: 5, DayOfWeek: "monday", Period: 1, Room: "1-1HR",
+		ClassName: "1", Grade: 1, SubjectName: "数学Ⅰ", TeacherName: "山田",
+	}
+	lab := models.Timetable{
+		ID: 11, ClassID: 1, TeacherID: 6, DayOfWeek: "tuesday", Period: 2, Room: "実験室A,B",
+		ClassName: "1", Grade: 1, GroupName: "A班", SubjectName: "情報工学実験（電子回路・プログラミング・ネットワーク演習）",
+		TeacherName: "佐藤",
+	}
+	other := models.Timetable{
+		ID: 20, ClassID: 2, TeacherID: 7, DayOfWeek: "monday", Period: 1, Room: "1-2HR",
+		ClassName: "2", Grade: 1, SubjectName: "英語", TeacherName: "鈴木",
+	}
+
+	substituted := lab
+	substituted.TeacherID = 8
+	substituted.TeacherName = "高橋"
+	movedIn := math
+	movedIn.Period = 3
+	otherRoom := other
+	otherRoom.Room = "視聴覚室"
+
+	changed := []models.EffectiveLesson{
+		// 休講と移動元は繰り返しから除く
+		{Timetable: math, Date: "2026-04-13", Status: models.LessonCanceled, OverrideIDs: []int{1}},
+		{Timetable: math, Date: "2026-04-20", Status: models.LessonMovedOut, OverrideIDs: []int{2}},
+		// 移動先は単発の予定
+		{Timetable: movedIn, Date: "2026-04-22", Status: models.LessonMovedIn, OverrideIDs: []int{2}, OriginalDate: "2026-04-20", OriginalPeriod: 1},
+		// 代講はその回だけの内容
+		{Timetable: substituted, Date: "2026-04-14", Status: models.LessonSubstituted, OverrideIDs: []int{3}},
+		// 対象外のクラスの変更は含まない
+		{Timetable: otherRoom, Date: "2026-04-13", Status: models.LessonRoomChanged, OverrideIDs: []int{4}},
+	}
+
+	classID := 1
+	feed := &models.CalendarFeed{Scope: models.CalendarScopeClass, ClassID: &classID, Name: "1-1"}
+	now := time.Date(2026, time.May, 1, 9, 0, 0, 0, calendarLocation)
+
+	got := renderICS(feed, bells, []models.Timetable{math, lab, other}, changed, now)
+	compareGolden(t, "calendar_feed_class.ics", got)
+
+	// 代講の教員の配信では、代講する回を単発の予定にし、元の担当教員の配信ではその回を除く
+	teacherID := 8
+	feed = &models.CalendarFeed{Scope: models.CalendarScopeTeacher, TeacherID: &teacherID, Name: "高橋"}
+	got = renderICS(feed, bells, []models.Timetable{math, lab, other}, changed, now)
+	compareGolden(t, "calendar_feed_substitute.ics", got)
+
+	teacherID = 6
+	feed = &models.CalendarFeed{Scope: models.CalendarScopeTeacher, TeacherID: &teacherID, Name: "佐藤"}
+	got = renderICS(feed, bells, []models.Timetable{math, lab, other}, changed, now)
+	compareGolden(t, "calendar_feed_teacher.ics", got)
+}
+
+// compareGolden - testdata の期待する出力と比べる（-update で書き換える）
+func compareGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestLoadChangedLessons(t *testing.T) {
+	math := models.Timetable{ID: 10, ClassID: 1, TeacherID: 5, DayOfWeek: "monday", Period: 1, Room: "1-1HR"}
+	period3 := 3
+	overrides := []models.TimetableOverride{
+		{ID: 1, TimetableID: 10, Type: models.OverrideCancel, Date: "2026-04-13"},
+		{ID: 2, TimetableID: 10, Type: models.OverrideMove, Date: "2026-04-20", NewDate: "2026-04-22", NewPeriod: &period3},
+		{ID: 3, TimetableID: 10, Type: models.OverrideRoomChange, Date: "2026-04-27", NewRoom: "視聴覚室"},
+		// 曜日の違う日・期間外・週間時間割にない授業の変更は反映しない
+		{ID: 4, TimetableID: 10, Type: models.OverrideRoomChange, Date: "2026-04-28", NewRoom: "視聴覚室"},
+		{ID: 5, TimetableID: 10, Type: models.OverrideCancel, Date: "2027-04-05"},
+		{ID: 6, TimetableID: 99, Type: models.OverrideCancel, Date: "2026-04-13"},
+	}
+
+	from := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(2027, time.March, 31, 0, 0, 0, 0, time.Local)
+	changed, err := loadChangedLessons(nil, []models.Timetable{math}, overrides, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		date   string
+		status string
+		period int
+		room   string
+	}{
+		{"2026-04-13", models.LessonCanceled, 1, "1-1HR"},
+		{"2026-04-20", models.LessonMovedOut, 1, "1-1HR"},
+		{"2026-04-22", models.LessonMovedIn, 3, "1-1HR"},
+		{"2026-04-27", models.LessonRoomChanged, 1, "視聴覚室"},
+	}
+	if len(changed) != len(want) {
+		t.Fatalf("changed = %+v", changed)
+	}
+	for i, w := range want {
+		got := changed[i]
+		if got.Date != w.date || got.Status != w.status || got.Period != w.period || got.Room != w.room {
+			t.Errorf("changed[%d] = %s %s %d限 %s, want %s %s %d限 %s", i, got.Date, got.Status, got.Period, got.Room, w.date, w.status, w.period, w.room)
+		}
+	}
+}
@@ -1,205 +1,873 @@
 package services
 
 import (
-	"database/sql" // この行を確認
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"kosen-schedule-system/internal/models"
+	"strings"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 )
 
 type ChangeRequestService struct {
-	db *sql.DB
+	db                *sql.DB
+	permissionService *PermissionService
+	conflictService   *ConflictService
+	broker            *EventBroker
 }
 
 func NewChangeRequestService(db *sql.DB) *ChangeRequestService {
-	return &ChangeRequestService{db: db}
+	return &ChangeRequestService{
+		db:                db,
+		permissionService: NewPermissionService(db),
+		conflictService:   NewConflictService(db),
+	}
+}
+
+// SetEventBroker - 時間割へ反映・取り消しした変更を配信する先を設定する（設定しなければ配信しない）
+func (s *ChangeRequestService) SetEventBroker(broker *EventBroker) {
+	s.broker = broker
+}
+
+// ErrNotRequester - 申請者本人以外が申請を変更しようとした
+var ErrNotRequester = errors.New("申請者本人のみ操作できます")
+
+func changeRequestSelect() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"cr.id", "cr.requester_id", "cr.title", "cr.description", "cr.status", "cr.version", "cr.request_data",
+		"cr.effective_date", "cr.decision_deadline",
+		"cr.approver_id", "cr.approved_at", "COALESCE(cr.approver_comment, '')",
+		"cr.created_at", "cr.updated_at",
+		"u.name as requester_name", "u.email as requester_email",
+		"a.name as approver_name", "a.email as approver_email",
+	).
+		From("change_requests cr").
+		LeftJoin("users u ON cr.requester_id = u.id").
+		LeftJoin("users a ON cr.approver_id = a.id").
+		PlaceholderFormat(squirrel.Question)
+}
+
+func scanChangeRequest(scanner interface{ Scan(...interface{}) error }) (*models.ChangeRequest, error) {
+	var request models.ChangeRequest
+	var requestDataJSON string
+	var approverID sql.NullInt64
+	var approvedAt, effectiveDate, decisionDeadline sql.NullTime
+	var requesterName, requesterEmail, approverName, approverEmail sql.NullString
+
+	err := scanner.Scan(
+		&request.ID, &request.RequesterID, &request.Title, &request.Description, &request.Status, &request.Version, &requestDataJSON,
+		&effectiveDate, &decisionDeadline,
+		&approverID, &approvedAt, &request.Comment,
+		&request.CreatedAt, &request.UpdatedAt,
+		&requesterName, &requesterEmail, &approverName, &approverEmail,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	request.RequestData = json.RawMessage(requestDataJSON)
+	request.ApproverID = nullIntPtr(approverID)
+	if approvedAt.Valid {
+		request.ApprovedAt = &approvedAt.Time
+	}
+	if effectiveDate.Valid {
+		request.EffectiveDate = effectiveDate.Time.Format(dateLayout)
+	}
+	if decisionDeadline.Valid {
+		request.DecisionDeadline = &decisionDeadline.Time
+	}
+
+	// リレーション設定
+	if requesterName.Valid {
+		request.Requester = &models.User{ID: request.RequesterID, Name: requesterName.String, Email: requesterEmail.String}
+	}
+	if approverName.Valid && request.ApproverID != nil {
+		request.Approver = &models.User{ID: *request.ApproverID, Name: approverName.String, Email: approverEmail.String}
+	}
+
+	return &request, nil
 }
 
-// CreateChangeRequest - 変更申請作成
-func (s *ChangeRequestService) CreateChangeRequest(request *models.ChangeRequest) error {
+// CreateChangeRequest - 変更申請作成（下書きでなければそのまま提出し、相手教員の同意が必要な申請は同意待ちになる）
+func (s *ChangeRequestService) CreateChangeRequest(userID int, req *models.CreateChangeRequestRequest) (*models.ChangeRequest, error) {
+	if req.Title == "" || req.RequestData == nil {
+		return nil, fmt.Errorf("件名と申請内容を入力してください")
+	}
+
 	// request_dataをJSONに変換
-	requestDataJSON, err := json.Marshal(request.RequestData)
+	requestData, err := json.Marshal(req.RequestData)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	scope, err := s.requestScope(requestData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.permissionService.RequirePermission(userID, models.PermissionRequestCreate, scope); err != nil {
+		return nil, err
+	}
+
+	status := models.StatusDraft
+	var consentTeachers []int
+	if !req.Draft {
+		consentTeachers, err = s.prepareRequestData(userID, requestData)
+		if err != nil {
+			return nil, err
+		}
+		status = submittedStatus(consentTeachers)
+	}
+
+	effectiveDate, deadline, err := requestSchedule(requestData, req.EffectiveDate, req.DecisionDeadline)
+	if err != nil {
+		return nil, err
 	}
 
 	query := squirrel.Insert("change_requests").
-		Columns("requester_id", "status", "request_data", "created_at", "updated_at").
-		Values(request.RequesterID, request.Status, string(requestDataJSON), time.Now(), time.Now()).
+		Columns("requester_id", "title", "description", "status", "request_data", "effective_date", "decision_deadline", "created_at", "updated_at").
+		Values(userID, req.Title, req.Description, status, string(requestData), nullableString(effectiveDate), nullableDeadline(deadline), time.Now(), time.Now()).
 		PlaceholderFormat(squirrel.Question)
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	result, err := s.db.Exec(sqlQuery, args...)
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(sqlQuery, args...)
+	if err != nil {
+		return nil, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	for _, teacherID := range consentTeachers {
+		if err := requestConsent(tx, int(id), teacherID); err != nil {
+			return nil, err
+		}
+	}
+	if !req.Draft {
+		if err := s.checkBatch(tx, int(id), requestData); err != nil {
+			return nil, err
+		}
+		if err := s.assignApprovalSteps(tx, int(id), requestData); err != nil {
+			return nil, err
+		}
+	}
+
+	err = recordEvent(tx, &models.ChangeRequestEvent{
+		ChangeRequestID: int(id),
+		ActorID:         &userID,
+		Type:            models.EventCreated,
+		ToStatus:        status,
+		After:           requestData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !req.Draft {
+		r := &lockedRequest{ID: int(id), RequesterID: userID, Status: status, RequestData: requestData}
+		if err := s.notifySubmitted(tx, r, status, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetChangeRequestByID(int(id))
+}
+
+// prepareRequestData - 申請内容を検証して既存の時間割とぶつからないか確認し、同意が必要な教員を返す
+// まとめた申請の競合は、申請を登録したトランザクション内で checkBatch により確認する
+func (s *ChangeRequestService) prepareRequestData(requesterID int, requestData json.RawMessage) ([]int, error) {
+	var data models.TimetableChangeData
+	if err := json.Unmarshal(requestData, &data); err != nil {
+		return nil, fmt.Errorf("申請データが不正です: %v", err)
+	}
+	if data.RequestType == models.RequestTypeBatch {
+		return prepareBatchItems(s.db, requesterID, &data)
+	}
+	if data.OriginalTimetableID == 0 && data.RequestType != models.RequestTypeSubstitute {
+		return nil, nil
+	}
+
+	if data.RequestType == models.RequestTypeSubstitute {
+		if err := validateSubstituteLessons(s.db, requesterID, &data); err != nil {
+			return nil, err
+		}
+	}
+	if data.RequestType == models.RequestTypeSwap {
+		if err := validateSwapLessons(s.db, requesterID, &data); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := s.conflictService.CheckChange(&data, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	if result.HasConflicts {
+		return nil, &ConflictError{Conflicts: result.Conflicts}
+	}
+
+	// 入れ替え相手・代講する教員の同意を得てから承認者に回す
+	return consentTeacherIDs(s.db, requesterID, &data)
+}
+
+// submittedStatus - 提出後の状態（同意が必要なら同意待ち、不要なら承認待ち）
+func submittedStatus(consentTeachers []int) string {
+	if len(consentTeachers) > 0 {
+		return models.StatusAwaitingConsent
 	}
+	return models.StatusPending
+}
 
-	request.ID = int(id)
+// resetConsents - 同意の依頼をやり直す（呼び出し側のトランザクション内で実行）
+func resetConsents(tx *sql.Tx, requestID int, consentTeachers []int) error {
+	if _, err := tx.Exec("DELETE FROM change_request_consents WHERE change_request_id = ?", requestID); err != nil {
+		return err
+	}
+	for _, teacherID := range consentTeachers {
+		if err := requestConsent(tx, requestID, teacherID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetChangeRequestByID - 変更申請詳細取得
-func (s *ChangeRequestService) GetChangeRequestByID(id int) (*models.ChangeRequest, error) {
-	query := squirrel.Select(
-		"cr.id", "cr.requester_id", "cr.status", "cr.request_data", 
-		"cr.created_at", "cr.updated_at",
-		"u.name as requester_name", "u.email as requester_email",
-	).
-		From("change_requests cr").
-		LeftJoin("users u ON cr.requester_id = u.id").
-		Where(squirrel.Eq{"cr.id": id}).
-		PlaceholderFormat(squirrel.Question)
+// SubmitChangeRequest - 下書きの申請を提出する（申請者本人のみ）
+func (s *ChangeRequestService) SubmitChangeRequest(id, userID int, version *int) (*models.ChangeRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	sqlQuery, args, err := query.ToSql()
+	r, err := lockChangeRequest(tx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if r.RequesterID != userID {
+		return nil, ErrNotRequester
+	}
+	if r.Status != models.StatusDraft {
+		return nil, &TransitionError{From: r.Status, To: models.StatusPending}
+	}
+
+	consentTeachers, err := s.prepareRequestData(userID, r.RequestData)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.transition(tx, submittedStatus(consentTeachers), userID, "", nil); err != nil {
+		return nil, err
+	}
+	if err := resetConsents(tx, id, consentTeachers); err != nil {
+		return nil, err
+	}
+	if err := s.checkBatch(tx, id, r.RequestData); err != nil {
+		return nil, err
+	}
+	if err := s.assignApprovalSteps(tx, id, r.RequestData); err != nil {
+		return nil, err
+	}
+	if err := s.notifySubmitted(tx, r, submittedStatus(consentTeachers), userID); err != nil {
+		return nil, err
+	}
 
-	var request models.ChangeRequest
-	var requester models.User
-	var requestDataJSON string
-	var requesterName, requesterEmail sql.NullString // 修正: sql.NullString を正しく参照
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	err = s.db.QueryRow(sqlQuery, args...).Scan(
-		&request.ID, &request.RequesterID, &request.Status, &requestDataJSON,
-		&request.CreatedAt, &request.UpdatedAt,
-		&requesterName, &requesterEmail,
-	)
+	return s.GetChangeRequestByID(id)
+}
+
+// CancelChangeRequest - 承認前の申請を取り下げる（申請者本人のみ）
+func (s *ChangeRequestService) CancelChangeRequest(id, userID int, version *int) (*models.ChangeRequest, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	// JSONをパース
-	err = json.Unmarshal([]byte(requestDataJSON), &request.RequestData)
+	r, err := lockChangeRequest(tx, id, version)
 	if err != nil {
 		return nil, err
 	}
+	if r.RequesterID != userID {
+		return nil, ErrNotRequester
+	}
+	if err := r.transition(tx, models.StatusCanceled, userID, "", nil); err != nil {
+		return nil, err
+	}
 
-	// リレーション設定
-	if requesterName.Valid {
-		requester.ID = request.RequesterID
-		requester.Name = requesterName.String
-		requester.Email = requesterEmail.String
-		request.Requester = &requester
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	return &request, nil
+	return s.GetChangeRequestByID(id)
 }
 
-// GetChangeRequests - 変更申請一覧取得
-func (s *ChangeRequestService) GetChangeRequests(filter models.RequestFilter) ([]models.ChangeRequest, error) {
-	query := squirrel.Select(
-		"cr.id", "cr.requester_id", "cr.status", "cr.request_data", 
-		"cr.created_at", "cr.updated_at",
-		"u.name as requester_name", "u.email as requester_email",
-	).
-		From("change_requests cr").
-		LeftJoin("users u ON cr.requester_id = u.id").
+// UpdateChangeRequest - 変更申請の修正（申請者本人のみ、承認前の申請のみ）
+// 提出済みの申請の内容を変更した場合は競合を確認し直し、相手教員の同意も取り直す
+func (s *ChangeRequestService) UpdateChangeRequest(id, userID int, req *models.UpdateChangeRequestRequest) (*models.ChangeRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := lockChangeRequest(tx, id, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if r.RequesterID != userID {
+		return nil, ErrNotRequester
+	}
+	if !editableStatuses[r.Status] {
+		return nil, ErrRequestNotEditable
+	}
+
+	query := squirrel.Update("change_requests").
+		Set("version", squirrel.Expr("version + 1")).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "version": r.Version}).
 		PlaceholderFormat(squirrel.Question)
+	event := &models.ChangeRequestEvent{
+		ChangeRequestID: id,
+		ActorID:         &userID,
+		Type:            models.EventEdit,
+	}
 
-	// フィルター条件を追加
-	if filter.Status != nil {
-		query = query.Where(squirrel.Eq{"cr.status": *filter.Status})
+	var edited []string
+	resubmitted := "" // 提出し直した場合の状態
+	if req.Title != "" {
+		query = query.Set("title", req.Title)
+		edited = append(edited, "件名")
 	}
-	if filter.RequesterID != nil {
-		query = query.Where(squirrel.Eq{"cr.requester_id": *filter.RequesterID})
+	if req.Description != "" {
+		query = query.Set("description", req.Description)
+		edited = append(edited, "説明")
 	}
-	if filter.DateFrom != nil {
-		query = query.Where(squirrel.GtOrEq{"cr.created_at": *filter.DateFrom})
+
+	if req.RequestData != nil {
+		requestData, err := json.Marshal(req.RequestData)
+		if err != nil {
+			return nil, err
+		}
+
+		scope, err := s.requestScope(requestData)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.permissionService.RequirePermission(userID, models.PermissionRequestCreate, scope); err != nil {
+			return nil, err
+		}
+		query = query.Set("request_data", string(requestData))
+		event.Before = r.RequestData
+		event.After = requestData
+		edited = append(edited, "申請内容")
+
+		// 下書きは提出時に確認する
+		if r.Status != models.StatusDraft {
+			consentTeachers, err := s.prepareRequestData(userID, requestData)
+			if err != nil {
+				return nil, err
+			}
+			// 内容が変わるため提出し直しとして扱う
+			status := submittedStatus(consentTeachers)
+			resubmitted = status
+			query = query.Set("status", status)
+			if status != r.Status {
+				event.FromStatus = r.Status
+				event.ToStatus = status
+			}
+			if err := resetConsents(tx, id, consentTeachers); err != nil {
+				return nil, err
+			}
+			if err := s.checkBatch(tx, id, requestData); err != nil {
+				return nil, err
+			}
+			// 承認の途中でも、内容が変われば最初の段階から承認し直す
+			if err := s.assignApprovalSteps(tx, id, requestData); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if req.EffectiveDate != "" || req.DecisionDeadline != nil {
+		edited = append(edited, "実施日・判断期限")
 	}
-	if filter.DateTo != nil {
-		query = query.Where(squirrel.LtOrEq{"cr.created_at": *filter.DateTo})
+	if req.RequestData != nil || req.EffectiveDate != "" || req.DecisionDeadline != nil {
+		requestData := r.RequestData
+		if event.After != nil {
+			requestData = event.After
+		}
+		effectiveDate, deadline, err := requestSchedule(requestData, req.EffectiveDate, req.DecisionDeadline)
+		if err != nil {
+			return nil, err
+		}
+		// 期限が変わるためリマインド・期限超過の通知をやり直す
+		query = query.
+			Set("effective_date", nullableString(effectiveDate)).
+			Set("decision_deadline", nullableDeadline(deadline)).
+			Set("reminded_at", nil).
+			Set("escalated_at", nil)
 	}
 
-	query = query.OrderBy("cr.created_at DESC")
+	if len(edited) == 0 {
+		return nil, fmt.Errorf("修正する内容を指定してください")
+	}
+	event.Comment = strings.Join(edited, "・") + "を修正しました"
 
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
 	}
+	if _, err := tx.Exec(sqlQuery, args...); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(tx, event); err != nil {
+		return nil, err
+	}
+	if resubmitted != "" {
+		if err := s.notifySubmitted(tx, &lockedRequest{ID: id, RequesterID: r.RequesterID, RequestData: event.After}, resubmitted, userID); err != nil {
+			return nil, err
+		}
+	}
 
-	rows, err := s.db.Query(sqlQuery, args...)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetChangeRequestByID(id)
+}
+
+// GetChangeRequestByID - 変更申請詳細取得
+func (s *ChangeRequestService) GetChangeRequestByID(id int) (*models.ChangeRequest, error) {
+	sqlQuery, args, err := changeRequestSelect().Where(squirrel.Eq{"cr.id": id}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := scanChangeRequest(s.db.QueryRow(sqlQuery, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Consents, err = s.GetConsents(id)
+	if err != nil {
+		return nil, err
+	}
+
+	request.ApprovalSteps, err = loadApprovalSteps(s.db, id)
 	if err != nil {
 		return nil, err
 	}
+
+	return request, nil
+}
+
+// GetChangeRequests - 変更申請一覧取得（requesterID が 0、status が空の場合は絞り込まない）と件数
+// 承認権限を持たないユーザーは自分の申請のみ、学年・学科を限定した承認権限のユーザーは自分の申請と範囲内の申請のみ取得する
+func (s *ChangeRequestService) GetChangeRequests(viewerID, requesterID int, status string, limit, offset int) ([]models.ChangeRequest, int, error) {
+	scopes, err := s.permissionService.PermissionScopes(viewerID, models.PermissionRequestApprove)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(scopes) == 0 {
+		requesterID = viewerID
+	}
+
+	where := squirrel.And{}
+	if requesterID != 0 {
+		where = append(where, squirrel.Eq{"cr.requester_id": requesterID})
+	}
+	if status != "" {
+		where = append(where, squirrel.Eq{"cr.status": status})
+	}
+
+	if requesterID == viewerID || hasGlobalScope(scopes) {
+		return s.queryChangeRequests(where, limit, offset)
+	}
+
+	// 範囲は申請データの対象クラスから求めるため、該当する申請をすべて読み込んでから絞り込む
+	all, _, err := s.queryChangeRequests(where, 0, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	visible := []models.ChangeRequest{}
+	for _, request := range all {
+		if request.RequesterID != viewerID {
+			scope, err := s.requestScope(request.RequestData)
+			if err != nil {
+				return nil, 0, err
+			}
+			if !anyScopeCovers(scopes, scope) {
+				continue
+			}
+		}
+		visible = append(visible, request)
+	}
+
+	total := len(visible)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return visible[offset:end], total, nil
+}
+
+// queryChangeRequests - 条件に合う申請を新しい順に取得（limit が 0 ならすべて）
+func (s *ChangeRequestService) queryChangeRequests(where squirrel.And, limit, offset int) ([]models.ChangeRequest, int, error) {
+	countQuery, countArgs, err := squirrel.Select("COUNT(*)").
+		From("change_requests cr").
+		Where(where).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := s.db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := changeRequestSelect().
+		Where(where).
+		OrderBy("cr.created_at DESC", "cr.id DESC")
+	if limit > 0 {
+		query = query.Limit(uint64(limit)).Offset(uint64(offset))
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer rows.Close()
 
-	var requests []models.ChangeRequest
+	requests := []models.ChangeRequest{}
 	for rows.Next() {
-		var request models.ChangeRequest
-		var requester models.User
-		var requestDataJSON string
-		var requesterName, requesterEmail sql.NullString // 修正: sql.NullString を正しく参照
-
-		err := rows.Scan(
-			&request.ID, &request.RequesterID, &request.Status, &requestDataJSON,
-			&request.CreatedAt, &request.UpdatedAt,
-			&requesterName, &requesterEmail,
-		)
+		request, err := scanChangeRequest(rows)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+		requests = append(requests, *request)
+	}
+
+	return requests, total, rows.Err()
+}
+
+// hasGlobalScope - 範囲を限定しない権限を含むか
+func hasGlobalScope(scopes []models.PermissionScope) bool {
+	for _, scope := range scopes {
+		if scope.IsGlobal() {
+			return true
+		}
+	}
+	return false
+}
+
+// anyScopeCovers - いずれかの範囲が target を含むか
+func anyScopeCovers(scopes []models.PermissionScope, target *models.PermissionScope) bool {
+	for _, scope := range scopes {
+		if scope.Covers(target) {
+			return true
 		}
+	}
+	return false
+}
+
+// ApproveChangeRequest - 変更申請承認
+// 承認段階がある申請は現在の段階を承認して次の段階へ進め、最後の段階の承認で時間割へ反映して反映済みにする
+// version を指定した場合、他の承認者が先に判断していれば ErrVersionConflict になる
+func (s *ChangeRequestService) ApproveChangeRequest(id, approverID int, comment string, version *int) (*models.ChangeRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := lockChangeRequest(tx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != models.StatusPending {
+		return nil, &TransitionError{From: r.Status, To: models.StatusApproved}
+	}
 
-		// JSONをパース
-		err = json.Unmarshal([]byte(requestDataJSON), &request.RequestData)
+	step, err := s.decideCurrentStep(tx, r, approverID, models.ApprovalStepApproved, comment)
+	if err != nil {
+		return nil, err
+	}
+	if step != nil {
+		steps, err := loadApprovalSteps(tx, id)
 		if err != nil {
 			return nil, err
 		}
-
-		// リレーション設定
-		if requesterName.Valid {
-			requester.ID = request.RequesterID
-			requester.Name = requesterName.String
-			requester.Email = requesterEmail.String
-			request.Requester = &requester
+		if currentApprovalStep(steps) != nil {
+			// 次の段階の承認待ち
+			if err := r.touch(tx); err != nil {
+				return nil, err
+			}
+			if err := s.notifySubmitted(tx, r, models.StatusPending, approverID); err != nil {
+				return nil, err
+			}
+			if err := tx.Commit(); err != nil {
+				return nil, err
+			}
+			return s.GetChangeRequestByID(id)
 		}
+	}
+
+	err = r.transition(tx, models.StatusApproved, approverID, comment, map[string]interface{}{
+		"approver_id":      approverID,
+		"approved_at":      time.Now(),
+		"approver_comment": comment,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		requests = append(requests, request)
+	applications, err := s.applyTimetableChange(tx, id, r.RequestData)
+	if err != nil {
+		return nil, err
 	}
 
-	return requests, nil
+	if err := r.transition(tx, models.StatusApplied, approverID, "", nil); err != nil {
+		return nil, err
+	}
+	if err := notifyDecision(tx, r, models.StatusApplied, approverID, comment); err != nil {
+		return nil, err
+	}
+	if err := notifyApplied(tx, r, applications, approverID); err != nil {
+		return nil, err
+	}
+	event, err := applicationEvent(tx, models.TimetableEventChanged, id, applications)
+	if err != nil {
+		return nil, err
+	}
+	if err := enqueueTimetableWebhook(tx, event); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.broker.Publish(event)
+
+	return s.GetChangeRequestByID(id)
 }
 
-// UpdateChangeRequestStatus - 変更申請ステータス更新
-func (s *ChangeRequestService) UpdateChangeRequestStatus(id int, status string) error {
-	query := squirrel.Update("change_requests").
-		Set("status", status).
-		Set("updated_at", time.Now()).
-		Where(squirrel.Eq{"id": id}).
-		PlaceholderFormat(squirrel.Question)
+// RejectChangeRequest - 変更申請却下（同意待ちの申請も却下できる、承認段階がある申請は現在の段階の承認者が却下する）
+func (s *ChangeRequestService) RejectChangeRequest(id, approverID int, comment string, version *int) (*models.ChangeRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	sqlQuery, args, err := query.ToSql()
+	r, err := lockChangeRequest(tx, id, version)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !CanTransition(r.Status, models.StatusRejected) {
+		return nil, &TransitionError{From: r.Status, To: models.StatusRejected}
+	}
+
+	if _, err := s.decideCurrentStep(tx, r, approverID, models.ApprovalStepRejected, comment); err != nil {
+		return nil, err
+	}
+
+	err = r.transition(tx, models.StatusRejected, approverID, comment, map[string]interface{}{
+		"approver_id":      approverID,
+		"approved_at":      time.Now(),
+		"approver_comment": comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := notifyDecision(tx, r, models.StatusRejected, approverID, comment); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	_, err = s.db.Exec(sqlQuery, args...)
-	return err
+	return s.GetChangeRequestByID(id)
 }
 
-// ApproveChangeRequest - 変更申請承認
-func (s *ChangeRequestService) ApproveChangeRequest(id int) error {
-	return s.UpdateChangeRequestStatus(id, "approved")
+// decideCurrentStep - 現在の承認段階を承認・却下する（判断した段階を返す）
+// 承認段階のない申請（ポリシーに該当しない申請）は、対象クラスの範囲で承認権限を持つか確認するだけで nil を返す
+func (s *ChangeRequestService) decideCurrentStep(tx *sql.Tx, r *lockedRequest, approverID int, status, comment string) (*models.ApprovalStep, error) {
+	scope, err := s.requestScope(r.RequestData)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := loadApprovalSteps(tx, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	step := currentApprovalStep(steps)
+	if step == nil {
+		return nil, s.permissionService.RequirePermission(approverID, models.PermissionRequestApprove, scope)
+	}
+
+	if err := s.checkStepApprover(step, steps, approverID, scope); err != nil {
+		return nil, err
+	}
+	if err := decideApprovalStep(tx, step, status, approverID, comment); err != nil {
+		return nil, err
+	}
+	return step, nil
 }
 
-// RejectChangeRequest - 変更申請却下
-func (s *ChangeRequestService) RejectChangeRequest(id int) error {
-	return s.UpdateChangeRequestStatus(id, "rejected")
+// RequestScope - 申請の対象クラスの学年・学科を求める（閲覧権限の確認用）
+func (s *ChangeRequestService) RequestScope(request *models.ChangeRequest) (*models.PermissionScope, error) {
+	return s.requestScope(request.RequestData)
 }
 
-// DeleteChangeRequest - 変更申請削除
+// requestScope - 申請データから対象クラスの学年・学科を求める
+// 対象のクラスが複数ある場合は共通する学年・学科だけを残し、範囲を限定した権限ではすべてのクラスを扱えるときだけ扱えるようにする
+// 特定できない場合は空の範囲を返し、学年・学科を限定した権限では扱えないようにする
+func (s *ChangeRequestService) requestScope(requestData json.RawMessage) (*models.PermissionScope, error) {
+	scopes, err := s.requestClassScopes(requestData)
+	if err != nil {
+		return nil, err
+	}
+	return mergeScopes(scopes), nil
+}
+
+// requestClassScopes - 申請の対象クラスごとの学年・学科（まとめた申請は各変更の対象クラス、入れ替え・代講申請は各授業のクラス）
+// 特定できないクラスは空の範囲にする
+func (s *ChangeRequestService) requestClassScopes(requestData json.RawMessage) ([]*models.PermissionScope, error) {
+	var data models.TimetableChangeData
+	if err := json.Unmarshal(requestData, &data); err != nil {
+		return []*models.PermissionScope{{}}, nil
+	}
+
+	if data.RequestType == models.RequestTypeBatch {
+		var scopes []*models.PermissionScope
+		for _, item := range data.Items {
+			itemData, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			itemScopes, err := s.requestClassScopes(itemData)
+			if err != nil {
+				return nil, err
+			}
+			scopes = append(scopes, itemScopes...)
+		}
+		if len(scopes) == 0 {
+			return []*models.PermissionScope{{}}, nil
+		}
+		return scopes, nil
+	}
+
+	classIDs, err := s.requestClassIDs(&data)
+	if err != nil {
+		return nil, err
+	}
+	scopes := make([]*models.PermissionScope, 0, len(classIDs))
+	for _, classID := range classIDs {
+		scope, err := s.classScope(classID)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// requestClassIDs - 申請の対象の授業のクラス（授業を指定しない申請は NewClassID。特定できない場合は 0）
+func (s *ChangeRequestService) requestClassIDs(data *models.TimetableChangeData) ([]int, error) {
+	var timetableIDs []int
+	if data.OriginalTimetableID != 0 {
+		timetableIDs = append(timetableIDs, data.OriginalTimetableID)
+	}
+	if data.RequestType == models.RequestTypeSwap && data.SwapTimetableID != 0 {
+		timetableIDs = append(timetableIDs, data.SwapTimetableID)
+	}
+	for _, sub := range data.Substitutions {
+		timetableIDs = append(timetableIDs, sub.TimetableID)
+	}
+	if len(timetableIDs) == 0 {
+		return []int{data.NewClassID}, nil
+	}
+
+	classIDs := make([]int, 0, len(timetableIDs))
+	for _, timetableID := range timetableIDs {
+		var classID int
+		err := s.db.QueryRow("SELECT class_id FROM timetables WHERE id = ?", timetableID).Scan(&classID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		classIDs = appendUniqueInt(classIDs, classID)
+	}
+	return classIDs, nil
+}
+
+// classScope - クラスの学年・学科（クラスが見つからない場合は空の範囲）
+func (s *ChangeRequestService) classScope(classID int) (*models.PermissionScope, error) {
+	scope := &models.PermissionScope{}
+	if classID == 0 {
+		return scope, nil
+	}
+
+	var grade int
+	var departmentID sql.NullInt64
+	err := s.db.QueryRow("SELECT grade, department_id FROM classes WHERE id = ?", classID).Scan(&grade, &departmentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return scope, nil
+		}
+		return nil, err
+	}
+	scope.Grade = &grade
+	scope.DepartmentID = nullIntPtr(departmentID)
+
+	return scope, nil
+}
+
+// DeleteChangeRequest - 変更申請削除（下書き・取り下げ済みの申請のみ。提出後の申請は履歴と反映の記録を残す）
 func (s *ChangeRequestService) DeleteChangeRequest(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := lockChangeRequest(tx, id, nil)
+	if err != nil {
+		return err
+	}
+	if r.Status != models.StatusDraft && r.Status != models.StatusCanceled {
+		return ErrRequestNotDeletable
+	}
+
 	query := squirrel.Delete("change_requests").
 		Where(squirrel.Eq{"id": id}).
 		PlaceholderFormat(squirrel.Question)
@@ -209,6 +877,8 @@ func (s *ChangeRequestService) DeleteChangeRequest(id int) error {
 		return err
 	}
 
-	_, err = s.db.Exec(sqlQuery, args...)
-	return err
+	if _, err := tx.Exec(sqlQuery, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+var (
+	// ErrConsentNotRequested - 同意を求められていない教員が回答しようとした
+	ErrConsentNotRequested = errors.New("この申請への同意は求められていません")
+	// ErrRequestNotAwaitingConsent - 同意待ち以外の申請に回答しようとした
+	ErrRequestNotAwaitingConsent = errors.New("同意待ちの申請ではありません")
+)
+
+// consentTeacherIDs - 申請に同意が必要な教員（入れ替える2コマの担当教員（共同担当を含む）、代講する教員。申請者本人は除く）
+func consentTeacherIDs(q queryer, requesterID int, data *models.TimetableChangeData) ([]int, error) {
+	var teacherIDs []int
+	switch data.RequestType {
+	case models.RequestTypeSwap:
+		if data.SwapTimetableID == 0 {
+			return nil, nil
+		}
+		for _, timetableID := range []int{data.OriginalTimetableID, data.SwapTimetableID} {
+			lesson, err := loadTimetableSnapshot(q, timetableID)
+			if err != nil {
+				if timetableID == data.SwapTimetableID {
+					return nil, fmt.Errorf("入れ替え相手の授業が見つかりません")
+				}
+				return nil, err
+			}
+			teacherIDs = append(teacherIDs, lessonTeachers(lesson)...)
+		}
+
+	case models.RequestTypeSubstitute:
+		for _, sub := range data.Substitutions {
+			teacherIDs = append(teacherIDs, sub.TeacherID)
+		}
+	}
+
+	seen := make(map[int]bool)
+	result := []int{}
+	for _, id := range teacherIDs {
+		if id == 0 || id == requesterID || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+
+	return result, nil
+}
+
+// GetConsents - 申請に対する同意の一覧
+func (s *ChangeRequestService) GetConsents(requestID int) ([]models.ChangeRequestConsent, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.change_request_id, c.teacher_id, COALESCE(u.name, ''), c.status,
+			COALESCE(c.comment, ''), c.responded_at, c.created_at
+		FROM change_request_consents c
+		LEFT JOIN users u ON c.teacher_id = u.id
+		WHERE c.change_request_id = ?
+		ORDER BY c.id
+	`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	consents := []models.ChangeRequestConsent{}
+	for rows.Next() {
+		var c models.ChangeRequestConsent
+		var respondedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.ChangeRequestID, &c.TeacherID, &c.TeacherName, &c.Status,
+			&c.Comment, &respondedAt, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if respondedAt.Valid {
+			c.RespondedAt = &respondedAt.Time
+		}
+		consents = append(consents, c)
+	}
+
+	return consents, rows.Err()
+}
+
+// GetPendingConsents - 教員が同意を求められている申請の一覧
+func (s *ChangeRequestService) GetPendingConsents(teacherID int) ([]models.ChangeRequestConsent, error) {
+	rows, err := s.db.Query(`
+		SELECT c.change_request_id
+		FROM change_request_consents c
+		JOIN change_requests cr ON c.change_request_id = cr.id
+		WHERE c.teacher_id = ? AND c.status = ? AND cr.status = ?
+		ORDER BY c.created_at
+	`, teacherID, models.ConsentPending, models.StatusAwaitingConsent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var requestIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		requestIDs = append(requestIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	consents := []models.ChangeRequestConsent{}
+	for _, id := range requestIDs {
+		request, err := s.GetChangeRequestByID(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range request.Consents {
+			if c.TeacherID == teacherID {
+				c.Request = request
+				consents = append(consents, c)
+			}
+		}
+	}
+
+	return consents, nil
+}
+
+// RespondConsent - 同意を求められた教員が申請に同意・不同意する
+// 全員が同意すると承認待ちになり、不同意の場合は申請を却下する
+func (s *ChangeRequestService) RespondConsent(requestID, teacherID int, req *models.RespondConsentRequest) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := lockChangeRequest(tx, requestID, nil)
+	if err != nil {
+		return err
+	}
+	if r.Status != models.StatusAwaitingConsent {
+		return ErrRequestNotAwaitingConsent
+	}
+
+	consentStatus := models.ConsentDeclined
+	if req.Accept {
+		consentStatus = models.ConsentAccepted
+	}
+
+	result, err := tx.Exec(`
+		UPDATE change_request_consents
+		SET status = ?, comment = ?, responded_at = ?
+		WHERE change_request_id = ? AND teacher_id = ? AND status = ?
+	`, consentStatus, req.Comment, time.Now(), requestID, teacherID, models.ConsentPending)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrConsentNotRequested
+	}
+
+	err = recordEvent(tx, &models.ChangeRequestEvent{
+		ChangeRequestID: requestID,
+		ActorID:         &teacherID,
+		Type:            models.EventConsent,
+		ToStatus:        consentStatus,
+		Comment:         req.Comment,
+	})
+	if err != nil {
+		return err
+	}
+
+	newStatus := models.StatusRejected
+	if req.Accept {
+		var remaining int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM change_request_consents
+			WHERE change_request_id = ? AND status <> ?
+		`, requestID, models.ConsentAccepted).Scan(&remaining)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return tx.Commit()
+		}
+		newStatus = models.StatusPending
+	}
+
+	if err := r.transition(tx, newStatus, teacherID, "", nil); err != nil {
+		return err
+	}
+	if newStatus == models.StatusPending {
+		err = s.notifySubmitted(tx, r, newStatus, teacherID)
+	} else {
+		comment := "相手の教員が同意しませんでした"
+		if req.Comment != "" {
+			comment += "（" + req.Comment + "）"
+		}
+		err = notifyDecision(tx, r, newStatus, teacherID, comment)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// requestConsent - 申請に必要な同意を登録し、同意待ちにする（呼び出し側のトランザクション内で実行）
+func requestConsent(tx *sql.Tx, requestID, teacherID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO change_request_consents (change_request_id, teacher_id, status)
+		VALUES (?, ?, ?)
+	`, requestID, teacherID, models.ConsentPending)
+	if err != nil {
+		return fmt.Errorf("同意依頼の登録に失敗しました: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// 判断期限の既定値（実施日の前日の何時か）
+const defaultDeadlineHour = 17
+
+// 判断を待っている申請の状態
+var undecidedStatuses = []string{models.StatusAwaitingConsent, models.StatusPending}
+
+// requestEffectiveDate - 申請内容のうち最も早い日付（日付指定のない週間時間割の変更は空）
+func requestEffectiveDate(data *models.TimetableChangeData) string {
+	dates := []string{data.Date, data.NewDate, data.SwapDate}
+	for _, sub := range data.Substitutions {
+		dates = append(dates, sub.Date)
+	}
+	if data.Recurrence != nil {
+		dates = append(dates, data.Recurrence.From)
+	}
+	for i := range data.Items {
+		dates = append(dates, requestEffectiveDate(&data.Items[i]))
+	}
+
+	earliest := ""
+	for _, d := range dates {
+		if d != "" && (earliest == "" || d < earliest) {
+			earliest = d
+		}
+	}
+	return earliest
+}
+
+// requestSchedule - 実施日と判断期限を決める（省略した場合は申請内容から求める）
+func requestSchedule(requestData json.RawMessage, effectiveDate string, deadline *time.Time) (string, *time.Time, error) {
+	if effectiveDate == "" {
+		var data models.TimetableChangeData
+		if err := json.Unmarshal(requestData, &data); err != nil {
+			return "", nil, fmt.Errorf("申請データが不正です: %v", err)
+		}
+		effectiveDate = requestEffectiveDate(&data)
+	}
+	if effectiveDate == "" {
+		return "", deadline, nil
+	}
+
+	effective, err := time.ParseInLocation(dateLayout, effectiveDate, time.Local)
+	if err != nil {
+		return "", nil, fmt.Errorf("実施日が不正です: %s", effectiveDate)
+	}
+	if deadline == nil {
+		d := time.Date(effective.Year(), effective.Month(), effective.Day()-1, defaultDeadlineHour, 0, 0, 0, time.Local)
+		deadline = &d
+	}
+	if deadline.After(effective) {
+		return "", nil, fmt.Errorf("判断期限は実施日より前にしてください")
+	}
+
+	return effectiveDate, deadline, nil
+}
+
+// nullableDeadline - 判断期限の DB 値
+func nullableDeadline(deadline *time.Time) interface{} {
+	if deadline == nil {
+		return nil
+	}
+	return *deadline
+}
+
+// approverUserIDs - 申請の次の判断者（承認段階のロールを対象範囲で持つユーザー、承認段階がなければ承認権限を持つユーザー）
+func (s *ChangeRequestService) approverUserIDs(q queryer, requestID int, requestData json.RawMessage) ([]int, error) {
+	scope, err := s.requestScope(requestData)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := loadApprovalSteps(q, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	if step := currentApprovalStep(steps); step != nil {
+		roles = []string{step.Role}
+	} else {
+		rows, err := q.Query("SELECT role FROM role_permissions WHERE permission = ?", models.PermissionRequestApprove)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var role string
+			if err := rows.Scan(&role); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			roles = append(roles, role)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return usersWithRoles(q, roles, scope)
+}
+
+// usersWithRoles - いずれかのロールを基本ロール、または scope を含む範囲の追加ロールとして持つユーザー
+func usersWithRoles(q queryer, roles []string, scope *models.PermissionScope) ([]int, error) {
+	if len(roles) == 0 {
+		return nil, nil
+	}
+
+	assigned := squirrel.And{squirrel.Eq{"role": roles}}
+	if scope != nil {
+		if scope.Grade != nil {
+			assigned = append(assigned, squirrel.Or{squirrel.Eq{"grade": nil}, squirrel.Eq{"grade": *scope.Grade}})
+		} else {
+			assigned = append(assigned, squirrel.Eq{"grade": nil})
+		}
+		if scope.DepartmentID != nil {
+			assigned = append(assigned, squirrel.Or{squirrel.Eq{"department_id": nil}, squirrel.Eq{"department_id": *scope.DepartmentID}})
+		} else {
+			assigned = append(assigned, squirrel.Eq{"department_id": nil})
+		}
+	}
+
+	baseSQL, baseArgs, err := squirrel.Select("id").
+		From("users").
+		Where(squirrel.Eq{"role": roles}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	assignedSQL, assignedArgs, err := squirrel.Select("user_id").
+		From("user_role_assignments").
+		Where(assigned).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	sqlStr := baseSQL + " UNION " + assignedSQL
+	args := append(baseArgs, assignedArgs...)
+
+	rows, err := q.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// RequestScheduler - 判断を待っている申請の期限を定期的に確認する
+// 実施日を過ぎた申請は期限切れにし、判断期限を過ぎた申請は管理者に通知し、判断期限が近い申請は判断者にリマインドする
+type RequestScheduler struct {
+	db                   *sql.DB
+	changeRequestService *ChangeRequestService
+	interval             time.Duration
+	remindBefore         time.Duration
+}
+
+func NewRequestScheduler(db *sql.DB, interval, remindBefore time.Duration) *RequestScheduler {
+	return &RequestScheduler{
+		db:                   db,
+		changeRequestService: NewChangeRequestService(db),
+		interval:             interval,
+		remindBefore:         remindBefore,
+	}
+}
+
+// Start - ctx が終了するまでバックグラウンドで定期的に確認する
+func (s *RequestScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunOnce(time.Now()); err != nil {
+				log.Printf("request scheduler: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce - now の時点で期限切れ・期限超過・リマインドの対象となる申請を処理する
+func (s *RequestScheduler) RunOnce(now time.Time) error {
+	if err := s.expireRequests(now); err != nil {
+		return fmt.Errorf("期限切れの処理に失敗しました: %v", err)
+	}
+	if err := s.escalateRequests(now); err != nil {
+		return fmt.Errorf("期限超過の通知に失敗しました: %v", err)
+	}
+	if err := s.remindRequests(now); err != nil {
+		return fmt.Errorf("リマインドに失敗しました: %v", err)
+	}
+	return nil
+}
+
+// expireRequests - 判断されないまま実施日を過ぎた申請を期限切れにし、申請者に通知する
+func (s *RequestScheduler) expireRequests(now time.Time) error {
+	ids, err := s.findRequests(squirrel.Lt{"effective_date": now.Format(dateLayout)})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := s.withLockedRequest(id, func(tx *sql.Tx, r *lockedRequest, title string) error {
+			if err := r.transition(tx, models.StatusExpired, 0, "判断されないまま実施日を過ぎたため期限切れになりました", nil); err != nil {
+				return err
+			}
+			return notifyUsers(tx, []int{r.RequesterID}, &models.Notification{
+				Title:           "申請が期限切れになりました",
+				Message:         fmt.Sprintf("申請「%s」は承認されないまま実施日を過ぎたため、期限切れになりました。", title),
+				Type:            models.NotificationWarning,
+				Event:           models.NotificationRequestExpired,
+				ChangeRequestID: &r.ID,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escalateRequests - 判断期限を過ぎた申請を管理者に通知する（1回のみ）
+func (s *RequestScheduler) escalateRequests(now time.Time) error {
+	ids, err := s.findRequests(squirrel.And{
+		squirrel.Lt{"decision_deadline": now},
+		squirrel.Eq{"escalated_at": nil},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := s.withLockedRequest(id, func(tx *sql.Tx, r *lockedRequest, title string) error {
+			if _, err := tx.Exec("UPDATE change_requests SET escalated_at = ? WHERE id = ?", now, r.ID); err != nil {
+				return err
+			}
+
+			admins, err := usersWithRoles(tx, []string{models.RoleAdmin}, nil)
+			if err != nil {
+				return err
+			}
+			err = recordEvent(tx, &models.ChangeRequestEvent{
+				ChangeRequestID: r.ID,
+				Type:            models.EventDeadline,
+				Comment:         "判断期限を過ぎたため管理者に通知しました",
+			})
+			if err != nil {
+				return err
+			}
+			return notifyUsers(tx, admins, &models.Notification{
+				Title:           "判断期限を過ぎた申請があります",
+				Message:         fmt.Sprintf("申請「%s」は判断期限を過ぎていますが、まだ%sです。", title, statusLabel(r.Status)),
+				Type:            models.NotificationWarning,
+				Event:           models.NotificationRequestOverdue,
+				ChangeRequestID: &r.ID,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remindRequests - 判断期限が近い申請を、承認待ちなら判断者に、同意待ちなら未回答の教員にリマインドする（1回のみ）
+func (s *RequestScheduler) remindRequests(now time.Time) error {
+	ids, err := s.findRequests(squirrel.And{
+		squirrel.GtOrEq{"decision_deadline": now},
+		squirrel.LtOrEq{"decision_deadline": now.Add(s.remindBefore)},
+		squirrel.Eq{"reminded_at": nil},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := s.withLockedRequest(id, func(tx *sql.Tx, r *lockedRequest, title string) error {
+			if _, err := tx.Exec("UPDATE change_requests SET reminded_at = ? WHERE id = ?", now, r.ID); err != nil {
+				return err
+			}
+
+			var recipients []int
+			var err error
+			if r.Status == models.StatusAwaitingConsent {
+				recipients, err = pendingConsentTeachers(tx, r.ID)
+			} else {
+				recipients, err = s.changeRequestService.approverUserIDs(tx, r.ID, r.RequestData)
+			}
+			if err != nil {
+				return err
+			}
+
+			err = recordEvent(tx, &models.ChangeRequestEvent{
+				ChangeRequestID: r.ID,
+				Type:            models.EventDeadline,
+				Comment:         "判断期限が近いためリマインドしました",
+			})
+			if err != nil {
+				return err
+			}
+			return notifyUsers(tx, recipients, &models.Notification{
+				Title:           "判断期限が近い申請があります",
+				Message:         fmt.Sprintf("申請「%s」（%s）の判断期限が近づいています。", title, statusLabel(r.Status)),
+				Type:            models.NotificationInfo,
+				Event:           models.NotificationDeadlineReminder,
+				ChangeRequestID: &r.ID,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findRequests - 判断を待っている申請のうち条件に該当するもの
+func (s *RequestScheduler) findRequests(cond squirrel.Sqlizer) ([]int, error) {
+	query := squirrel.Select("id").
+		From("change_requests").
+		Where(squirrel.Eq{"status": undecidedStatuses}).
+		Where(cond).
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// withLockedRequest - 申請を行ロックし、まだ判断を待っていれば fn を実行する（申請ごとにトランザクションを分ける）
+func (s *RequestScheduler) withLockedRequest(id int, fn func(tx *sql.Tx, r *lockedRequest, title string) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	r, err := lockChangeRequest(tx, id, nil)
+	if err != nil {
+		return err
+	}
+	// 確認した後に他のユーザーが判断していれば何もしない
+	if !containsString(undecidedStatuses, r.Status) {
+		return nil
+	}
+
+	var title string
+	if err := tx.QueryRow("SELECT title FROM change_requests WHERE id = ?", id).Scan(&title); err != nil {
+		return err
+	}
+
+	if err := fn(tx, r, title); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pendingConsentTeachers - 申請への同意をまだ回答していない教員
+func pendingConsentTeachers(q queryer, requestID int) ([]int, error) {
+	rows, err := q.Query(`
+		SELECT teacher_id FROM change_request_consents
+		WHERE change_request_id = ? AND status = ?
+	`, requestID, models.ConsentPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teacherIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		teacherIDs = append(teacherIDs, id)
+	}
+	return teacherIDs, rows.Err()
+}
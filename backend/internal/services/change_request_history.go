@@ -0,0 +1,119 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// recordEvent - 申請の履歴を記録（呼び出し側のトランザクション内で実行、actorID が 0 の場合は自動処理）
+func recordEvent(tx *sql.Tx, event *models.ChangeRequestEvent) error {
+	var actorID, fromStatus, toStatus, before, after interface{}
+	if event.ActorID != nil && *event.ActorID != 0 {
+		actorID = *event.ActorID
+	}
+	if event.FromStatus != "" {
+		fromStatus = event.FromStatus
+	}
+	if event.ToStatus != "" {
+		toStatus = event.ToStatus
+	}
+	if len(event.Before) > 0 {
+		before = string(event.Before)
+	}
+	if len(event.After) > 0 {
+		after = string(event.After)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO change_request_events
+			(change_request_id, actor_id, event_type, from_status, to_status, comment, before_data, after_data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, event.ChangeRequestID, actorID, event.Type, fromStatus, toStatus, event.Comment, before, after)
+	if err != nil {
+		return fmt.Errorf("履歴の記録に失敗しました: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	event.ID = int(id)
+	event.CreatedAt = time.Now()
+
+	return enqueueRequestWebhook(tx, event)
+}
+
+// AddComment - 申請にコメントを追加
+func (s *ChangeRequestService) AddComment(requestID, userID int, comment string) (*models.ChangeRequestEvent, error) {
+	if comment == "" {
+		return nil, fmt.Errorf("コメントを入力してください")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 申請が存在することを確認（削除と同時に書き込まないよう行ロックする）
+	if _, err := lockChangeRequest(tx, requestID, nil); err != nil {
+		return nil, err
+	}
+
+	event := &models.ChangeRequestEvent{
+		ChangeRequestID: requestID,
+		ActorID:         &userID,
+		Type:            models.EventComment,
+		Comment:         comment,
+	}
+	if err := recordEvent(tx, event); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// GetRequestHistory - 申請の履歴（古い順）
+func (s *ChangeRequestService) GetRequestHistory(requestID int) ([]models.ChangeRequestEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT e.id, e.change_request_id, e.actor_id, COALESCE(u.name, ''), e.event_type,
+			COALESCE(e.from_status, ''), COALESCE(e.to_status, ''), COALESCE(e.comment, ''),
+			e.before_data, e.after_data, e.created_at
+		FROM change_request_events e
+		LEFT JOIN users u ON e.actor_id = u.id
+		WHERE e.change_request_id = ?
+		ORDER BY e.created_at, e.id
+	`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.ChangeRequestEvent{}
+	for rows.Next() {
+		var e models.ChangeRequestEvent
+		var actorID sql.NullInt64
+		var before, after sql.NullString
+		if err := rows.Scan(&e.ID, &e.ChangeRequestID, &actorID, &e.ActorName, &e.Type,
+			&e.FromStatus, &e.ToStatus, &e.Comment, &before, &after, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		e.ActorID = nullIntPtr(actorID)
+		if before.Valid {
+			e.Before = []byte(before.String)
+		}
+		if after.Valid {
+			e.After = []byte(after.String)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// requestTitle - 通知に載せる申請の件名
+func requestTitle(q queryer, requestID int) (string, error) {
+	var title string
+	err := q.QueryRow("SELECT title FROM change_requests WHERE id = ?", requestID).Scan(&title)
+	return title, err
+}
+
+// notifySubmitted - 提出された申請を、同意待ちなら相手教員に、承認待ちなら次の承認者に通知する（操作した本人は除く）
+func (s *ChangeRequestService) notifySubmitted(tx *sql.Tx, r *lockedRequest, status string, actorID int) error {
+	title, err := requestTitle(tx, r.ID)
+	if err != nil {
+		return err
+	}
+
+	if status == models.StatusAwaitingConsent {
+		teacherIDs, err := pendingConsentTeachers(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		return notifyUsers(tx, excludeUsers(teacherIDs, actorID), &models.Notification{
+			Title:           "同意を求められている申請があります",
+			Message:         fmt.Sprintf("申請「%s」について、あなたの同意が必要です。", title),
+			Type:            models.NotificationInfo,
+			Event:           models.NotificationConsentRequested,
+			ChangeRequestID: &r.ID,
+		})
+	}
+
+	approverIDs, err := s.approverUserIDs(tx, r.ID, r.RequestData)
+	if err != nil {
+		return err
+	}
+	return notifyUsers(tx, excludeUsers(approverIDs, actorID, r.RequesterID), &models.Notification{
+		Title:           "承認待ちの申請があります",
+		Message:         fmt.Sprintf("申請「%s」が承認待ちになりました。", title),
+		Type:            models.NotificationInfo,
+		Event:           models.NotificationRequestSubmitted,
+		ChangeRequestID: &r.ID,
+	})
+}
+
+// notifyDecision - 承認・却下の結果を申請者に通知する（判断した本人が申請者の場合は通知しない）
+func notifyDecision(tx *sql.Tx, r *lockedRequest, status string, actorID int, comment string) error {
+	title, err := requestTitle(tx, r.ID)
+	if err != nil {
+		return err
+	}
+
+	n := &models.Notification{ChangeRequestID: &r.ID}
+	switch status {
+	case models.StatusApplied:
+		n.Title = "申請が承認されました"
+		n.Message = fmt.Sprintf("申請「%s」が承認され、時間割に反映されました。", title)
+		n.Type = models.NotificationSuccess
+		n.Event = models.NotificationRequestApproved
+	default:
+		n.Title = "申請が却下されました"
+		n.Message = fmt.Sprintf("申請「%s」は却下されました。", title)
+		n.Type = models.NotificationError
+		n.Event = models.NotificationRequestRejected
+	}
+	if comment != "" {
+		n.Message += "\nコメント：" + comment
+	}
+
+	return notifyUsers(tx, excludeUsers([]int{r.RequesterID}, actorID), n)
+}
+
+// notifyApplied - 申請の反映で変わる授業の教員・学生に時間割の変更を通知する（申請者と承認した本人は除く）
+func notifyApplied(tx *sql.Tx, r *lockedRequest, applications []models.ChangeRequestApplication, actorID int) error {
+	recipients, err := applicationUserIDs(tx, applications)
+	if err != nil {
+		return err
+	}
+	title, err := requestTitle(tx, r.ID)
+	if err != nil {
+		return err
+	}
+
+	return notifyUsers(tx, excludeUsers(recipients, actorID, r.RequesterID), &models.Notification{
+		Title:           "時間割が変更されました",
+		Message:         fmt.Sprintf("申請「%s」が承認され、あなたの授業の時間割が変更されました。", title),
+		Type:            models.NotificationInfo,
+		Event:           models.NotificationTimetableChanged,
+		ChangeRequestID: &r.ID,
+	})
+}
+
+// applicationUserIDs - 反映記録の授業の教員・学生と、変更前の担当教員・代講の教員
+func applicationUserIDs(q queryer, applications []models.ChangeRequestApplication) ([]int, error) {
+	var timetableIDs, userIDs []int
+	for _, a := range applications {
+		if a.TimetableID != nil {
+			timetableIDs = append(timetableIDs, *a.TimetableID)
+		}
+		if a.Before != nil {
+			userIDs = append(userIDs, a.Before.TeacherID)
+		}
+		if a.OverrideID != nil {
+			var newTeacherID sql.NullInt64
+			err := q.QueryRow("SELECT new_teacher_id FROM timetable_overrides WHERE id = ?", *a.OverrideID).Scan(&newTeacherID)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if newTeacherID.Valid {
+				userIDs = append(userIDs, int(newTeacherID.Int64))
+			}
+		}
+	}
+
+	lessonUsers, err := lessonUserIDs(q, timetableIDs)
+	if err != nil {
+		return nil, err
+	}
+	return append(userIDs, lessonUsers...), nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// ErrSlotChanged - 反映後に対象のコマが変更されているため、反映前の内容に戻せない
+var ErrSlotChanged = errors.New("反映後に時間割が変更されているため取り消せません")
+
+// ErrNoApplications - 時間割への反映の記録がない（反映前の内容が分からない）ため取り消せない
+var ErrNoApplications = errors.New("時間割への反映の記録がないため取り消せません")
+
+// RevertChangeRequest - 反映済みの申請を取り消し、時間割を反映前の内容に戻す（対象クラスの範囲の承認権限）
+// 反映後に対象のコマが別の変更で書き換えられている場合は ErrSlotChanged になる
+func (s *ChangeRequestService) RevertChangeRequest(id, userID int, comment string, version *int) (*models.ChangeRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := lockChangeRequest(tx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(r.Status, models.StatusReverted) {
+		return nil, &TransitionError{From: r.Status, To: models.StatusReverted}
+	}
+
+	scope, err := s.requestScope(r.RequestData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.permissionService.RequirePermission(userID, models.PermissionRequestApprove, scope); err != nil {
+		return nil, err
+	}
+
+	applications, err := loadApplications(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(applications) == 0 {
+		return nil, ErrNoApplications
+	}
+	// 日付指定の変更は取り消すと削除されるため、先に配信する内容を作っておく
+	event, err := applicationEvent(tx, models.TimetableEventReverted, id, applications)
+	if err != nil {
+		return nil, err
+	}
+	if err := enqueueTimetableWebhook(tx, event); err != nil {
+		return nil, err
+	}
+	affected, err := s.revertApplications(tx, applications)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE change_request_applications SET reverted_at = ? WHERE change_request_id = ?", time.Now(), id); err != nil {
+		return nil, err
+	}
+	if err := r.transition(tx, models.StatusReverted, userID, comment, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifyRevert(tx, r, applications, affected, userID, comment); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.broker.Publish(event)
+
+	return s.GetChangeRequestByID(id)
+}
+
+// revertApplications - 反映記録をもとに時間割を反映前の内容に戻し、影響を受ける教員を返す
+// 週間時間割は反映後の内容から変わっていないことを、日付指定の変更は後から同じ授業を変更していないことを確認する
+// どちらも戻した授業が、その後に同じコマへ入った別の授業とぶつかる場合は ConflictError を返す
+func (s *ChangeRequestService) revertApplications(tx *sql.Tx, applications []models.ChangeRequestApplication) ([]int, error) {
+	var affected []int
+	var before, current []models.TimetableSnapshot
+	var restored []restoredLesson
+
+	for _, a := range applications {
+		switch {
+		case a.OverrideID != nil:
+			teacherIDs, lesson, err := revertOverride(tx, *a.OverrideID)
+			if err != nil {
+				return nil, err
+			}
+			affected = append(affected, teacherIDs...)
+			restored = append(restored, *lesson)
+
+		case a.Before != nil && a.After != nil:
+			t, err := loadTimetableSnapshot(tx, a.Before.ID)
+			if err != nil {
+				return nil, fmt.Errorf("%w（時間割ID %d）", ErrSlotChanged, a.Before.ID)
+			}
+			if !sameLessonContent(t, a.After) {
+				return nil, fmt.Errorf("%w（時間割ID %d）", ErrSlotChanged, a.Before.ID)
+			}
+			before = append(before, *a.Before)
+			current = append(current, *t)
+			affected = append(affected, a.Before.TeacherID, a.After.TeacherID)
+			affected = append(affected, a.Before.CoTeacherIDs...)
+
+		default:
+			// 日付指定の変更が削除されている
+			return nil, ErrSlotChanged
+		}
+	}
+
+	// 日付指定の変更を取り消した授業が、その日の別の授業とぶつからないか確認
+	conflicts, err := checkRestoredLessons(tx, restored)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	if len(before) == 0 {
+		return affected, nil
+	}
+
+	// 戻し先のコマが別の授業で埋まっていないか確認
+	conflicts, err = s.conflictService.CheckLessons(tx, before, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	// 入れ替えの取り消しは反映時と同じく、一方を退避してから逆順に更新する
+	if len(current) == 2 && sameClassGroup(&current[0], &current[1]) {
+		if err := parkTimetable(tx, &current[0]); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		if err := updateTimetable(tx, &before[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return affected, nil
+}
+
+// restoredLesson - 日付指定の変更を取り消して元に戻した授業
+type restoredLesson struct {
+	timetableID int
+	date        string
+}
+
+// revertOverride - 申請で登録した日付指定の変更を削除し、対象の授業の担当教員と代講の教員、元に戻した授業を返す
+func revertOverride(tx *sql.Tx, overrideID int) ([]int, *restoredLesson, error) {
+	var timetableID, teacherID int
+	var date time.Time
+	var newTeacherID, replacedTeacherID sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT o.timetable_id, o.date, o.new_teacher_id, o.replaced_teacher_id, t.teacher_id
+		FROM timetable_overrides o
+		JOIN timetables t ON o.timetable_id = t.id
+		WHERE o.id = ? FOR UPDATE
+	`, overrideID).Scan(&timetableID, &date, &newTeacherID, &replacedTeacherID, &teacherID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrSlotChanged
+		}
+		return nil, nil, err
+	}
+
+	var later int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM timetable_overrides
+		WHERE timetable_id = ? AND date = ? AND id > ?
+	`, timetableID, date, overrideID).Scan(&later)
+	if err != nil {
+		return nil, nil, err
+	}
+	if later > 0 {
+		return nil, nil, fmt.Errorf("%w（%s の時間割ID %d）", ErrSlotChanged, date.Format(dateLayout), timetableID)
+	}
+
+	if _, err := tx.Exec("DELETE FROM timetable_overrides WHERE id = ?", overrideID); err != nil {
+		return nil, nil, err
+	}
+
+	teacherIDs := []int{teacherID}
+	if newTeacherID.Valid {
+		teacherIDs = append(teacherIDs, int(newTeacherID.Int64))
+	}
+	if replacedTeacherID.Valid {
+		teacherIDs = append(teacherIDs, int(replacedTeacherID.Int64))
+	}
+	return teacherIDs, &restoredLesson{timetableID: timetableID, date: date.Format(dateLayout)}, nil
+}
+
+// checkRestoredLessons - 日付指定の変更を取り消して元に戻した授業と、その日の別の授業との競合を確認
+// 休講・移動を取り消すと元のコマに授業が戻るため、その後に同じコマへ移動してきた授業などとぶつかることがある
+func checkRestoredLessons(q queryer, restored []restoredLesson) ([]models.Conflict, error) {
+	byDate := make(map[string]map[int]bool)
+	var dates []string
+	for _, r := range restored {
+		if byDate[r.date] == nil {
+			byDate[r.date] = make(map[int]bool)
+			dates = append(dates, r.date)
+		}
+		byDate[r.date][r.timetableID] = true
+	}
+
+	conflicts := []models.Conflict{}
+	for _, date := range dates {
+		d, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, err
+		}
+		lessons, err := loadEffectiveLessons(q, d, d)
+		if err != nil {
+			return nil, err
+		}
+
+		isRestored := func(l *models.EffectiveLesson) bool {
+			return byDate[date][l.ID] && l.Status != models.LessonMovedIn && isActiveLesson(l)
+		}
+		for i := range lessons {
+			if !isRestored(&lessons[i]) {
+				continue
+			}
+			a := effectiveSnapshot(&lessons[i])
+			for j := range lessons {
+				// 戻した授業同士は一度だけ比較する
+				if i == j || !isActiveLesson(&lessons[j]) || (j < i && isRestored(&lessons[j])) {
+					continue
+				}
+				b := effectiveSnapshot(&lessons[j])
+				conflicts = append(conflicts, compareLessons(&a, &b)...)
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// sameLessonContent - 反映で書き換えた項目が一致するか（共同担当教員は反映で変わらないため比較しない）
+func sameLessonContent(a, b *models.TimetableSnapshot) bool {
+	if (a.GroupID == nil) != (b.GroupID == nil) || (a.GroupID != nil && *a.GroupID != *b.GroupID) {
+		return false
+	}
+	return a.ClassID == b.ClassID && a.SubjectID == b.SubjectID && a.TeacherID == b.TeacherID &&
+		a.DayOfWeek == b.DayOfWeek && a.Period == b.Period && a.Room == b.Room
+}
+
+// notifyRevert - 申請者・相手教員・承認者と、対象の授業の教員・学生に取り消しを通知する（取り消した本人は除く）
+func (s *ChangeRequestService) notifyRevert(tx *sql.Tx, r *lockedRequest, applications []models.ChangeRequestApplication, teacherIDs []int, actorID int, comment string) error {
+	title, err := requestTitle(tx, r.ID)
+	if err != nil {
+		return err
+	}
+
+	lessonUsers, err := applicationUserIDs(tx, applications)
+	if err != nil {
+		return err
+	}
+	recipients := append([]int{r.RequesterID}, teacherIDs...)
+	recipients = append(recipients, lessonUsers...)
+	rows, err := tx.Query(`
+		SELECT teacher_id FROM change_request_consents WHERE change_request_id = ?
+		UNION
+		SELECT approver_id FROM change_request_approval_steps WHERE change_request_id = ? AND approver_id IS NOT NULL
+		UNION
+		SELECT approver_id FROM change_requests WHERE id = ? AND approver_id IS NOT NULL
+	`, r.ID, r.ID, r.ID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		recipients = append(recipients, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("申請「%s」で反映した時間割の変更が取り消され、変更前の時間割に戻りました。", title)
+	if comment != "" {
+		body += "\n理由：" + comment
+	}
+
+	return notifyUsers(tx, excludeUsers(recipients, actorID), &models.Notification{
+		Title:           "時間割の変更が取り消されました",
+		Message:         body,
+		Type:            models.NotificationWarning,
+		Event:           models.NotificationRequestReverted,
+		ChangeRequestID: &r.ID,
+	})
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+var (
+	// ErrInvalidTransition - 現在の状態では許可されていない操作（TransitionError がこれとして扱われる）
+	ErrInvalidTransition = errors.New("この状態の申請には実行できない操作です")
+	// ErrVersionConflict - 画面に表示していた後に、他のユーザーが申請を更新した
+	ErrVersionConflict = errors.New("申請が他のユーザーによって更新されています。最新の内容を確認してください")
+	// ErrRequestNotEditable - 提出・承認後の申請を修正しようとした
+	ErrRequestNotEditable = errors.New("承認前の申請のみ修正できます")
+	// ErrRequestNotDeletable - 提出済みの申請を削除しようとした（履歴と反映の記録を残すため）
+	ErrRequestNotDeletable = errors.New("下書きと取り下げた申請のみ削除できます")
+)
+
+// 申請の状態遷移（遷移元 → 遷移できる状態）
+// 下書きを提出すると承認待ち（相手教員の同意が必要なら同意待ち）になり、承認すると時間割へ反映して反映済みになる
+// 判断されないまま実施日を過ぎた申請は期限切れになる
+var requestTransitions = map[string][]string{
+	models.StatusDraft:           {models.StatusPending, models.StatusAwaitingConsent, models.StatusCanceled},
+	models.StatusAwaitingConsent: {models.StatusPending, models.StatusRejected, models.StatusCanceled, models.StatusExpired},
+	models.StatusPending:         {models.StatusApproved, models.StatusRejected, models.StatusCanceled, models.StatusExpired},
+	models.StatusApproved:        {models.StatusApplied, models.StatusReverted},
+	models.StatusApplied:         {models.StatusReverted},
+}
+
+// 申請者が内容を修正できる状態
+var editableStatuses = map[string]bool{
+	models.StatusDraft:           true,
+	models.StatusAwaitingConsent: true,
+	models.StatusPending:         true,
+}
+
+var statusLabels = map[string]string{
+	models.StatusDraft:           "下書き",
+	models.StatusAwaitingConsent: "同意待ち",
+	models.StatusPending:         "承認待ち",
+	models.StatusApproved:        "承認済み",
+	models.StatusApplied:         "反映済み",
+	models.StatusRejected:        "却下",
+	models.StatusCanceled:        "取り下げ",
+	models.StatusReverted:        "取り消し済み",
+	models.StatusExpired:         "期限切れ",
+}
+
+// TransitionError - 許可されていない状態遷移
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("「%s」の申請を「%s」にはできません", statusLabel(e.From), statusLabel(e.To))
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// CanTransition - from の状態から to の状態へ遷移できるか
+func CanTransition(from, to string) bool {
+	for _, s := range requestTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func statusLabel(status string) string {
+	if label, ok := statusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// lockedRequest - トランザクション内で行ロックして読み込んだ申請
+type lockedRequest struct {
+	ID          int
+	RequesterID int
+	Status      string
+	Version     int
+	RequestData json.RawMessage
+}
+
+// lockChangeRequest - 申請を行ロックして読み込む（version を指定した場合、現在の version と異なれば ErrVersionConflict）
+func lockChangeRequest(tx *sql.Tx, id int, version *int) (*lockedRequest, error) {
+	var r lockedRequest
+	var requestData string
+	err := tx.QueryRow(`
+		SELECT id, requester_id, status, version, request_data
+		FROM change_requests WHERE id = ? FOR UPDATE
+	`, id).Scan(&r.ID, &r.RequesterID, &r.Status, &r.Version, &requestData)
+	if err != nil {
+		return nil, err
+	}
+	r.RequestData = json.RawMessage(requestData)
+
+	if version != nil && *version != r.Version {
+		return nil, ErrVersionConflict
+	}
+
+	return &r, nil
+}
+
+// transition - 状態遷移を検証してステータスを更新し、version を進めて履歴に記録する
+// actorID は操作したユーザー（自動処理は 0）、fields は同時に更新する列
+func (r *lockedRequest) transition(tx *sql.Tx, to string, actorID int, comment string, fields map[string]interface{}) error {
+	if !CanTransition(r.Status, to) {
+		return &TransitionError{From: r.Status, To: to}
+	}
+
+	query := squirrel.Update("change_requests").
+		Set("status", to).
+		Set("version", squirrel.Expr("version + 1")).
+		Set("updated_at", time.Now()).
+		SetMap(fields).
+		Where(squirrel.Eq{"id": r.ID, "version": r.Version}).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %v", err)
+	}
+
+	result, err := tx.Exec(sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrVersionConflict
+	}
+
+	event := &models.ChangeRequestEvent{
+		ChangeRequestID: r.ID,
+		ActorID:         &actorID,
+		Type:            models.EventStatus,
+		FromStatus:      r.Status,
+		ToStatus:        to,
+		Comment:         comment,
+	}
+	if err := recordEvent(tx, event); err != nil {
+		return err
+	}
+
+	r.Status = to
+	r.Version++
+	return nil
+}
+
+// touch - 状態を変えずに version だけを進める（多段階承認の途中の段階を承認した場合など）
+func (r *lockedRequest) touch(tx *sql.Tx) error {
+	result, err := tx.Exec(`
+		UPDATE change_requests SET version = version + 1, updated_at = ?
+		WHERE id = ? AND version = ?
+	`, time.Now(), r.ID, r.Version)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrVersionConflict
+	}
+
+	r.Version++
+	return nil
+}
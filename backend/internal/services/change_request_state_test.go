@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"kosen-schedule-system/internal/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		// 提出・同意
+		{models.StatusDraft, models.StatusPending, true},
+		{models.StatusDraft, models.StatusAwaitingConsent, true},
+		{models.StatusAwaitingConsent, models.StatusPending, true},
+		{models.StatusAwaitingConsent, models.StatusRejected, true},
+		// 承認・却下・反映・取り消し
+		{models.StatusPending, models.StatusApproved, true},
+		{models.StatusPending, models.StatusRejected, true},
+		{models.StatusApproved, models.StatusApplied, true},
+		{models.StatusApproved, models.StatusReverted, true},
+		{models.StatusApplied, models.StatusReverted, true},
+		// 取り下げ・期限切れは判断前のみ
+		{models.StatusDraft, models.StatusCanceled, true},
+		{models.StatusAwaitingConsent, models.StatusCanceled, true},
+		{models.StatusPending, models.StatusCanceled, true},
+		{models.StatusAwaitingConsent, models.StatusExpired, true},
+		{models.StatusPending, models.StatusExpired, true},
+
+		// 下書きは承認・却下・期限切れにならない
+		{models.StatusDraft, models.StatusApproved, false},
+		{models.StatusDraft, models.StatusRejected, false},
+		{models.StatusDraft, models.StatusExpired, false},
+		// 同意待ちは同意を経ずに承認できない
+		{models.StatusAwaitingConsent, models.StatusApproved, false},
+		// 承認待ちから直接反映・取り消しにはならない
+		{models.StatusPending, models.StatusApplied, false},
+		{models.StatusPending, models.StatusReverted, false},
+		// 承認後は取り下げ・却下できない
+		{models.StatusApproved, models.StatusCanceled, false},
+		{models.StatusApproved, models.StatusRejected, false},
+		{models.StatusApplied, models.StatusCanceled, false},
+		{models.StatusApplied, models.StatusApproved, false},
+		// 終了した申請は動かない
+		{models.StatusRejected, models.StatusPending, false},
+		{models.StatusCanceled, models.StatusDraft, false},
+		{models.StatusReverted, models.StatusApplied, false},
+		{models.StatusExpired, models.StatusPending, false},
+		// 同じ状態・未知の状態
+		{models.StatusPending, models.StatusPending, false},
+		{"unknown", models.StatusPending, false},
+		{models.StatusPending, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionErrorIsInvalidTransition(t *testing.T) {
+	err := &TransitionError{From: models.StatusApplied, To: models.StatusCanceled}
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Error("TransitionError should match ErrInvalidTransition")
+	}
+	if want := "「反映済み」の申請を「取り下げ」にはできません"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type ClassGroupService struct {
+	db *sql.DB
+}
+
+func NewClassGroupService(db *sql.DB) *ClassGroupService {
+	return &ClassGroupService{db: db}
+}
+
+func classGroupSelect() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"g.id", "g.class_id", "g.name", "g.created_at",
+		"(SELECT COUNT(*) FROM class_group_members m WHERE m.group_id = g.id)",
+	).
+		From("class_groups g").
+		PlaceholderFormat(squirrel.Question)
+}
+
+func scanClassGroup(scanner interface{ Scan(...interface{}) error }) (*models.ClassGroup, error) {
+	var g models.ClassGroup
+	if err := scanner.Scan(&g.ID, &g.ClassID, &g.Name, &g.CreatedAt, &g.MemberCount); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// GetClassGroups - クラスのグループ一覧
+func (s *ClassGroupService) GetClassGroups(classID int) ([]models.ClassGroup, error) {
+	query := classGroupSelect().
+		Where(squirrel.Eq{"g.class_id": classID}).
+		OrderBy("g.name")
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	groups := []models.ClassGroup{}
+	for rows.Next() {
+		g, err := scanClassGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		groups = append(groups, *g)
+	}
+
+	return groups, nil
+}
+
+// GetClassGroupByID - グループ取得
+func (s *ClassGroupService) GetClassGroupByID(id int) (*models.ClassGroup, error) {
+	query := classGroupSelect().Where(squirrel.Eq{"g.id": id})
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	g, err := scanClassGroup(s.db.QueryRow(sqlStr, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("group not found")
+		}
+		return nil, fmt.Errorf("failed to get group: %v", err)
+	}
+
+	return g, nil
+}
+
+// CreateClassGroup - グループ作成
+func (s *ClassGroupService) CreateClassGroup(classID int, req *models.CreateClassGroupRequest) (*models.ClassGroup, error) {
+	result, err := s.db.Exec("INSERT INTO class_groups (class_id, name) VALUES (?, ?)", classID, req.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetClassGroupByID(int(id))
+}
+
+// DeleteClassGroup - グループ削除（グループの授業も削除される）
+func (s *ClassGroupService) DeleteClassGroup(classID, groupID int) error {
+	result, err := s.db.Exec("DELETE FROM class_groups WHERE id = ? AND class_id = ?", groupID, classID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("group not found")
+	}
+
+	return nil
+}
+
+// GetGroupMembers - グループ所属学生一覧
+func (s *ClassGroupService) GetGroupMembers(groupID int) ([]models.User, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.name, u.email, u.role, u.created_at
+		FROM class_group_members m
+		JOIN users u ON m.student_id = u.id
+		WHERE m.group_id = ?
+		ORDER BY u.name
+	`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// AddGroupMember - 学生をグループに追加（今年度そのクラスに在籍している学生のみ）
+func (s *ClassGroupService) AddGroupMember(groupID, studentID int, academicYear int) error {
+	var enrolled bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM enrollments e
+			JOIN class_groups g ON g.class_id = e.class_id
+			WHERE g.id = ? AND e.student_id = ? AND e.academic_year = ?
+		)
+	`, groupID, studentID, academicYear).Scan(&enrolled)
+	if err != nil {
+		return err
+	}
+	if !enrolled {
+		return fmt.Errorf("学生がこのクラスに在籍していません")
+	}
+
+	_, err = s.db.Exec("INSERT IGNORE INTO class_group_members (group_id, student_id) VALUES (?, ?)", groupID, studentID)
+	return err
+}
+
+// RemoveGroupMember - 学生をグループから外す
+func (s *ClassGroupService) RemoveGroupMember(groupID, studentID int) error {
+	_, err := s.db.Exec("DELETE FROM class_group_members WHERE group_id = ? AND student_id = ?", groupID, studentID)
+	return err
+}
+
+// GetStudentGroupIDs - 学生が所属するクラス内グループのID一覧
+func (s *ClassGroupService) GetStudentGroupIDs(studentID, classID int) (map[int]bool, error) {
+	rows, err := s.db.Query(`
+		SELECT g.id
+		FROM class_group_members m
+		JOIN class_groups g ON m.group_id = g.id
+		WHERE m.student_id = ? AND g.class_id = ?
+	`, studentID, classID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	groupIDs := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		groupIDs[id] = true
+	}
+
+	return groupIDs, nil
+}
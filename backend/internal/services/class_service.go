@@ -16,11 +16,36 @@ func NewClassService(db *sql.DB) *ClassService {
 	return &ClassService{db: db}
 }
 
-func (s *ClassService) GetClasses() ([]models.Class, error) {
-	query := squirrel.Select("id", "grade", "class_name", "created_at").
-		From("classes").
-		OrderBy("grade", "class_name").
+func classSelect() squirrel.SelectBuilder {
+	return squirrel.Select("c.id", "c.grade", "c.class_name", "c.department_id", "COALESCE(d.name, '')",
+		"c.homeroom_teacher_id", "COALESCE(h.name, '')", "c.created_at").
+		From("classes c").
+		LeftJoin("departments d ON c.department_id = d.id").
+		LeftJoin("users h ON c.homeroom_teacher_id = h.id").
 		PlaceholderFormat(squirrel.Question)
+}
+
+func scanClass(scanner interface{ Scan(...interface{}) error }) (*models.Class, error) {
+	var c models.Class
+	var departmentID, homeroomTeacherID sql.NullInt64
+	if err := scanner.Scan(&c.ID, &c.Grade, &c.ClassName, &departmentID, &c.DepartmentName,
+		&homeroomTeacherID, &c.HomeroomTeacherName, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	c.DepartmentID = nullIntPtr(departmentID)
+	c.HomeroomTeacherID = nullIntPtr(homeroomTeacherID)
+	return &c, nil
+}
+
+func (s *ClassService) GetClasses(filter models.ClassFilter) ([]models.Class, error) {
+	query := classSelect().OrderBy("c.grade", "c.class_name")
+
+	if filter.Grade != nil {
+		query = query.Where(squirrel.Eq{"c.grade": *filter.Grade})
+	}
+	if filter.DepartmentID != nil {
+		query = query.Where(squirrel.Eq{"c.department_id": *filter.DepartmentID})
+	}
 
 	sql, args, err := query.ToSql()
 	if err != nil {
@@ -35,30 +60,25 @@ func (s *ClassService) GetClasses() ([]models.Class, error) {
 
 	var classes []models.Class
 	for rows.Next() {
-		var c models.Class
-		err := rows.Scan(&c.ID, &c.Grade, &c.ClassName, &c.CreatedAt)
+		c, err := scanClass(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
-		classes = append(classes, c)
+		classes = append(classes, *c)
 	}
 
 	return classes, nil
 }
 
 func (s *ClassService) GetClassByID(id int) (*models.Class, error) {
-	query := squirrel.Select("id", "grade", "class_name", "created_at").
-		From("classes").
-		Where(squirrel.Eq{"id": id}).
-		PlaceholderFormat(squirrel.Question)
+	query := classSelect().Where(squirrel.Eq{"c.id": id})
 
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query: %v", err)
 	}
 
-	var c models.Class
-	err = s.db.QueryRow(sqlStr, args...).Scan(&c.ID, &c.Grade, &c.ClassName, &c.CreatedAt)
+	c, err := scanClass(s.db.QueryRow(sqlStr, args...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("class not found")
@@ -66,5 +86,102 @@ func (s *ClassService) GetClassByID(id int) (*models.Class, error) {
 		return nil, fmt.Errorf("failed to get class: %v", err)
 	}
 
-	return &c, nil
-}
\ No newline at end of file
+	return c, nil
+}
+
+// CreateClass - クラス作成
+func (s *ClassService) CreateClass(req *models.CreateClassRequest) (*models.Class, error) {
+	query := squirrel.Insert("classes").
+		Columns("grade", "class_name", "department_id", "homeroom_teacher_id").
+		Values(req.Grade, req.ClassName, req.DepartmentID, req.HomeroomTeacherID).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	result, err := s.db.Exec(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create class: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetClassByID(int(id))
+}
+
+// UpdateClass - クラス更新
+func (s *ClassService) UpdateClass(id int, req *models.UpdateClassRequest) (*models.Class, error) {
+	if req.Grade <= 0 && req.ClassName == "" && req.DepartmentID == nil && req.HomeroomTeacherID == nil {
+		return s.GetClassByID(id)
+	}
+
+	query := squirrel.Update("classes").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Question)
+
+	if req.Grade > 0 {
+		query = query.Set("grade", req.Grade)
+	}
+	if req.ClassName != "" {
+		query = query.Set("class_name", req.ClassName)
+	}
+	// 学科は指定した場合のみ変更する（0 を指定すると学科なしにする）
+	if req.DepartmentID != nil {
+		var departmentID interface{}
+		if *req.DepartmentID > 0 {
+			departmentID = *req.DepartmentID
+		}
+		query = query.Set("department_id", departmentID)
+	}
+	// 担任も指定した場合のみ変更する（0 を指定すると担任なしにする）
+	if req.HomeroomTeacherID != nil {
+		var teacherID interface{}
+		if *req.HomeroomTeacherID > 0 {
+			teacherID = *req.HomeroomTeacherID
+		}
+		query = query.Set("homeroom_teacher_id", teacherID)
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	if _, err := s.db.Exec(sqlStr, args...); err != nil {
+		return nil, fmt.Errorf("failed to update class: %v", err)
+	}
+
+	return s.GetClassByID(id)
+}
+
+// DeleteClass - クラス削除
+func (s *ClassService) DeleteClass(id int) error {
+	query := squirrel.Delete("classes").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %v", err)
+	}
+
+	result, err := s.db.Exec(sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete class: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("class not found")
+	}
+
+	return nil
+}
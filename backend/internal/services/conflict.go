@@ -0,0 +1,516 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// ConflictError - 時間割の競合があるため処理できない
+type ConflictError struct {
+	Conflicts []models.Conflict
+}
+
+func (e *ConflictError) Error() string {
+	messages := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		messages = append(messages, c.Message)
+	}
+	return "時間割が競合しています: " + strings.Join(messages, " / ")
+}
+
+// Is - クラス（グループ）の時間重複を含む場合は ErrSlotOccupied としても扱う
+func (e *ConflictError) Is(target error) bool {
+	if target != ErrSlotOccupied {
+		return false
+	}
+	for _, c := range e.Conflicts {
+		if c.Type == models.ConflictClass {
+			return true
+		}
+	}
+	return false
+}
+
+// queryer - *sql.DB と *sql.Tx の共通部分
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type ConflictService struct {
+	db *sql.DB
+}
+
+func NewConflictService(db *sql.DB) *ConflictService {
+	return &ConflictService{db: db}
+}
+
+// 場所の重複を確認しない授業場所
+var sharedRooms = map[string]bool{
+	"":   true,
+	"未定": true,
+}
+
+// CheckChange - 変更申請の内容を反映した場合の競合を確認（日付指定の変更・入れ替えを含む）
+// requesterID は代講申請で交代する教員（申請者）
+func (s *ConflictService) CheckChange(data *models.TimetableChangeData, requesterID int) (*models.ConflictCheckResult, error) {
+	if data.RequestType == models.RequestTypeSwap {
+		conflicts, err := s.checkSwap(s.db, data)
+		if err != nil {
+			return nil, err
+		}
+		return newConflictCheckResult(conflicts), nil
+	}
+
+	if data.RequestType == models.RequestTypeSubstitute {
+		overrides, err := substituteOverrides(data, requesterID)
+		if err != nil {
+			return nil, err
+		}
+		conflicts, err := s.CheckOverrides(s.db, overrides)
+		if err != nil {
+			return nil, err
+		}
+		return newConflictCheckResult(conflicts), nil
+	}
+
+	if data.Date != "" {
+		conflicts, err := s.CheckOverride(s.db, overrideFromChangeData(data))
+		if err != nil {
+			return nil, err
+		}
+		return newConflictCheckResult(conflicts), nil
+	}
+
+	before, err := loadTimetableSnapshot(s.db, data.OriginalTimetableID)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := changedSnapshot(s.db, before, data)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := s.CheckLessons(s.db, []models.TimetableSnapshot{*after}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConflictCheckResult(conflicts), nil
+}
+
+// CheckOverride - 日付指定の変更を反映した場合に、変更先の日の実際の時間割とぶつからないか確認
+func (s *ConflictService) CheckOverride(q queryer, override *models.TimetableOverride) ([]models.Conflict, error) {
+	return s.CheckOverrides(q, []*models.TimetableOverride{override})
+}
+
+// CheckOverrides - 同時に反映する日付指定の変更（入れ替えなど）の競合を確認する
+// 変更される授業自身は比較対象から外し、変更後の授業同士は比較する
+func (s *ConflictService) CheckOverrides(q queryer, overrides []*models.TimetableOverride) ([]models.Conflict, error) {
+	type lessonKey struct {
+		timetableID int
+		date        string
+	}
+	type proposal struct {
+		target string
+		lesson models.TimetableSnapshot
+	}
+
+	changed := make(map[lessonKey]string)
+	var proposals []proposal
+	for _, override := range overrides {
+		base, err := validateOverride(q, override)
+		if err != nil {
+			return nil, err
+		}
+		// 同時に登録する変更の中でも、休講・移動と同じ授業・日付の変更は重ねない
+		key := lessonKey{base.ID, override.Date}
+		if previous, ok := changed[key]; ok && (previous == models.OverrideCancel || previous == models.OverrideMove ||
+			override.Type == models.OverrideCancel || override.Type == models.OverrideMove) {
+			return nil, ErrLessonAlreadyChanged
+		}
+		changed[key] = override.Type
+		if override.Type == models.OverrideCancel {
+			continue
+		}
+
+		target := override.Date
+		if override.NewDate != "" {
+			target = override.NewDate
+		}
+		targetDate, err := time.Parse(dateLayout, target)
+		if err != nil {
+			return nil, fmt.Errorf("日付が不正です: %s", target)
+		}
+
+		proposed := *base
+		proposed.DayOfWeek = weekdayName(targetDate)
+		if override.NewPeriod != nil {
+			proposed.Period = *override.NewPeriod
+		}
+		if override.NewTeacherID != nil {
+			proposed.TeacherID, proposed.CoTeacherIDs = substituteTeacher(
+				proposed.TeacherID, proposed.CoTeacherIDs, override.ReplacedTeacherID, *override.NewTeacherID)
+		}
+		if override.NewRoom != "" {
+			proposed.Room = override.NewRoom
+		}
+		proposals = append(proposals, proposal{target: target, lesson: proposed})
+	}
+
+	conflicts := []models.Conflict{}
+	lessonsByDate := make(map[string][]models.EffectiveLesson)
+	for i := range proposals {
+		p := &proposals[i]
+
+		lessons, ok := lessonsByDate[p.target]
+		if !ok {
+			targetDate, _ := time.Parse(dateLayout, p.target)
+			var err error
+			lessons, err = loadEffectiveLessons(q, targetDate, targetDate)
+			if err != nil {
+				return nil, err
+			}
+			lessonsByDate[p.target] = lessons
+		}
+
+		var found []models.Conflict
+		for j := range lessons {
+			l := &lessons[j]
+			// 休講・移動元の授業と、変更する授業自身は比較しない
+			if _, ok := changed[lessonKey{l.ID, l.Date}]; !isActiveLesson(l) || ok {
+				continue
+			}
+			other := effectiveSnapshot(l)
+			found = append(found, compareLessons(&p.lesson, &other)...)
+		}
+
+		// 同じ日に入る変更後の授業同士の比較
+		for j := i + 1; j < len(proposals); j++ {
+			if proposals[j].target == p.target {
+				found = append(found, compareLessons(&p.lesson, &proposals[j].lesson)...)
+			}
+		}
+
+		unavailable, err := checkUnavailability(q, []models.TimetableSnapshot{p.lesson})
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, unavailable...)
+
+		for j := range found {
+			found[j].Message = p.target + " " + found[j].Message
+		}
+		conflicts = append(conflicts, found...)
+	}
+
+	return conflicts, nil
+}
+
+// CheckLessons - 提案されたコマ同士、および既存の時間割との競合を確認する
+// 提案に含まれる ID のコマと replacedClassIDs のクラスの既存授業は置き換えられるものとして比較対象から外す
+func (s *ConflictService) CheckLessons(q queryer, proposed []models.TimetableSnapshot, replacedClassIDs []int) ([]models.Conflict, error) {
+	conflicts := []models.Conflict{}
+	if len(proposed) == 0 {
+		return conflicts, nil
+	}
+
+	existing, err := loadSlotLessons(q, proposed, replacedClassIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range proposed {
+		p := &proposed[i]
+
+		// 既存の時間割との比較
+		for j := range existing {
+			conflicts = append(conflicts, compareLessons(p, &existing[j])...)
+		}
+
+		// 提案されたコマ同士の比較
+		for j := i + 1; j < len(proposed); j++ {
+			conflicts = append(conflicts, compareLessons(p, &proposed[j])...)
+		}
+	}
+
+	unavailable, err := checkUnavailability(q, proposed)
+	if err != nil {
+		return nil, err
+	}
+	conflicts = append(conflicts, unavailable...)
+
+	return conflicts, nil
+}
+
+// effectiveSnapshot - 特定の日の授業を、競合の確認に使う1コマの内容にする
+func effectiveSnapshot(l *models.EffectiveLesson) models.TimetableSnapshot {
+	return models.TimetableSnapshot{
+		ID:           l.ID,
+		ClassID:      l.ClassID,
+		GroupID:      l.GroupID,
+		SubjectID:    l.SubjectID,
+		TeacherID:    l.TeacherID,
+		DayOfWeek:    l.DayOfWeek,
+		Period:       l.Period,
+		Room:         l.Room,
+		CoTeacherIDs: l.CoTeacherIDs,
+	}
+}
+
+// compareLessons - 同じコマに入る2つの授業の競合を列挙
+func compareLessons(a, b *models.TimetableSnapshot) []models.Conflict {
+	if a.DayOfWeek != b.DayOfWeek || a.Period != b.Period || (a.ID != 0 && a.ID == b.ID) {
+		return nil
+	}
+
+	var conflicts []models.Conflict
+	base := models.Conflict{
+		DayOfWeek:              a.DayOfWeek,
+		Period:                 a.Period,
+		TimetableID:            a.ID,
+		ConflictingTimetableID: b.ID,
+	}
+
+	// クラス全体の授業は全グループと、グループの授業は同じグループとぶつかる
+	if a.ClassID == b.ClassID && (a.GroupID == nil || b.GroupID == nil || *a.GroupID == *b.GroupID) {
+		c := base
+		c.Type = models.ConflictClass
+		c.ClassID = a.ClassID
+		c.Message = fmt.Sprintf("%s %d限: クラスの授業が重複しています", dayLabel(a.DayOfWeek), a.Period)
+		conflicts = append(conflicts, c)
+	}
+
+	for _, teacherID := range lessonTeachers(a) {
+		for _, other := range lessonTeachers(b) {
+			if teacherID == other {
+				c := base
+				c.Type = models.ConflictTeacher
+				c.TeacherID = teacherID
+				c.Message = fmt.Sprintf("%s %d限: 教員（ID: %d）の授業が重複しています", dayLabel(a.DayOfWeek), a.Period, teacherID)
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	if !sharedRooms[a.Room] && a.Room == b.Room {
+		c := base
+		c.Type = models.ConflictRoom
+		c.Room = a.Room
+		c.Message = fmt.Sprintf("%s %d限: 授業場所「%s」が重複しています", dayLabel(a.DayOfWeek), a.Period, a.Room)
+		conflicts = append(conflicts, c)
+	}
+
+	return conflicts
+}
+
+// lessonTeachers - 主担当と共同担当の教員ID（担当者が分からない場合は空）
+func lessonTeachers(t *models.TimetableSnapshot) []int {
+	var teachers []int
+	if t.TeacherID != 0 {
+		teachers = append(teachers, t.TeacherID)
+	}
+	for _, id := range t.CoTeacherIDs {
+		if id != 0 && id != t.TeacherID {
+			teachers = append(teachers, id)
+		}
+	}
+	return teachers
+}
+
+// loadSlotLessons - 提案されたコマと同じ曜日・時限の既存授業（共同担当を含む）を取得
+func loadSlotLessons(q queryer, proposed []models.TimetableSnapshot, replacedClassIDs []int) ([]models.TimetableSnapshot, error) {
+	slots := squirrel.Or{}
+	seen := make(map[string]bool)
+	excludeIDs := []int{}
+	for _, p := range proposed {
+		key := fmt.Sprintf("%s-%d", p.DayOfWeek, p.Period)
+		if !seen[key] {
+			seen[key] = true
+			slots = append(slots, squirrel.Eq{"day_of_week": p.DayOfWeek, "period": p.Period})
+		}
+		if p.ID != 0 {
+			excludeIDs = append(excludeIDs, p.ID)
+		}
+	}
+
+	query := squirrel.Select("id", "class_id", "group_id", "subject_id", "teacher_id", "day_of_week", "period", "room").
+		From("timetables").
+		Where(slots).
+		PlaceholderFormat(squirrel.Question)
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{"id": excludeIDs})
+	}
+	if len(replacedClassIDs) > 0 {
+		query = query.Where(squirrel.NotEq{"class_id": replacedClassIDs})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := q.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	lessons := []models.TimetableSnapshot{}
+	for rows.Next() {
+		var t models.TimetableSnapshot
+		var groupID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.ClassID, &groupID, &t.SubjectID, &t.TeacherID, &t.DayOfWeek, &t.Period, &t.Room); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		t.GroupID = nullIntPtr(groupID)
+		lessons = append(lessons, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadCoTeachers(q, lessons); err != nil {
+		return nil, err
+	}
+
+	return lessons, nil
+}
+
+// loadCoTeachers - 共同担当教員を読み込む
+func loadCoTeachers(q queryer, lessons []models.TimetableSnapshot) error {
+	if len(lessons) == 0 {
+		return nil
+	}
+
+	index := make(map[int]*models.TimetableSnapshot, len(lessons))
+	ids := make([]int, 0, len(lessons))
+	for i := range lessons {
+		index[lessons[i].ID] = &lessons[i]
+		ids = append(ids, lessons[i].ID)
+	}
+
+	sqlStr, args, err := squirrel.Select("timetable_id", "teacher_id").
+		From("timetable_teachers").
+		Where(squirrel.Eq{"timetable_id": ids}).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := q.Query(sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var timetableID, teacherID int
+		if err := rows.Scan(&timetableID, &teacherID); err != nil {
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		if t, ok := index[timetableID]; ok {
+			t.CoTeacherIDs = append(t.CoTeacherIDs, teacherID)
+		}
+	}
+
+	return rows.Err()
+}
+
+// checkUnavailability - 担当教員の都合の悪い時間に入っていないか確認
+func checkUnavailability(q queryer, proposed []models.TimetableSnapshot) ([]models.Conflict, error) {
+	teacherIDs := []int{}
+	for i := range proposed {
+		teacherIDs = append(teacherIDs, lessonTeachers(&proposed[i])...)
+	}
+	if len(teacherIDs) == 0 {
+		return []models.Conflict{}, nil
+	}
+
+	sqlStr, args, err := squirrel.Select("teacher_id", "day_of_week", "period", "reason").
+		From("teacher_unavailability").
+		Where(squirrel.Eq{"teacher_id": teacherIDs}).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := q.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	var unavailable []models.TeacherUnavailability
+	for rows.Next() {
+		var u models.TeacherUnavailability
+		var period sql.NullInt64
+		if err := rows.Scan(&u.TeacherID, &u.DayOfWeek, &period, &u.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		u.Period = nullIntPtr(period)
+		unavailable = append(unavailable, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	conflicts := []models.Conflict{}
+	for i := range proposed {
+		p := &proposed[i]
+		for _, teacherID := range lessonTeachers(p) {
+			for _, u := range unavailable {
+				if u.TeacherID != teacherID || u.DayOfWeek != p.DayOfWeek || (u.Period != nil && *u.Period != p.Period) {
+					continue
+				}
+				message := fmt.Sprintf("%s %d限: 教員（ID: %d）の都合の悪い時間です", dayLabel(p.DayOfWeek), p.Period, teacherID)
+				if u.Reason != "" {
+					message += "（" + u.Reason + "）"
+				}
+				conflicts = append(conflicts, models.Conflict{
+					Type:        models.ConflictUnavailable,
+					Message:     message,
+					DayOfWeek:   p.DayOfWeek,
+					Period:      p.Period,
+					TimetableID: p.ID,
+					TeacherID:   teacherID,
+				})
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+func newConflictCheckResult(conflicts []models.Conflict) *models.ConflictCheckResult {
+	return &models.ConflictCheckResult{
+		HasConflicts: len(conflicts) > 0,
+		Conflicts:    conflicts,
+	}
+}
+
+// dayLabel - 曜日の表示名
+func dayLabel(day string) string {
+	switch day {
+	case "monday":
+		return models.DayMonday
+	case "tuesday":
+		return models.DayTuesday
+	case "wednesday":
+		return models.DayWednesday
+	case "thursday":
+		return models.DayThursday
+	case "friday":
+		return models.DayFriday
+	}
+	return day
+}
@@ -13,11 +13,15 @@ import (
 )
 
 type CSVService struct {
-	db *sql.DB
+	db              *sql.DB
+	conflictService *ConflictService
 }
 
 func NewCSVService(db *sql.DB) *CSVService {
-	return &CSVService{db: db}
+	return &CSVService{
+		db:              db,
+		conflictService: NewConflictService(db),
+	}
 }
 
 // 担当者CSVインポート
@@ -64,6 +68,9 @@ func (s *CSVService) ImportSubjects(reader io.Reader) (*models.CSVImportResult,
 			Teacher2:    strings.TrimSpace(record[6]),
 			Teacher3:    strings.TrimSpace(record[7]),
 		}
+		if len(record) > 8 {
+			subjectCSV.Department = strings.TrimSpace(record[8])
+		}
 
 		// バリデーション
 		if err := s.validateSubjectCSV(subjectCSV); err != nil {
@@ -131,29 +138,7 @@ func (s *CSVService) ImportTimetables(reader io.Reader) (*models.CSVImportResult
 			continue
 		}
 
-		timetableCSV := models.TimetableCSV{
-			Class: strings.TrimSpace(record[0]),
-			Mon1:  strings.TrimSpace(record[1]),
-			Mon2:  strings.TrimSpace(record[2]),
-			Mon3:  strings.TrimSpace(record[3]),
-			Mon4:  strings.TrimSpace(record[4]),
-			Tue1:  strings.TrimSpace(record[5]),
-			Tue2:  strings.TrimSpace(record[6]),
-			Tue3:  strings.TrimSpace(record[7]),
-			Tue4:  strings.TrimSpace(record[8]),
-			Wed1:  strings.TrimSpace(record[9]),
-			Wed2:  strings.TrimSpace(record[10]),
-			Wed3:  strings.TrimSpace(record[11]),
-			Wed4:  strings.TrimSpace(record[12]),
-			Thu1:  strings.TrimSpace(record[13]),
-			Thu2:  strings.TrimSpace(record[14]),
-			Thu3:  strings.TrimSpace(record[15]),
-			Thu4:  strings.TrimSpace(record[16]),
-			Fri1:  strings.TrimSpace(record[17]),
-			Fri2:  strings.TrimSpace(record[18]),
-			Fri3:  strings.TrimSpace(record[19]),
-			Fri4:  strings.TrimSpace(record[20]),
-		}
+		timetableCSV := timetableCSVFromRecord(record)
 
 		// バリデーション
 		if err := s.validateTimetableCSV(timetableCSV); err != nil {
@@ -186,6 +171,162 @@ func (s *CSVService) ImportTimetables(reader io.Reader) (*models.CSVImportResult
 	return result, nil
 }
 
+// 時間割CSVの競合チェック（インポートした場合に既存の時間割・教員の予定とぶつからないか確認する）
+func (s *CSVService) CheckTimetablesCSV(reader io.Reader) (*models.ConflictCheckResult, error) {
+	csvReader := csv.NewReader(reader)
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV読み込みエラー: %v", err)
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSVファイルが空または不正です")
+	}
+
+	var lessons []models.TimetableSnapshot
+	var classIDs []int
+	groupIDs := make(map[string]int)
+
+	// ヘッダー行をスキップ（不正な行はインポート時にエラーになるためここでは対象外）
+	for _, record := range records[1:] {
+		if len(record) < 21 {
+			continue
+		}
+
+		timetableCSV := timetableCSVFromRecord(record)
+		if err := s.validateTimetableCSV(timetableCSV); err != nil {
+			continue
+		}
+
+		classParts := strings.Split(timetableCSV.Class, "-")
+		grade, _ := strconv.Atoi(classParts[0])
+
+		var classID int
+		err := s.db.QueryRow("SELECT id FROM classes WHERE grade = ? AND class_name = ?", grade, classParts[1]).Scan(&classID)
+		if err != nil {
+			continue
+		}
+		classIDs = append(classIDs, classID)
+
+		for day, periods := range timetableCells(timetableCSV) {
+			for period, cell := range periods {
+				for _, lesson := range parseTimetableCell(cell) {
+					t := models.TimetableSnapshot{ClassID: classID, DayOfWeek: day, Period: period, Room: "未定"}
+
+					// 未登録の科目は担当者が分からないため、クラスの重複だけを確認する
+					err := s.db.QueryRow("SELECT id FROM subjects WHERE name = ?", lesson.SubjectName).Scan(&t.SubjectID)
+					if err != nil && err != sql.ErrNoRows {
+						return nil, err
+					}
+					if t.SubjectID != 0 {
+						if err := resolveSubjectAssignment(s.db, &t); err != nil {
+							return nil, err
+						}
+					}
+
+					// 未作成のグループには仮のID（負の値）を割り当てて比較する
+					if lesson.GroupName != "" {
+						key := fmt.Sprintf("%d:%s", classID, lesson.GroupName)
+						if _, ok := groupIDs[key]; !ok {
+							var id int
+							err := s.db.QueryRow("SELECT id FROM class_groups WHERE class_id = ? AND name = ?", classID, lesson.GroupName).Scan(&id)
+							if err == sql.ErrNoRows {
+								id = -(len(groupIDs) + 1)
+							} else if err != nil {
+								return nil, err
+							}
+							groupIDs[key] = id
+						}
+						groupID := groupIDs[key]
+						t.GroupID = &groupID
+					}
+
+					lessons = append(lessons, t)
+				}
+			}
+		}
+	}
+
+	conflicts, err := s.conflictService.CheckLessons(s.db, lessons, classIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConflictCheckResult(conflicts), nil
+}
+
+// 学生名簿CSVインポート（学籍番号, 氏名, メールアドレス, クラス）
+func (s *CSVService) ImportEnrollments(reader io.Reader, academicYear int) (*models.CSVImportResult, error) {
+	csvReader := csv.NewReader(reader)
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV読み込みエラー: %v", err)
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("CSVファイルが空または不正です")
+	}
+
+	result := &models.CSVImportResult{
+		Success:       true,
+		TotalRows:     len(records) - 1,
+		ProcessedRows: 0,
+		ErrorRows:     []models.CSVErrorRow{},
+		Errors:        []string{},
+		ProcessedAt:   time.Now(),
+	}
+
+	// ヘッダー行をスキップ
+	for i, record := range records[1:] {
+		rowNum := i + 2
+
+		if len(record) < 4 {
+			result.ErrorRows = append(result.ErrorRows, models.CSVErrorRow{
+				Row:   rowNum,
+				Error: "列数が不足しています（4列必要）",
+				Data:  strings.Join(record, ","),
+			})
+			continue
+		}
+
+		enrollmentCSV := models.EnrollmentCSV{
+			StudentNumber: strings.TrimSpace(record[0]),
+			Name:          strings.TrimSpace(record[1]),
+			Email:         strings.TrimSpace(record[2]),
+			Class:         strings.TrimSpace(record[3]),
+		}
+
+		// バリデーション
+		if err := s.validateEnrollmentCSV(enrollmentCSV); err != nil {
+			result.ErrorRows = append(result.ErrorRows, models.CSVErrorRow{
+				Row:   rowNum,
+				Error: err.Error(),
+				Data:  strings.Join(record, ","),
+			})
+			continue
+		}
+
+		// データベースに保存
+		if err := s.saveEnrollmentFromCSV(enrollmentCSV, academicYear); err != nil {
+			result.ErrorRows = append(result.ErrorRows, models.CSVErrorRow{
+				Row:   rowNum,
+				Error: fmt.Sprintf("保存エラー: %v", err),
+				Data:  strings.Join(record, ","),
+			})
+			continue
+		}
+
+		result.ProcessedRows++
+	}
+
+	if len(result.ErrorRows) > 0 {
+		result.Success = false
+		result.Errors = append(result.Errors, fmt.Sprintf("%d行でエラーが発生しました", len(result.ErrorRows)))
+	}
+
+	return result, nil
+}
+
 // 担当者CSVバリデーション
 func (s *CSVService) validateSubjectCSV(data models.SubjectCSV) error {
 	if data.SubjectCode == "" {
@@ -223,6 +364,17 @@ func (s *CSVService) validateTimetableCSV(data models.TimetableCSV) error {
 	return nil
 }
 
+// 学生名簿CSVバリデーション
+func (s *CSVService) validateEnrollmentCSV(data models.EnrollmentCSV) error {
+	if data.Name == "" {
+		return fmt.Errorf("氏名が必須です")
+	}
+	if data.Email == "" || !strings.Contains(data.Email, "@") {
+		return fmt.Errorf("メールアドレスが不正です")
+	}
+	return s.validateTimetableCSV(models.TimetableCSV{Class: data.Class})
+}
+
 // 担当者データ保存
 func (s *CSVService) saveSubjectFromCSV(data models.SubjectCSV) error {
 	// トランザクション開始
@@ -258,6 +410,167 @@ func (s *CSVService) saveSubjectFromCSV(data models.SubjectCSV) error {
 		if err != nil {
 			return fmt.Errorf("クラス保存エラー: %v", err)
 		}
+
+		// 実施場所・担当教員の保存
+		if err := s.saveSubjectAssignment(tx, data, grade, className); err != nil {
+			return err
+		}
+	}
+
+	// 学科の紐付け（学科列がある場合）
+	if data.Department != "" {
+		if err := s.saveDepartmentMembership(tx, data); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// 科目・クラスごとの実施場所と担当教員の保存（教員は氏名で照合し、未登録の教員は無視する）
+func (s *CSVService) saveSubjectAssignment(tx *sql.Tx, data models.SubjectCSV, grade int, className string) error {
+	// 実施場所は教室マスタの教室名に読み替える（未登録の教室は登録する）
+	room, err := resolveOrCreateRoom(tx, data.Room)
+	if err != nil {
+		return fmt.Errorf("実施場所処理エラー: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO subject_assignments (subject_id, class_id, room, work_type)
+		SELECT s.id, c.id, ?, ?
+		FROM subjects s, classes c
+		WHERE s.code = ? AND c.grade = ? AND c.class_name = ?
+		ON DUPLICATE KEY UPDATE room = VALUES(room), work_type = VALUES(work_type)
+	`, room, data.WorkType, data.SubjectCode, grade, className)
+	if err != nil {
+		return fmt.Errorf("担当者保存エラー: %v", err)
+	}
+
+	var assignmentID int
+	err = tx.QueryRow(`
+		SELECT sa.id FROM subject_assignments sa
+		JOIN subjects s ON sa.subject_id = s.id
+		JOIN classes c ON sa.class_id = c.id
+		WHERE s.code = ? AND c.grade = ? AND c.class_name = ?
+	`, data.SubjectCode, grade, className).Scan(&assignmentID)
+	if err != nil {
+		return fmt.Errorf("担当者保存エラー: %v", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM subject_assignment_teachers WHERE assignment_id = ?", assignmentID); err != nil {
+		return fmt.Errorf("担当教員保存エラー: %v", err)
+	}
+
+	for i, name := range []string{data.Teacher1, data.Teacher2, data.Teacher3} {
+		if name == "" {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT IGNORE INTO subject_assignment_teachers (assignment_id, teacher_id, position)
+			SELECT ?, id, ? FROM users WHERE name = ? AND role IN ('teacher', 'admin')
+		`, assignmentID, i+1, name)
+		if err != nil {
+			return fmt.Errorf("担当教員保存エラー: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// 学科の紐付け保存（科目・担当教員・2年生以上のクラス）
+func (s *CSVService) saveDepartmentMembership(tx *sql.Tx, data models.SubjectCSV) error {
+	var departmentID int
+	err := tx.QueryRow("SELECT id FROM departments WHERE code = ? OR name = ? OR short_name = ?",
+		data.Department, data.Department, data.Department).Scan(&departmentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("学科が見つかりません: %s", data.Department)
+		}
+		return fmt.Errorf("学科取得エラー: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO department_subjects (department_id, subject_id)
+		SELECT ?, id FROM subjects WHERE code = ?
+	`, departmentID, data.SubjectCode)
+	if err != nil {
+		return fmt.Errorf("学科科目保存エラー: %v", err)
+	}
+
+	for _, teacherName := range []string{data.Teacher1, data.Teacher2, data.Teacher3} {
+		if teacherName == "" {
+			continue
+		}
+		_, err = tx.Exec(`
+			INSERT IGNORE INTO department_teachers (department_id, user_id)
+			SELECT ?, id FROM users WHERE name = ? AND role IN ('teacher', 'admin')
+		`, departmentID, teacherName)
+		if err != nil {
+			return fmt.Errorf("学科教員保存エラー: %v", err)
+		}
+	}
+
+	// 1年生は混合学級のため学科に所属させない
+	classParts := strings.Split(data.Class, "-")
+	if len(classParts) == 2 {
+		grade, _ := strconv.Atoi(classParts[0])
+		if grade >= 2 {
+			_, err = tx.Exec("UPDATE classes SET department_id = ? WHERE grade = ? AND class_name = ?",
+				departmentID, grade, classParts[1])
+			if err != nil {
+				return fmt.Errorf("クラス学科保存エラー: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 学生名簿データ保存（未登録の学生はパスワードログイン不可のアカウントとして作成）
+func (s *CSVService) saveEnrollmentFromCSV(data models.EnrollmentCSV, academicYear int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	classParts := strings.Split(data.Class, "-")
+	grade, _ := strconv.Atoi(classParts[0])
+
+	var classID int
+	err = tx.QueryRow("SELECT id FROM classes WHERE grade = ? AND class_name = ?", grade, classParts[1]).Scan(&classID)
+	if err != nil {
+		return fmt.Errorf("クラスが見つかりません: %s", data.Class)
+	}
+
+	var studentID int
+	var role string
+	err = tx.QueryRow("SELECT id, role FROM users WHERE email = ?", data.Email).Scan(&studentID, &role)
+	if err == sql.ErrNoRows {
+		// シングルサインオンで利用するためパスワードは設定しない
+		result, err := tx.Exec("INSERT INTO users (email, password_hash, name, role) VALUES (?, '!', ?, ?)",
+			data.Email, data.Name, models.RoleStudent)
+		if err != nil {
+			return fmt.Errorf("学生作成エラー: %v", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		studentID = int(id)
+	} else if err != nil {
+		return err
+	} else if role != models.RoleStudent {
+		return fmt.Errorf("学生以外のユーザーです: %s", data.Email)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO enrollments (student_id, class_id, academic_year, student_number)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE class_id = VALUES(class_id), student_number = VALUES(student_number)
+	`, studentID, classID, academicYear, nullString(data.StudentNumber))
+	if err != nil {
+		return fmt.Errorf("在籍登録エラー: %v", err)
 	}
 
 	return tx.Commit()
@@ -331,44 +644,187 @@ func (s *CSVService) saveTimetableFromCSV(data models.TimetableCSV) error {
 		return fmt.Errorf("既存データ削除エラー: %v", err)
 	}
 
-	// 時間割データマップ
-	timetableMap := map[string]map[int]string{
+	// 各時間割データを保存（「選択A:数学/選択B:物理」のようにグループ別の並行授業を指定できる）
+	for day, periods := range timetableCells(data) {
+		for period, cell := range periods {
+			for _, lesson := range parseTimetableCell(cell) {
+				// 科目ID取得（存在しない場合は作成）
+				subjectID, err := s.getOrCreateSubjectID(tx, lesson.SubjectName)
+				if err != nil {
+					return fmt.Errorf("科目処理エラー: %v", err)
+				}
+
+				// グループID取得（存在しない場合は作成）
+				var groupID interface{}
+				if lesson.GroupName != "" {
+					id, err := s.getOrCreateClassGroupID(tx, classID, lesson.GroupName)
+					if err != nil {
+						return fmt.Errorf("グループ処理エラー: %v", err)
+					}
+					groupID = id
+				}
+
+				// 担当教員・実施場所は担当者CSVの登録内容を使用（未登録の場合はデフォルト教員・未定）
+				t := models.TimetableSnapshot{SubjectID: subjectID, ClassID: classID, Room: "未定"}
+				if err := resolveSubjectAssignment(tx, &t); err != nil {
+					return fmt.Errorf("担当者処理エラー: %v", err)
+				}
+				if t.TeacherID == 0 {
+					t.TeacherID = 1 // デフォルト教員ID
+				}
+
+				// 時間割データ挿入
+				insertQuery := `
+					INSERT INTO timetables (class_id, group_id, subject_id, teacher_id, day_of_week, period, room, created_at, updated_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+				`
+				result, err := tx.Exec(insertQuery, classID, groupID, subjectID, t.TeacherID, day, period, t.Room)
+				if err != nil {
+					return fmt.Errorf("時間割挿入エラー: %v", err)
+				}
+
+				// 共同担当教員
+				if len(t.CoTeacherIDs) > 0 {
+					timetableID, err := result.LastInsertId()
+					if err != nil {
+						return err
+					}
+					for _, teacherID := range t.CoTeacherIDs {
+						_, err := tx.Exec("INSERT IGNORE INTO timetable_teachers (timetable_id, teacher_id) VALUES (?, ?)", timetableID, teacherID)
+						if err != nil {
+							return fmt.Errorf("共同担当教員挿入エラー: %v", err)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// timetableCSVFromRecord - 時間割CSVの1行（21列）を読み込む
+func timetableCSVFromRecord(record []string) models.TimetableCSV {
+	return models.TimetableCSV{
+		Class: strings.TrimSpace(record[0]),
+		Mon1:  strings.TrimSpace(record[1]),
+		Mon2:  strings.TrimSpace(record[2]),
+		Mon3:  strings.TrimSpace(record[3]),
+		Mon4:  strings.TrimSpace(record[4]),
+		Tue1:  strings.TrimSpace(record[5]),
+		Tue2:  strings.TrimSpace(record[6]),
+		Tue3:  strings.TrimSpace(record[7]),
+		Tue4:  strings.TrimSpace(record[8]),
+		Wed1:  strings.TrimSpace(record[9]),
+		Wed2:  strings.TrimSpace(record[10]),
+		Wed3:  strings.TrimSpace(record[11]),
+		Wed4:  strings.TrimSpace(record[12]),
+		Thu1:  strings.TrimSpace(record[13]),
+		Thu2:  strings.TrimSpace(record[14]),
+		Thu3:  strings.TrimSpace(record[15]),
+		Thu4:  strings.TrimSpace(record[16]),
+		Fri1:  strings.TrimSpace(record[17]),
+		Fri2:  strings.TrimSpace(record[18]),
+		Fri3:  strings.TrimSpace(record[19]),
+		Fri4:  strings.TrimSpace(record[20]),
+	}
+}
+
+// timetableCells - 時間割データマップ（曜日 → 時限 → セル）
+func timetableCells(data models.TimetableCSV) map[string]map[int]string {
+	return map[string]map[int]string{
 		"monday":    {1: data.Mon1, 2: data.Mon2, 3: data.Mon3, 4: data.Mon4},
 		"tuesday":   {1: data.Tue1, 2: data.Tue2, 3: data.Tue3, 4: data.Tue4},
 		"wednesday": {1: data.Wed1, 2: data.Wed2, 3: data.Wed3, 4: data.Wed4},
 		"thursday":  {1: data.Thu1, 2: data.Thu2, 3: data.Thu3, 4: data.Thu4},
 		"friday":    {1: data.Fri1, 2: data.Fri2, 3: data.Fri3, 4: data.Fri4},
 	}
+}
 
-	// 各時間割データを保存
-	for day, periods := range timetableMap {
-		for period, subjectName := range periods {
-			if subjectName == "" || subjectName == "空" {
-				continue
-			}
+// resolveSubjectAssignment - 担当者CSVで登録された担当教員・実施場所をコマに設定
+func resolveSubjectAssignment(q queryer, t *models.TimetableSnapshot) error {
+	rows, err := q.Query(`
+		SELECT sa.room, sat.teacher_id
+		FROM subject_assignments sa
+		LEFT JOIN subject_assignment_teachers sat ON sat.assignment_id = sa.id
+		WHERE sa.subject_id = ? AND sa.class_id = ?
+		ORDER BY sat.position
+	`, t.SubjectID, t.ClassID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-			// 科目ID取得（存在しない場合は作成）
-			subjectID, err := s.getOrCreateSubjectID(tx, subjectName)
-			if err != nil {
-				return fmt.Errorf("科目処理エラー: %v", err)
-			}
+	for rows.Next() {
+		var room string
+		var teacherID sql.NullInt64
+		if err := rows.Scan(&room, &teacherID); err != nil {
+			return err
+		}
+		if room != "" {
+			t.Room = room
+		}
+		if !teacherID.Valid {
+			continue
+		}
+		if t.TeacherID == 0 {
+			t.TeacherID = int(teacherID.Int64)
+		} else {
+			t.CoTeacherIDs = append(t.CoTeacherIDs, int(teacherID.Int64))
+		}
+	}
 
-			// 教員ID取得（デフォルト教員を使用）
-			teacherID := 1 // デフォルト教員ID
+	return rows.Err()
+}
 
-			// 時間割データ挿入
-			insertQuery := `
-				INSERT INTO timetables (class_id, subject_id, teacher_id, day_of_week, period, room, created_at, updated_at)
-				VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())
-			`
-			_, err = tx.Exec(insertQuery, classID, subjectID, teacherID, day, period, "未定")
-			if err != nil {
-				return fmt.Errorf("時間割挿入エラー: %v", err)
-			}
+// 時間割CSVのセルに記載された授業
+type timetableCellLesson struct {
+	GroupName   string
+	SubjectName string
+}
+
+// parseTimetableCell - セルを授業ごとに分解（「/」区切りで並行授業、「グループ名:科目名」でグループ指定）
+func parseTimetableCell(cell string) []timetableCellLesson {
+	cell = strings.NewReplacer("／", "/", "：", ":").Replace(cell)
+
+	var lessons []timetableCellLesson
+	for _, part := range strings.Split(cell, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "空" {
+			continue
 		}
+
+		lesson := timetableCellLesson{SubjectName: part}
+		if i := strings.Index(part, ":"); i > 0 {
+			lesson.GroupName = strings.TrimSpace(part[:i])
+			lesson.SubjectName = strings.TrimSpace(part[i+1:])
+		}
+		if lesson.SubjectName == "" {
+			continue
+		}
+		lessons = append(lessons, lesson)
 	}
+	return lessons
+}
 
-	return tx.Commit()
+// クラス内グループID取得または作成
+func (s *CSVService) getOrCreateClassGroupID(tx *sql.Tx, classID int, name string) (int, error) {
+	var groupID int
+	err := tx.QueryRow("SELECT id FROM class_groups WHERE class_id = ? AND name = ?", classID, name).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		result, err := tx.Exec("INSERT INTO class_groups (class_id, name) VALUES (?, ?)", classID, name)
+		if err != nil {
+			return 0, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		return int(id), nil
+	} else if err != nil {
+		return 0, err
+	}
+	return groupID, nil
 }
 
 // 科目ID取得または作成
@@ -440,11 +896,12 @@ func (s *CSVService) getClassesForExport(filter map[string]interface{}) ([]struc
 // クラス別時間割データ取得
 func (s *CSVService) getTimetableDataForClass(classID int) (map[string]map[int]string, error) {
 	query := `
-		SELECT t.day_of_week, t.period, s.name
+		SELECT t.day_of_week, t.period, s.name, COALESCE(g.name, '')
 		FROM timetables t
 		JOIN subjects s ON t.subject_id = s.id
+		LEFT JOIN class_groups g ON t.group_id = g.id
 		WHERE t.class_id = ?
-		ORDER BY t.day_of_week, t.period
+		ORDER BY t.day_of_week, t.period, g.name
 	`
 
 	rows, err := s.db.Query(query, classID)
@@ -466,12 +923,21 @@ func (s *CSVService) getTimetableDataForClass(classID int) (map[string]map[int]s
 		var day string
 		var period int
 		var subjectName string
+		var groupName string
 
-		if err := rows.Scan(&day, &period, &subjectName); err != nil {
+		if err := rows.Scan(&day, &period, &subjectName, &groupName); err != nil {
 			return nil, err
 		}
 
+		// グループ別の授業は「グループ名:科目名」を「/」でつなげる
+		if groupName != "" {
+			subjectName = groupName + ":" + subjectName
+		}
+
 		if periods, ok := timetableData[day]; ok {
+			if periods[period] != "" {
+				subjectName = periods[period] + "/" + subjectName
+			}
 			periods[period] = subjectName
 		}
 	}
@@ -485,18 +951,19 @@ func (s *CSVService) ExportSubjects(writer io.Writer, filter map[string]interfac
 	defer csvWriter.Flush()
 
 	// ヘッダー書き込み
-	header := []string{"科目コード", "クラス", "実施場所", "科目", "勤務形態", "教員１", "教員２", "教員３"}
+	header := []string{"科目コード", "クラス", "実施場所", "科目", "勤務形態", "教員１", "教員２", "教員３", "学科"}
 	if err := csvWriter.Write(header); err != nil {
 		return fmt.Errorf("ヘッダー書き込みエラー: %v", err)
 	}
 
 	// データ取得・書き込み
 	query := `
-		SELECT s.code, CONCAT(c.grade, '-', c.class_name), t.room, s.name, '常勤', u.name, '', ''
+		SELECT s.code, CONCAT(c.grade, '-', c.class_name), t.room, s.name, '常勤', u.name, '', '', COALESCE(d.code, '')
 		FROM subjects s
 		JOIN timetables t ON s.id = t.subject_id
 		JOIN classes c ON t.class_id = c.id
 		JOIN users u ON t.teacher_id = u.id
+		LEFT JOIN departments d ON c.department_id = d.id
 		GROUP BY s.id, c.id
 		ORDER BY c.grade, c.class_name, s.code
 	`
@@ -508,8 +975,8 @@ func (s *CSVService) ExportSubjects(writer io.Writer, filter map[string]interfac
 	defer rows.Close()
 
 	for rows.Next() {
-		var record []string = make([]string, 8)
-		if err := rows.Scan(&record[0], &record[1], &record[2], &record[3], &record[4], &record[5], &record[6], &record[7]); err != nil {
+		var record []string = make([]string, 9)
+		if err := rows.Scan(&record[0], &record[1], &record[2], &record[3], &record[4], &record[5], &record[6], &record[7], &record[8]); err != nil {
 			return fmt.Errorf("データ読み込みエラー: %v", err)
 		}
 
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// 送信時刻を過ぎたかを確認する間隔
+const digestCheckInterval = 5 * time.Minute
+
+// 次の授業日を探す日数（授業のある曜日が見つからなければ送信しない）
+const digestLookahead = 7
+
+// ParseDigestTime - 送信時刻（"18:00" の形式）を 0 時からの経過時間にする
+func ParseDigestTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("時刻は HH:MM の形式で指定してください: %s", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// DigestScheduler - 毎日決まった時刻に、次の授業日の時間割の変更を関係者ごとに1通にまとめて通知する
+// 受け取るのは変更される授業の教員・代講の教員・受講する学生と、そのクラスの担任
+// 対象日ごとに digest_runs に記録し、同じ日の分は一度しか送らない
+type DigestScheduler struct {
+	db     *sql.DB
+	sendAt time.Duration
+}
+
+func NewDigestScheduler(db *sql.DB, sendAt time.Duration) *DigestScheduler {
+	return &DigestScheduler{db: db, sendAt: sendAt}
+}
+
+// Start - ctx が終了するまでバックグラウンドで定期的に確認する
+func (s *DigestScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(digestCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunOnce(time.Now()); err != nil {
+				log.Printf("daily digest: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce - now がその日の送信時刻を過ぎていれば、次の授業日の変更のまとめを送る（送信済みなら何もしない）
+func (s *DigestScheduler) RunOnce(now time.Time) error {
+	if now.Before(truncateDate(now).Add(s.sendAt)) {
+		return nil
+	}
+
+	target, ok, err := nextSchoolDay(s.db, now)
+	if err != nil {
+		return fmt.Errorf("次の授業日の確認に失敗しました: %v", err)
+	}
+	if !ok {
+		return nil
+	}
+	if err := s.send(target); err != nil {
+		return fmt.Errorf("%s の変更のまとめの送信に失敗しました: %v", target.Format(dateLayout), err)
+	}
+	return nil
+}
+
+// nextSchoolDay - now の翌日以降で、週間時間割に授業のある最初の日
+// 祝日・休業日の暦は持っていないため、授業のある曜日だけで判断する
+func nextSchoolDay(q queryer, now time.Time) (time.Time, bool, error) {
+	rows, err := q.Query("SELECT DISTINCT day_of_week FROM timetables")
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]bool)
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return time.Time{}, false, err
+		}
+		days[day] = true
+	}
+	if err := rows.Err(); err != nil {
+		return time.Time{}, false, err
+	}
+
+	d := truncateDate(now)
+	for i := 0; i < digestLookahead; i++ {
+		d = d.AddDate(0, 0, 1)
+		if days[weekdayName(d)] {
+			return d, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// send - target の日の変更を受け取る人ごとにまとめて通知する（通知とメールの登録は1つのトランザクションで行う）
+func (s *DigestScheduler) send(target time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 他の処理が同じ日の分を送っていれば何もしない
+	result, err := tx.Exec("INSERT IGNORE INTO digest_runs (target_date) VALUES (?)", target.Format(dateLayout))
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+
+	lines, err := digestLines(tx, target)
+	if err != nil {
+		return err
+	}
+
+	userIDs := make([]int, 0, len(lines))
+	for userID := range lines {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+
+	title := fmt.Sprintf("%d月%d日（%s）の時間割の変更", target.Month(), target.Day(), dayLabel(weekdayName(target)))
+	for _, userID := range userIDs {
+		err := notifyUsers(tx, []int{userID}, &models.Notification{
+			Title:   title,
+			Message: strings.Join(lines[userID], "\n"),
+			Type:    models.NotificationInfo,
+			Event:   models.NotificationDailyDigest,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE digest_runs SET recipients = ? WHERE target_date = ?", len(userIDs), target.Format(dateLayout)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// digestLines - target の日に変更のある授業を、受け取る人ごとの説明の行にする（時限・クラスの順）
+func digestLines(q queryer, target time.Time) (map[int][]string, error) {
+	lessons, err := loadEffectiveLessons(q, target, target)
+	if err != nil {
+		return nil, err
+	}
+	homerooms, err := homeroomTeachers(q)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[int][]string)
+	for i := range lessons {
+		lesson := &lessons[i]
+		if lesson.Status == models.LessonRegular {
+			continue
+		}
+
+		line, err := digestLine(q, lesson)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients, err := lessonUserIDs(q, []int{lesson.ID})
+		if err != nil {
+			return nil, err
+		}
+		// 代講の教員（変更を反映した授業の担当）と担任
+		recipients = append(recipients, lesson.TeacherID)
+		recipients = append(recipients, lesson.CoTeacherIDs...)
+		if teacherID, ok := homerooms[lesson.ClassID]; ok {
+			recipients = append(recipients, teacherID)
+		}
+
+		seen := make(map[int]bool)
+		for _, userID := range recipients {
+			if userID == 0 || seen[userID] {
+				continue
+			}
+			seen[userID] = true
+			lines[userID] = append(lines[userID], line)
+		}
+	}
+	return lines, nil
+}
+
+// digestLine - 変更のある授業の説明（例: 「3-2 2限「数学」：休講」）
+func digestLine(q queryer, lesson *models.EffectiveLesson) (string, error) {
+	line := fmt.Sprintf("%d-%s %d限「%s」", lesson.Grade, lesson.ClassName, lesson.Period, lesson.SubjectName)
+	if lesson.GroupName != "" {
+		line = fmt.Sprintf("%d-%s（%s） %d限「%s」", lesson.Grade, lesson.ClassName, lesson.GroupName, lesson.Period, lesson.SubjectName)
+	}
+
+	switch lesson.Status {
+	case models.LessonCanceled:
+		line += "：休講"
+	case models.LessonMovedOut:
+		detail := "：移動"
+		if len(lesson.OverrideIDs) > 0 {
+			var newDate sql.NullTime
+			var newPeriod sql.NullInt64
+			err := q.QueryRow("SELECT new_date, new_period FROM timetable_overrides WHERE id = ?",
+				lesson.OverrideIDs[len(lesson.OverrideIDs)-1]).Scan(&newDate, &newPeriod)
+			if err != nil && err != sql.ErrNoRows {
+				return "", err
+			}
+			if newDate.Valid && newPeriod.Valid {
+				detail = fmt.Sprintf("：%s %d限に移動", newDate.Time.Format(dateLayout), newPeriod.Int64)
+			} else if newPeriod.Valid {
+				detail = fmt.Sprintf("：%d限に移動", newPeriod.Int64)
+			} else if newDate.Valid {
+				detail = fmt.Sprintf("：%s に移動", newDate.Time.Format(dateLayout))
+			}
+		}
+		line += detail
+	case models.LessonMovedIn:
+		line += fmt.Sprintf("：%s %d限から移動", lesson.OriginalDate, lesson.OriginalPeriod)
+		if lesson.Room != "" {
+			line += fmt.Sprintf("（%s）", lesson.Room)
+		}
+	case models.LessonSubstituted:
+		line += fmt.Sprintf("：代講（%s）", lesson.TeacherName)
+	case models.LessonRoomChanged:
+		line += fmt.Sprintf("：教室変更（%s）", lesson.Room)
+	}
+
+	if lesson.Note != "" {
+		line += "　備考：" + lesson.Note
+	}
+	return line, nil
+}
+
+// homeroomTeachers - クラスごとの担任
+func homeroomTeachers(q queryer) (map[int]int, error) {
+	rows, err := q.Query("SELECT id, homeroom_teacher_id FROM classes WHERE homeroom_teacher_id IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	homerooms := make(map[int]int)
+	for rows.Next() {
+		var classID, teacherID int
+		if err := rows.Scan(&classID, &teacherID); err != nil {
+			return nil, err
+		}
+		homerooms[classID] = teacherID
+	}
+	return homerooms, rows.Err()
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type DepartmentService struct {
+	db *sql.DB
+}
+
+func NewDepartmentService(db *sql.DB) *DepartmentService {
+	return &DepartmentService{db: db}
+}
+
+// 学科一覧取得
+func (s *DepartmentService) GetDepartments() ([]models.Department, error) {
+	query := squirrel.Select("id", "code", "name", "short_name", "created_at", "updated_at").
+		From("departments").
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	departments := []models.Department{}
+	for rows.Next() {
+		var d models.Department
+		if err := rows.Scan(&d.ID, &d.Code, &d.Name, &d.ShortName, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		departments = append(departments, d)
+	}
+
+	return departments, nil
+}
+
+// 学科取得（ID指定）
+func (s *DepartmentService) GetDepartmentByID(id int) (*models.Department, error) {
+	query := squirrel.Select("id", "code", "name", "short_name", "created_at", "updated_at").
+		From("departments").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	var d models.Department
+	err = s.db.QueryRow(sqlStr, args...).Scan(&d.ID, &d.Code, &d.Name, &d.ShortName, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("department not found")
+		}
+		return nil, fmt.Errorf("failed to get department: %v", err)
+	}
+
+	return &d, nil
+}
+
+// 学科作成
+func (s *DepartmentService) CreateDepartment(req *models.CreateDepartmentRequest) (*models.Department, error) {
+	query := squirrel.Insert("departments").
+		Columns("code", "name", "short_name").
+		Values(req.Code, req.Name, req.ShortName).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetDepartmentByID(int(id))
+}
+
+// 学科更新
+func (s *DepartmentService) UpdateDepartment(id int, req *models.UpdateDepartmentRequest) (*models.Department, error) {
+	if req.Code == "" && req.Name == "" && req.ShortName == nil {
+		return s.GetDepartmentByID(id)
+	}
+
+	query := squirrel.Update("departments").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Question)
+
+	if req.Code != "" {
+		query = query.Set("code", req.Code)
+	}
+	if req.Name != "" {
+		query = query.Set("name", req.Name)
+	}
+	if req.ShortName != nil {
+		query = query.Set("short_name", *req.ShortName)
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec(sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	return s.GetDepartmentByID(id)
+}
+
+// 学科削除
+func (s *DepartmentService) DeleteDepartment(id int) error {
+	result, err := s.db.Exec("DELETE FROM departments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("department not found")
+	}
+
+	return nil
+}
+
+// 学科所属教員一覧
+func (s *DepartmentService) GetDepartmentTeachers(id int) ([]models.User, error) {
+	query := squirrel.Select("u.id", "u.name", "u.email", "u.role", "u.created_at").
+		From("department_teachers dt").
+		Join("users u ON dt.user_id = u.id").
+		Where(squirrel.Eq{"dt.department_id": id}).
+		OrderBy("u.name").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// 学科開講科目一覧
+func (s *DepartmentService) GetDepartmentSubjects(id int) ([]models.Subject, error) {
+	query := squirrel.Select("s.id", "s.code", "s.name", "s.term", "s.credits", "s.created_at").
+		From("department_subjects ds").
+		Join("subjects s ON ds.subject_id = s.id").
+		Where(squirrel.Eq{"ds.department_id": id}).
+		OrderBy("s.code").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	subjects := []models.Subject{}
+	for rows.Next() {
+		var subject models.Subject
+		if err := rows.Scan(&subject.ID, &subject.Code, &subject.Name, &subject.Term, &subject.Credits, &subject.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, nil
+}
+
+// 教員を学科に追加
+func (s *DepartmentService) AddTeacher(departmentID, userID int) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO department_teachers (department_id, user_id) VALUES (?, ?)", departmentID, userID)
+	return err
+}
+
+// 教員を学科から外す
+func (s *DepartmentService) RemoveTeacher(departmentID, userID int) error {
+	_, err := s.db.Exec("DELETE FROM department_teachers WHERE department_id = ? AND user_id = ?", departmentID, userID)
+	return err
+}
+
+// 科目を学科に追加
+func (s *DepartmentService) AddSubject(departmentID, subjectID int) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO department_subjects (department_id, subject_id) VALUES (?, ?)", departmentID, subjectID)
+	return err
+}
+
+// 科目を学科から外す
+func (s *DepartmentService) RemoveSubject(departmentID, subjectID int) error {
+	_, err := s.db.Exec("DELETE FROM department_subjects WHERE department_id = ? AND subject_id = ?", departmentID, subjectID)
+	return err
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type EnrollmentService struct {
+	db *sql.DB
+}
+
+func NewEnrollmentService(db *sql.DB) *EnrollmentService {
+	return &EnrollmentService{db: db}
+}
+
+func enrollmentSelect() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"e.id", "e.student_id", "e.class_id", "e.academic_year", "COALESCE(e.student_number, '')", "e.created_at",
+		"u.name", "u.email", "c.grade", "c.class_name",
+	).
+		From("enrollments e").
+		Join("users u ON e.student_id = u.id").
+		Join("classes c ON e.class_id = c.id").
+		PlaceholderFormat(squirrel.Question)
+}
+
+func scanEnrollment(scanner interface{ Scan(...interface{}) error }) (*models.Enrollment, error) {
+	var e models.Enrollment
+	err := scanner.Scan(
+		&e.ID, &e.StudentID, &e.ClassID, &e.AcademicYear, &e.StudentNumber, &e.CreatedAt,
+		&e.StudentName, &e.StudentEmail, &e.Grade, &e.ClassName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// GetStudentEnrollment - 学生の指定年度の所属クラス取得（未登録なら nil）
+func (s *EnrollmentService) GetStudentEnrollment(studentID, academicYear int) (*models.Enrollment, error) {
+	query := enrollmentSelect().
+		Where(squirrel.Eq{"e.student_id": studentID, "e.academic_year": academicYear})
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	e, err := scanEnrollment(s.db.QueryRow(sqlStr, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get enrollment: %v", err)
+	}
+
+	return e, nil
+}
+
+// GetClassEnrollments - クラスの在籍学生一覧
+func (s *EnrollmentService) GetClassEnrollments(classID, academicYear int) ([]models.Enrollment, error) {
+	query := enrollmentSelect().
+		Where(squirrel.Eq{"e.class_id": classID, "e.academic_year": academicYear}).
+		OrderBy("e.student_number", "u.name")
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	enrollments := []models.Enrollment{}
+	for rows.Next() {
+		e, err := scanEnrollment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		enrollments = append(enrollments, *e)
+	}
+
+	return enrollments, nil
+}
+
+// EnrollStudent - 学生をクラスに登録（同じ年度の登録があれば置き換え）
+func (s *EnrollmentService) EnrollStudent(req *models.CreateEnrollmentRequest) (*models.Enrollment, error) {
+	academicYear := req.AcademicYear
+	if academicYear == 0 {
+		academicYear = models.AcademicYearOf(time.Now())
+	}
+
+	var role string
+	err := s.db.QueryRow("SELECT role FROM users WHERE id = ?", req.StudentID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("student not found")
+		}
+		return nil, err
+	}
+	if role != models.RoleStudent {
+		return nil, fmt.Errorf("学生以外のユーザーは登録できません")
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO enrollments (student_id, class_id, academic_year, student_number)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE class_id = VALUES(class_id), student_number = VALUES(student_number)
+	`, req.StudentID, req.ClassID, academicYear, nullString(req.StudentNumber))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enroll student: %v", err)
+	}
+
+	return s.GetStudentEnrollment(req.StudentID, academicYear)
+}
+
+// DeleteEnrollment - 在籍登録削除
+func (s *EnrollmentService) DeleteEnrollment(id int) error {
+	result, err := s.db.Exec("DELETE FROM enrollments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("enrollment not found")
+	}
+
+	return nil
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// 購読者ごとに溜めておけるイベントの数（受け取りが追いつかない購読者の分は捨てる）
+const subscriptionBuffer = 16
+
+// EventBroker - 時間割の変更をプロセス内で購読者に配信する
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscription - 購読の条件と受信用のチャネル
+// UserID の授業・クラスに影響する変更と、ClassIDs・TeacherIDs・Rooms のいずれかに該当する変更を受け取る
+type Subscription struct {
+	UserID     int
+	ClassIDs   []int
+	TeacherIDs []int
+	Rooms      []string
+
+	C chan models.TimetableEvent
+}
+
+// Subscribe - 購読を始める（終わったら Unsubscribe する）
+func (b *EventBroker) Subscribe(sub *Subscription) *Subscription {
+	sub.C = make(chan models.TimetableEvent, subscriptionBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe - 購読をやめてチャネルを閉じる
+func (b *EventBroker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.C)
+	}
+}
+
+// Publish - 条件に該当する購読者へイベントを送る（b が nil なら何もしない、送れない購読者は待たずに飛ばす）
+func (b *EventBroker) Publish(event *models.TimetableEvent) {
+	if b == nil || event == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.C <- *event:
+		default:
+		}
+	}
+}
+
+func (s *Subscription) matches(e *models.TimetableEvent) bool {
+	for _, id := range e.UserIDs {
+		if id == s.UserID {
+			return true
+		}
+	}
+	for _, id := range e.ClassIDs {
+		if containsInt(s.ClassIDs, id) {
+			return true
+		}
+	}
+	for _, id := range e.TeacherIDs {
+		if containsInt(s.TeacherIDs, id) {
+			return true
+		}
+	}
+	for _, room := range e.Rooms {
+		if containsString(s.Rooms, room) {
+			return true
+		}
+	}
+	return false
+}
+
+// applicationEvent - 反映記録から、影響するクラス・教員・教室・教員と学生をまとめたイベントを作る
+// 日付指定の変更は削除する前に呼び出す
+func applicationEvent(q queryer, eventType string, requestID int, applications []models.ChangeRequestApplication) (*models.TimetableEvent, error) {
+	title, err := requestTitle(q, requestID)
+	if err != nil {
+		return nil, err
+	}
+	event := newTimetableEvent(eventType, title)
+	event.ChangeRequestID = &requestID
+
+	for _, a := range applications {
+		for _, snapshot := range []*models.TimetableSnapshot{a.Before, a.After} {
+			if snapshot == nil {
+				continue
+			}
+			addLessonToEvent(event, snapshot.ClassID, snapshot.Room, append([]int{snapshot.TeacherID}, snapshot.CoTeacherIDs...)...)
+		}
+		if a.OverrideID != nil {
+			if err := addOverrideToEvent(q, event, *a.OverrideID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	event.UserIDs, err = applicationUserIDs(q, applications)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// overrideEvent - 日付指定の変更（申請によらないもの）のイベントを作る
+func overrideEvent(q queryer, overrideIDs ...int) (*models.TimetableEvent, error) {
+	event := newTimetableEvent(models.TimetableEventChanged, "時間割が変更されました")
+	var timetableIDs []int
+	for _, id := range overrideIDs {
+		if err := addOverrideToEvent(q, event, id); err != nil {
+			return nil, err
+		}
+		var timetableID int
+		if err := q.QueryRow("SELECT timetable_id FROM timetable_overrides WHERE id = ?", id).Scan(&timetableID); err != nil {
+			return nil, err
+		}
+		timetableIDs = append(timetableIDs, timetableID)
+	}
+
+	userIDs, err := lessonUserIDs(q, timetableIDs)
+	if err != nil {
+		return nil, err
+	}
+	event.UserIDs = append(event.UserIDs, userIDs...)
+	event.UserIDs = append(event.UserIDs, event.TeacherIDs...)
+	return event, nil
+}
+
+func newTimetableEvent(eventType, title string) *models.TimetableEvent {
+	return &models.TimetableEvent{
+		Type:       eventType,
+		Title:      title,
+		ClassIDs:   []int{},
+		TeacherIDs: []int{},
+		Rooms:      []string{},
+		OccurredAt: time.Now(),
+	}
+}
+
+// addOverrideToEvent - 日付指定の変更の対象の授業と、代講の教員・変更後の教室・日付をイベントに加える
+func addOverrideToEvent(q queryer, event *models.TimetableEvent, overrideID int) error {
+	var classID, teacherID int
+	var room, newRoom string
+	var date time.Time
+	var newDate sql.NullTime
+	var newTeacherID, replacedTeacherID sql.NullInt64
+	err := q.QueryRow(`
+		SELECT t.class_id, t.teacher_id, COALESCE(t.room, ''), o.date, o.new_date, o.new_teacher_id, o.replaced_teacher_id,
+		       COALESCE(o.new_room, '')
+		FROM timetable_overrides o
+		JOIN timetables t ON o.timetable_id = t.id
+		WHERE o.id = ?
+	`, overrideID).Scan(&classID, &teacherID, &room, &date, &newDate, &newTeacherID, &replacedTeacherID, &newRoom)
+	if err != nil {
+		return err
+	}
+
+	addLessonToEvent(event, classID, room, teacherID)
+	if newTeacherID.Valid {
+		event.TeacherIDs = appendUniqueInt(event.TeacherIDs, int(newTeacherID.Int64))
+	}
+	if replacedTeacherID.Valid {
+		event.TeacherIDs = appendUniqueInt(event.TeacherIDs, int(replacedTeacherID.Int64))
+	}
+	if newRoom != "" {
+		event.Rooms = appendUniqueString(event.Rooms, newRoom)
+	}
+	event.Dates = appendUniqueString(event.Dates, date.Format(dateLayout))
+	if newDate.Valid {
+		event.Dates = appendUniqueString(event.Dates, newDate.Time.Format(dateLayout))
+	}
+	return nil
+}
+
+// addLessonToEvent - 授業のクラス・教室・担当教員をイベントに加える
+func addLessonToEvent(event *models.TimetableEvent, classID int, room string, teacherIDs ...int) {
+	event.ClassIDs = appendUniqueInt(event.ClassIDs, classID)
+	for _, id := range teacherIDs {
+		event.TeacherIDs = appendUniqueInt(event.TeacherIDs, id)
+	}
+	if room != "" {
+		event.Rooms = appendUniqueString(event.Rooms, room)
+	}
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUniqueInt(list []int, v int) []int {
+	if containsInt(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+func appendUniqueString(list []string, v string) []string {
+	if containsString(list, v) {
+		return list
+	}
+	return append(list, v)
+}
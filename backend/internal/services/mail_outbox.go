@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/mailer"
+	"kosen-schedule-system/internal/models"
+)
+
+// メールの状態（mail_outbox.status）
+const (
+	mailPending = "pending"
+	mailSent    = "sent"
+	mailFailed  = "failed"
+)
+
+const (
+	maxMailAttempts  = 8               // これだけ失敗したら再送をやめる
+	mailRetryBase    = time.Minute     // 1回目の失敗後の待ち時間（失敗するごとに倍にする）
+	mailRetryMax     = 6 * time.Hour   // 再送までの待ち時間の上限
+	mailClaimTimeout = 5 * time.Minute // 送信中のメールを他の処理が拾わないようにする時間
+	mailBatchSize    = 100             // 1回の確認で送信する件数
+)
+
+// MailSender - メールの送信（mailer.Mailer、確認用の差し替え）
+type MailSender interface {
+	Send(msg *mailer.Message) error
+}
+
+// enqueueMail - 通知をメールの送信待ちに登録する（呼び出し側のトランザクション内で実行）
+// 受信しない設定（通知の種類ごと、または "all"）のユーザーには登録しない
+func enqueueMail(tx *sql.Tx, notificationID, userID int, event string) error {
+	var email string
+	var enabled bool
+	err := tx.QueryRow(`
+		SELECT u.email, COALESCE(a.email_enabled, TRUE) AND COALESCE(p.email_enabled, TRUE)
+		FROM users u
+		LEFT JOIN notification_preferences a ON a.user_id = u.id AND a.event_type = ?
+		LEFT JOIN notification_preferences p ON p.user_id = u.id AND p.event_type = ?
+		WHERE u.id = ?
+	`, models.NotificationPreferenceAll, event, userID).Scan(&email, &enabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if !enabled || email == "" {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO mail_outbox (notification_id, to_address, status, next_attempt_at)
+		VALUES (?, ?, ?, ?)
+	`, notificationID, email, mailPending, time.Now())
+	if err != nil {
+		return fmt.Errorf("メールの送信待ちへの登録に失敗しました: %v", err)
+	}
+	return nil
+}
+
+// MailOutboxWorker - 送信待ちのメールを定期的に送信する
+// 送信に失敗したメールは間隔を倍にしながら再送し、maxMailAttempts 回失敗したら failed にする
+type MailOutboxWorker struct {
+	db       *sql.DB
+	sender   MailSender
+	baseURL  string
+	interval time.Duration
+}
+
+func NewMailOutboxWorker(db *sql.DB, sender MailSender, baseURL string, interval time.Duration) *MailOutboxWorker {
+	return &MailOutboxWorker{
+		db:       db,
+		sender:   sender,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		interval: interval,
+	}
+}
+
+// Start - ctx が終了するまでバックグラウンドで定期的に送信する
+func (w *MailOutboxWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(time.Now()); err != nil {
+				log.Printf("mail outbox: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce - now の時点で送信予定のメールを送信する（送信の失敗は記録して再送を予約し、エラーにはしない）
+func (w *MailOutboxWorker) RunOnce(now time.Time) error {
+	rows, err := w.db.Query(`
+		SELECT id FROM mail_outbox
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`, mailPending, now, mailBatchSize)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := w.deliver(id, now); err != nil {
+			return fmt.Errorf("メール %d の処理に失敗しました: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// deliver - 1通を送信し、結果を記録する
+func (w *MailOutboxWorker) deliver(id int, now time.Time) error {
+	// 他の処理が同じメールを送らないよう、送信中は次の送信予定を先へずらしておく
+	result, err := w.db.Exec(`
+		UPDATE mail_outbox SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at <= ?
+	`, now.Add(mailClaimTimeout), id, mailPending, now)
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+
+	var to string
+	var attempts int
+	var data mailer.NotificationData
+	var changeRequestID sql.NullInt64
+	err = w.db.QueryRow(`
+		SELECT o.to_address, o.attempts, u.name, n.title, n.message, n.change_request_id
+		FROM mail_outbox o
+		JOIN notifications n ON o.notification_id = n.id
+		JOIN users u ON n.user_id = u.id
+		WHERE o.id = ?
+	`, id).Scan(&to, &attempts, &data.Name, &data.Title, &data.Message, &changeRequestID)
+	if err != nil {
+		return err
+	}
+	if w.baseURL != "" {
+		data.URL = w.baseURL + "/notifications"
+		if changeRequestID.Valid {
+			data.URL = fmt.Sprintf("%s/requests/%d", w.baseURL, changeRequestID.Int64)
+		}
+	}
+
+	a := attemptMail(w.sender, to, &data, attempts, now)
+	if a.err == nil {
+		_, err := w.db.Exec(`
+			UPDATE mail_outbox SET status = ?, attempts = ?, sent_at = ?, last_error = NULL
+			WHERE id = ?
+		`, a.status, a.attempts, time.Now(), id)
+		return err
+	}
+
+	_, err = w.db.Exec(`
+		UPDATE mail_outbox SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?
+		WHERE id = ?
+	`, a.status, a.attempts, a.nextAttemptAt, a.err.Error(), id)
+	return err
+}
+
+// mailAttempt - 1回の送信の結果と、記録する状態
+type mailAttempt struct {
+	status        string
+	attempts      int
+	nextAttemptAt time.Time // 失敗した場合の次の送信予定
+	err           error
+}
+
+// attemptMail - 通知のメールを1回送信する（これまでに attempts 回失敗している）
+// 失敗した場合は間隔を倍にして再送を予約し、maxMailAttempts 回失敗したら failed にする
+func attemptMail(sender MailSender, to string, data *mailer.NotificationData, attempts int, now time.Time) mailAttempt {
+	msg, err := mailer.RenderNotification(to, data)
+	if err == nil {
+		err = sender.Send(msg)
+	}
+	attempts++
+
+	if err == nil {
+		return mailAttempt{status: mailSent, attempts: attempts}
+	}
+	status := mailPending
+	if attempts >= maxMailAttempts {
+		status = mailFailed
+	}
+	return mailAttempt{status: status, attempts: attempts, nextAttemptAt: now.Add(mailRetryDelay(attempts)), err: err}
+}
+
+// mailRetryDelay - attempts 回失敗した後、次に送信するまでの待ち時間
+func mailRetryDelay(attempts int) time.Duration {
+	delay := mailRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= mailRetryMax {
+			return mailRetryMax
+		}
+	}
+	return delay
+}
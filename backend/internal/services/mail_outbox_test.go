@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kosen-schedule-system/internal/mailer"
+)
+
+// fakeSender - 最初の failures 回は失敗し、送ったメールを記録する
+type fakeSender struct {
+	failures int
+	calls    int
+	sent     []*mailer.Message
+}
+
+func (f *fakeSender) Send(msg *mailer.Message) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("connection refused")
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestAttemptMailRetriesUntilSent(t *testing.T) {
+	sender := &fakeSender{failures: 3}
+	data := &mailer.NotificationData{Name: "山田", Title: "休講", Message: "2限は休講です"}
+	now := time.Date(2026, time.April, 13, 9, 0, 0, 0, time.UTC)
+
+	attempts := 0
+	wantDelays := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, want := range wantDelays {
+		a := attemptMail(sender, "teacher@example.ac.jp", data, attempts, now)
+		if a.err == nil {
+			t.Fatalf("attempt %d should fail", i+1)
+		}
+		if a.status != mailPending {
+			t.Fatalf("attempt %d status = %s, want %s", i+1, a.status, mailPending)
+		}
+		if a.attempts != i+1 {
+			t.Fatalf("attempt %d attempts = %d", i+1, a.attempts)
+		}
+		if got := a.nextAttemptAt.Sub(now); got != want {
+			t.Errorf("attempt %d retry after %v, want %v", i+1, got, want)
+		}
+		attempts = a.attempts
+		now = a.nextAttemptAt
+	}
+
+	a := attemptMail(sender, "teacher@example.ac.jp", data, attempts, now)
+	if a.err != nil {
+		t.Fatalf("attempt 4 error = %v", a.err)
+	}
+	if a.status != mailSent || a.attempts != 4 {
+		t.Fatalf("attempt 4 = %s (%d attempts), want %s (4 attempts)", a.status, a.attempts, mailSent)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "teacher@example.ac.jp" {
+		t.Fatalf("sent = %+v", sender.sent)
+	}
+}
+
+func TestAttemptMailGivesUp(t *testing.T) {
+	sender := &fakeSender{failures: maxMailAttempts + 1}
+	data := &mailer.NotificationData{Name: "山田", Title: "休講", Message: "2限は休講です"}
+	now := time.Date(2026, time.April, 13, 9, 0, 0, 0, time.UTC)
+
+	var a mailAttempt
+	for attempts := 0; attempts < maxMailAttempts; attempts++ {
+		a = attemptMail(sender, "teacher@example.ac.jp", data, attempts, now)
+		if attempts < maxMailAttempts-1 && a.status != mailPending {
+			t.Fatalf("attempt %d status = %s, want %s", attempts+1, a.status, mailPending)
+		}
+	}
+	if a.status != mailFailed {
+		t.Fatalf("status after %d failures = %s, want %s", maxMailAttempts, a.status, mailFailed)
+	}
+	if sender.calls != maxMailAttempts {
+		t.Fatalf("calls = %d, want %d", sender.calls, maxMailAttempts)
+	}
+}
+
+func TestMailRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, 6 * time.Hour},
+		{30, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := mailRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("mailRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type NotificationService struct {
+	db *sql.DB
+}
+
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// GetNotifications - ユーザーの通知一覧（新しい順）と件数（unreadOnly の場合は未読のみ）
+func (s *NotificationService) GetNotifications(userID int, unreadOnly bool, limit, offset int) ([]models.Notification, int, error) {
+	where := squirrel.Eq{"user_id": userID}
+	if unreadOnly {
+		where["is_read"] = false
+	}
+
+	countQuery, countArgs, err := squirrel.Select("COUNT(*)").
+		From("notifications").
+		Where(where).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	var total int
+	if err := s.db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sqlQuery, args, err := squirrel.Select(
+		"id", "user_id", "title", "message", "type", "COALESCE(event_type, '')", "change_request_id",
+		"is_read", "read_at", "created_at",
+	).
+		From("notifications").
+		Where(where).
+		OrderBy("created_at DESC", "id DESC").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var changeRequestID sql.NullInt64
+		var readAt sql.NullTime
+		err := rows.Scan(&n.ID, &n.UserID, &n.Title, &n.Message, &n.Type, &n.Event, &changeRequestID,
+			&n.IsRead, &readAt, &n.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		n.ChangeRequestID = nullIntPtr(changeRequestID)
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, total, rows.Err()
+}
+
+// GetUnreadCount - ユーザーの未読の通知の件数
+func (s *NotificationService) GetUnreadCount(userID int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = FALSE", userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead - 通知を既読にする（本人の通知でなければ sql.ErrNoRows）
+func (s *NotificationService) MarkRead(userID, id int) error {
+	var isRead bool
+	err := s.db.QueryRow("SELECT is_read FROM notifications WHERE id = ? AND user_id = ?", id, userID).Scan(&isRead)
+	if err != nil {
+		return err
+	}
+	if isRead {
+		return nil
+	}
+
+	_, err = s.db.Exec("UPDATE notifications SET is_read = TRUE, read_at = ? WHERE id = ? AND is_read = FALSE", time.Now(), id)
+	return err
+}
+
+// MarkAllRead - ユーザーの未読の通知をすべて既読にし、既読にした件数を返す
+func (s *NotificationService) MarkAllRead(userID int) (int, error) {
+	result, err := s.db.Exec("UPDATE notifications SET is_read = TRUE, read_at = ? WHERE user_id = ? AND is_read = FALSE", time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// 通知の種類の表示名（メール受信設定の画面に並べる順）
+var notificationEvents = []struct {
+	event string
+	label string
+}{
+	{models.NotificationPreferenceAll, "すべての通知メール"},
+	{models.NotificationConsentRequested, "同意の依頼"},
+	{models.NotificationRequestSubmitted, "承認待ちの申請"},
+	{models.NotificationRequestApproved, "申請の承認"},
+	{models.NotificationRequestRejected, "申請の却下"},
+	{models.NotificationTimetableChanged, "時間割の変更"},
+	{models.NotificationDailyDigest, "翌授業日の変更のまとめ"},
+	{models.NotificationRequestReverted, "申請の取り消し"},
+	{models.NotificationRequestExpired, "申請の期限切れ"},
+	{models.NotificationDeadlineReminder, "判断期限のリマインド"},
+	{models.NotificationRequestOverdue, "判断期限の超過"},
+}
+
+// GetPreferences - 通知の種類ごとのメール受信設定（設定していない種類は受信する）
+func (s *NotificationService) GetPreferences(userID int) ([]models.NotificationPreference, error) {
+	rows, err := s.db.Query("SELECT event_type, email_enabled FROM notification_preferences WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]bool)
+	for rows.Next() {
+		var event string
+		var enabled bool
+		if err := rows.Scan(&event, &enabled); err != nil {
+			return nil, err
+		}
+		settings[event] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(notificationEvents))
+	for _, e := range notificationEvents {
+		enabled, ok := settings[e.event]
+		preferences = append(preferences, models.NotificationPreference{
+			Event:        e.event,
+			Label:        e.label,
+			EmailEnabled: enabled || !ok,
+		})
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences - メール受信設定を変更する（指定した種類のみ）
+func (s *NotificationService) UpdatePreferences(userID int, req *models.UpdateNotificationPreferencesRequest) ([]models.NotificationPreference, error) {
+	if len(req.Email) == 0 {
+		return nil, fmt.Errorf("変更する設定を指定してください")
+	}
+	for event := range req.Email {
+		known := false
+		for _, e := range notificationEvents {
+			if e.event == event {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("通知の種類が不正です: %s", event)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for event, enabled := range req.Email {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, event_type, email_enabled)
+			VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE email_enabled = VALUES(email_enabled)
+		`, userID, event, enabled)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetPreferences(userID)
+}
+
+// notifyUsers - 複数のユーザーに同じ内容の通知を登録する（呼び出し側のトランザクション内で実行）
+// 重複したユーザーと 0 は除く。メールを受信する設定のユーザーには送信待ちのメールも登録する
+func notifyUsers(tx *sql.Tx, userIDs []int, n *models.Notification) error {
+	seen := make(map[int]bool)
+	for _, userID := range userIDs {
+		if userID == 0 || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		result, err := tx.Exec(`
+			INSERT INTO notifications (user_id, title, message, type, event_type, change_request_id)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, userID, n.Title, n.Message, n.Type, n.Event, n.ChangeRequestID)
+		if err != nil {
+			return fmt.Errorf("通知の登録に失敗しました: %v", err)
+		}
+
+		notificationID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := enqueueMail(tx, int(notificationID), userID, n.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// excludeUsers - userIDs から exclude のユーザーを除く
+func excludeUsers(userIDs []int, exclude ...int) []int {
+	filtered := make([]int, 0, len(userIDs))
+	for _, userID := range userIDs {
+		excluded := false
+		for _, id := range exclude {
+			if userID == id {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			filtered = append(filtered, userID)
+		}
+	}
+	return filtered
+}
+
+// lessonUserIDs - 授業の担当教員・共同担当教員と、受講する学生（今年度の在籍クラス、グループの授業はグループの学生）
+func lessonUserIDs(q queryer, timetableIDs []int) ([]int, error) {
+	if len(timetableIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(timetableIDs)), ",")
+	ids := make([]interface{}, len(timetableIDs))
+	for i, id := range timetableIDs {
+		ids[i] = id
+	}
+
+	var args []interface{}
+	args = append(args, ids...)
+	args = append(args, ids...)
+	args = append(args, models.AcademicYearOf(time.Now()))
+	args = append(args, ids...)
+	args = append(args, ids...)
+
+	rows, err := q.Query(`
+		SELECT teacher_id FROM timetables WHERE id IN (`+placeholders+`)
+		UNION
+		SELECT teacher_id FROM timetable_teachers WHERE timetable_id IN (`+placeholders+`)
+		UNION
+		SELECT e.student_id FROM timetables t
+		JOIN enrollments e ON e.class_id = t.class_id AND e.academic_year = ?
+		WHERE t.id IN (`+placeholders+`) AND t.group_id IS NULL
+		UNION
+		SELECT m.student_id FROM timetables t
+		JOIN class_group_members m ON m.group_id = t.group_id
+		WHERE t.id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// 日付指定の変更の種類の表示名
+var overrideTypeLabels = map[string]string{
+	models.OverrideCancel:     "休講",
+	models.OverrideMove:       "授業の移動",
+	models.OverrideSubstitute: "代講",
+	models.OverrideRoomChange: "教室変更",
+}
+
+// notifyOverrides - 日付指定の変更を、対象の授業の教員・学生と代講の教員に通知する（登録した本人は除く）
+func notifyOverrides(tx *sql.Tx, overrides []*models.TimetableOverride, actorID int) error {
+	for _, o := range overrides {
+		recipients, err := lessonUserIDs(tx, []int{o.TimetableID})
+		if err != nil {
+			return err
+		}
+		if o.NewTeacherID != nil {
+			recipients = append(recipients, *o.NewTeacherID)
+		}
+
+		var subjectName string
+		var period int
+		err = tx.QueryRow(`
+			SELECT s.name, t.period
+			FROM timetables t
+			JOIN subjects s ON t.subject_id = s.id
+			WHERE t.id = ?
+		`, o.TimetableID).Scan(&subjectName, &period)
+		if err != nil {
+			return err
+		}
+
+		label := overrideTypeLabels[o.Type]
+		if label == "" {
+			label = "変更"
+		}
+		message := fmt.Sprintf("%s %d限の「%s」が%sになりました。", o.Date, period, subjectName, label)
+		if o.Type == models.OverrideMove && o.NewDate != "" {
+			message = fmt.Sprintf("%s %d限の「%s」が%sに移動しました。", o.Date, period, subjectName, o.NewDate)
+		}
+		if o.Note != "" {
+			message += "\n備考：" + o.Note
+		}
+
+		err = notifyUsers(tx, excludeUsers(recipients, actorID), &models.Notification{
+			Title:           "時間割が変更されました",
+			Message:         message,
+			Type:            models.NotificationInfo,
+			Event:           models.NotificationTimetableChanged,
+			ChangeRequestID: o.ChangeRequestID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
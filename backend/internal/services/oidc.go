@@ -0,0 +1,483 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCConfig - OpenID Connect設定
+type OIDCConfig struct {
+	Issuer         string
+	ClientID       string
+	ClientSecret   string
+	RedirectURL    string
+	AllowedDomains []string
+	DomainRoles    map[string]string // メールドメイン -> ロール
+	GroupRoles     map[string]string // groupsクレーム -> ロール
+	DefaultRole    string
+}
+
+// ParseOIDCMapping - "key:value,key:value" 形式の設定を解析
+func ParseOIDCMapping(s string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		mapping[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return mapping
+}
+
+// ParseOIDCList - カンマ区切りの設定を解析
+func ParseOIDCList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcPendingLogin struct {
+	verifier    string
+	nonce       string
+	browserHash [32]byte
+	expiresAt   time.Time
+}
+
+type oidcIDTokenClaims struct {
+	Email         string   `json:"email"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	Name          string   `json:"name"`
+	Nonce         string   `json:"nonce"`
+	Groups        []string `json:"groups"`
+	jwt.RegisteredClaims
+}
+
+// OIDCLoginTTL - ログイン開始からコールバックまでの有効期限
+const OIDCLoginTTL = 10 * time.Minute
+
+// OIDCService - 認可コード + PKCE によるシングルサインオン
+type OIDCService struct {
+	authService *AuthService
+	config      OIDCConfig
+	httpClient  *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	// 開始したログインはこのプロセスのメモリにだけ保持するため、複数台で動かす場合はスティッキーセッションが必要
+	pending map[string]oidcPendingLogin
+}
+
+func NewOIDCService(authService *AuthService, config OIDCConfig) *OIDCService {
+	if config.DefaultRole == "" {
+		config.DefaultRole = models.RoleStudent
+	}
+	return &OIDCService{
+		authService: authService,
+		config:      config,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		keys:        make(map[string]*rsa.PublicKey),
+		pending:     make(map[string]oidcPendingLogin),
+	}
+}
+
+// Enabled - OIDCが設定されているか
+func (s *OIDCService) Enabled() bool {
+	return s.config.Issuer != "" && s.config.ClientID != ""
+}
+
+// AuthorizationURL - 認可エンドポイントのURLとstate、ログインを開始したブラウザの鍵を生成
+// ブラウザの鍵はHttpOnlyのCookieに入れ、コールバックで同じブラウザから戻ってきたことを確認する
+func (s *OIDCService) AuthorizationURL() (string, string, string, error) {
+	if !s.Enabled() {
+		return "", "", "", errors.New("OIDCログインは設定されていません")
+	}
+
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	state, err := randomURLString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	nonce, err := randomURLString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	verifier, err := randomURLString(48)
+	if err != nil {
+		return "", "", "", err
+	}
+	browserKey, err := randomURLString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	s.mu.Lock()
+	s.cleanupPendingLocked()
+	s.pending[state] = oidcPendingLogin{
+		verifier:    verifier,
+		nonce:       nonce,
+		browserHash: sha256.Sum256([]byte(browserKey)),
+		expiresAt:   time.Now().Add(OIDCLoginTTL),
+	}
+	s.mu.Unlock()
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", s.config.ClientID)
+	params.Set("redirect_uri", s.config.RedirectURL)
+	params.Set("scope", "openid email profile")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	authURL := discovery.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + params.Encode()
+	} else {
+		authURL += "?" + params.Encode()
+	}
+
+	return authURL, state, browserKey, nil
+}
+
+// Exchange - 認可コードをIDトークンに交換し、対応するユーザーを返す
+// browserKey はログイン開始時にCookieへ入れた鍵（別のブラウザで始めたログインは受け付けない）
+func (s *OIDCService) Exchange(code, state, browserKey string) (*models.User, error) {
+	claims, err := s.exchangeCode(code, state, browserKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveUser(claims)
+}
+
+// exchangeCode - 認可コードをIDトークンに交換し、検証したクレームを返す
+func (s *OIDCService) exchangeCode(code, state, browserKey string) (*oidcIDTokenClaims, error) {
+	if !s.Enabled() {
+		return nil, errors.New("OIDCログインは設定されていません")
+	}
+
+	s.mu.Lock()
+	pending, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(pending.expiresAt) {
+		return nil, errors.New("ログインセッションが無効または期限切れです")
+	}
+	browserHash := sha256.Sum256([]byte(browserKey))
+	if subtle.ConstantTimeCompare(browserHash[:], pending.browserHash[:]) != 1 {
+		return nil, errors.New("ログインを開始したブラウザと一致しません")
+	}
+
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.config.RedirectURL)
+	form.Set("client_id", s.config.ClientID)
+	form.Set("code_verifier", pending.verifier)
+	if s.config.ClientSecret != "" {
+		form.Set("client_secret", s.config.ClientSecret)
+	}
+
+	resp, err := s.httpClient.PostForm(discovery.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("トークンエンドポイントへの接続に失敗しました: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return nil, fmt.Errorf("トークンレスポンスの解析に失敗しました: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || tokenResponse.IDToken == "" {
+		return nil, fmt.Errorf("トークン取得に失敗しました: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+
+	claims, err := s.verifyIDToken(tokenResponse.IDToken, discovery.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != pending.nonce {
+		return nil, errors.New("IDトークンのnonceが一致しません")
+	}
+
+	return claims, nil
+}
+
+// verifyIDToken - IDトークンの署名とクレームを検証
+func (s *OIDCService) verifyIDToken(idToken, issuer string) (*oidcIDTokenClaims, error) {
+	claims := &oidcIDTokenClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.getKey(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(s.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("IDトークンの検証に失敗しました: %v", err)
+	}
+	if !token.Valid {
+		return nil, errors.New("無効なIDトークンです")
+	}
+	return claims, nil
+}
+
+// resolveUser - IDトークンのクレームからユーザーを取得（未登録なら作成）
+func (s *OIDCService) resolveUser(claims *oidcIDTokenClaims) (*models.User, error) {
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		return nil, errors.New("IDトークンにメールアドレスが含まれていません")
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, errors.New("メールアドレスが確認されていません")
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if len(s.config.AllowedDomains) > 0 && !containsString(s.config.AllowedDomains, domain) {
+		return nil, fmt.Errorf("このドメインではログインできません: %s", domain)
+	}
+	// メールドメインでログインの可否やロールを決める場合は、確認済みのメールアドレスであることを必須にする
+	if claims.EmailVerified == nil && s.trustsDomain(domain) {
+		return nil, errors.New("IDトークンにメールアドレスの確認状態（email_verified）が含まれていません")
+	}
+
+	user, err := s.authService.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		// 既存ユーザーはシステム側のロールを優先する
+		return user, nil
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = email[:strings.LastIndex(email, "@")]
+	}
+
+	// パスワードログインは使わないため推測不能な値を設定
+	password, err := randomURLString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.CreateUser(email, password, name, s.mapRole(domain, claims.Groups))
+}
+
+// trustsDomain - ログインの可否やロールをメールドメインで決めているか
+func (s *OIDCService) trustsDomain(domain string) bool {
+	if len(s.config.AllowedDomains) > 0 {
+		return true
+	}
+	_, ok := s.config.DomainRoles[domain]
+	return ok
+}
+
+// mapRole - メールドメインとグループからロールを決定（権限の強いものを優先）
+func (s *OIDCService) mapRole(domain string, groups []string) string {
+	candidates := []string{}
+	if role, ok := s.config.DomainRoles[domain]; ok {
+		candidates = append(candidates, role)
+	}
+	for _, group := range groups {
+		if role, ok := s.config.GroupRoles[strings.ToLower(group)]; ok {
+			candidates = append(candidates, role)
+		}
+	}
+
+	for _, role := range []string{models.RoleAdmin, models.RoleTeacher, models.RoleStudent} {
+		if containsString(candidates, role) {
+			return role
+		}
+	}
+	return s.config.DefaultRole
+}
+
+// getDiscovery - プロバイダーのメタデータを取得（キャッシュ）
+func (s *OIDCService) getDiscovery() (*oidcDiscovery, error) {
+	s.mu.Lock()
+	cached := s.discovery
+	s.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(s.config.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := s.httpClient.Get(discoveryURL)
+	if err != nil {
+		return nil, fmt.Errorf("OIDCプロバイダーへの接続に失敗しました: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDCメタデータの取得に失敗しました: status %d", resp.StatusCode)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("OIDCメタデータの解析に失敗しました: %v", err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDCメタデータに必要なエンドポイントがありません")
+	}
+	// OpenID Connect Discovery 1.0 の 4.3 節のとおり、設定した発行者と一致しなければ使わない
+	if discovery.Issuer != s.config.Issuer {
+		return nil, fmt.Errorf("OIDCメタデータの issuer が設定と一致しません: %q", discovery.Issuer)
+	}
+
+	s.mu.Lock()
+	s.discovery = &discovery
+	s.mu.Unlock()
+
+	return &discovery, nil
+}
+
+// getKey - kidに対応する公開鍵を取得（未知のkidならJWKSを再取得）
+func (s *OIDCService) getKey(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := s.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	// kidを指定しないプロバイダー向け（鍵が1つだけの場合）
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("署名鍵が見つかりません: %s", kid)
+}
+
+// refreshKeys - JWKSを取得して鍵キャッシュを更新
+func (s *OIDCService) refreshKeys() error {
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Get(discovery.JWKSURI)
+	if err != nil {
+		return fmt.Errorf("JWKSの取得に失敗しました: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("JWKSの解析に失敗しました: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *OIDCService) cleanupPendingLocked() {
+	now := time.Now()
+	for state, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, state)
+		}
+	}
+}
+
+func randomURLString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCProvider - 認可コードを受け取ると IDトークンを返すローカルの OIDC プロバイダー
+type mockOIDCProvider struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	issuer     string // メタデータの issuer（空なら server.URL）
+	omitIssuer bool   // メタデータに issuer を含めない
+
+	// 次のトークン要求で IDトークンに入れるクレーム（nonce は認可 URL の値に置き換える）
+	claims jwt.MapClaims
+
+	nonce     string
+	challenge string
+	verifier  string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := p.issuer
+		if issuer == "" {
+			issuer = p.server.URL
+		}
+		metadata := map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		}
+		if p.omitIssuer {
+			delete(metadata, "issuer")
+		}
+		json.NewEncoder(w).Encode(metadata)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.verifier = r.PostForm.Get("code_verifier")
+		if r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		claims["nonce"] = p.nonce
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize - 認可 URL の nonce・code_challenge を記録する（ブラウザでのログインの代わり）
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, p.server.URL+"/authorize?") {
+		t.Fatalf("authorization URL = %s", authURL)
+	}
+	if u.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q", u.Query().Get("code_challenge_method"))
+	}
+	p.nonce = u.Query().Get("nonce")
+	p.challenge = u.Query().Get("code_challenge")
+}
+
+func (p *mockOIDCProvider) standardClaims(clientID string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            "user-1",
+		"aud":            clientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"email":          "Teacher@Example.ac.jp",
+		"email_verified": true,
+		"name":           "山田 太郎",
+	}
+}
+
+func newTestOIDCService(p *mockOIDCProvider) *OIDCService {
+	return NewOIDCService(nil, OIDCConfig{
+		Issuer:      p.server.URL,
+		ClientID:    "kosen-schedule",
+		RedirectURL: "http://localhost:3000/auth/callback",
+		DomainRoles: map[string]string{"example.ac.jp": "teacher"},
+	})
+}
+
+func TestOIDCExchange(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	s := newTestOIDCService(p)
+	p.claims = p.standardClaims("kosen-schedule")
+
+	authURL, state, browserKey, err := s.AuthorizationURL()
+	if err != nil {
+		t.Fatalf("AuthorizationURL() error = %v", err)
+	}
+	p.authorize(t, authURL)
+
+	claims, err := s.exchangeCode("valid-code", state, browserKey)
+	if err != nil {
+		t.Fatalf("exchangeCode() error = %v", err)
+	}
+	if claims.Email != "Teacher@Example.ac.jp" || claims.Name != "山田 太郎" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// PKCE の code_verifier が認可 URL の code_challenge と対応している
+	sum := sha256.Sum256([]byte(p.verifier))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+		t.Errorf("code_verifier does not match code_challenge")
+	}
+
+	// state は一度しか使えない
+	if _, err := s.exchangeCode("valid-code", state, browserKey); err == nil {
+		t.Error("exchangeCode() should reject a reused state")
+	}
+}
+
+func TestOIDCExchangeRejectsOtherBrowser(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	s := newTestOIDCService(p)
+	p.claims = p.standardClaims("kosen-schedule")
+
+	authURL, state, _, err := s.AuthorizationURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.authorize(t, authURL)
+
+	// 攻撃者が始めたログインの state を、Cookie を持たない別のブラウザで使う
+	for _, key := range []string{"", "attacker-key"} {
+		if _, err := s.exchangeCode("valid-code", state, key); err == nil {
+			t.Errorf("exchangeCode() should reject browser key %q", key)
+		}
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+	}{
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"missing exp", func(c jwt.MapClaims) { delete(c, "exp") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newMockOIDCProvider(t)
+			s := newTestOIDCService(p)
+			p.claims = p.standardClaims("kosen-schedule")
+			tt.modify(p.claims)
+
+			authURL, state, browserKey, err := s.AuthorizationURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.authorize(t, authURL)
+
+			if _, err := s.exchangeCode("valid-code", state, browserKey); err == nil {
+				t.Error("exchangeCode() should fail")
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRejectsWrongNonce(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	s := newTestOIDCService(p)
+	p.claims = p.standardClaims("kosen-schedule")
+
+	authURL, state, browserKey, err := s.AuthorizationURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.authorize(t, authURL)
+	p.nonce = "replayed-nonce"
+
+	if _, err := s.exchangeCode("valid-code", state, browserKey); err == nil {
+		t.Error("exchangeCode() should reject a mismatched nonce")
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	p.issuer = "https://other.example.com"
+	if _, _, _, err := newTestOIDCService(p).AuthorizationURL(); err == nil {
+		t.Error("AuthorizationURL() should fail when the discovery issuer differs")
+	}
+
+	p = newMockOIDCProvider(t)
+	p.omitIssuer = true
+	if _, _, _, err := newTestOIDCService(p).AuthorizationURL(); err == nil {
+		t.Error("AuthorizationURL() should fail when the discovery issuer is missing")
+	}
+}
+
+func TestOIDCResolveUserRejections(t *testing.T) {
+	verified, unverified := true, false
+	tests := []struct {
+		name   string
+		config OIDCConfig
+		claims oidcIDTokenClaims
+	}{
+		{
+			name:   "missing email",
+			claims: oidcIDTokenClaims{EmailVerified: &verified},
+		},
+		{
+			name:   "unverified email",
+			claims: oidcIDTokenClaims{Email: "a@example.ac.jp", EmailVerified: &unverified},
+		},
+		{
+			name:   "domain not allowed",
+			config: OIDCConfig{AllowedDomains: []string{"example.ac.jp"}},
+			claims: oidcIDTokenClaims{Email: "a@gmail.com", EmailVerified: &verified},
+		},
+		{
+			name:   "email_verified omitted with allowed domains",
+			config: OIDCConfig{AllowedDomains: []string{"example.ac.jp"}},
+			claims: oidcIDTokenClaims{Email: "a@example.ac.jp"},
+		},
+		{
+			name:   "email_verified omitted with domain role",
+			config: OIDCConfig{DomainRoles: map[string]string{"example.ac.jp": "teacher"}},
+			claims: oidcIDTokenClaims{Email: "a@example.ac.jp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 認証サービスを使う前に拒否されること（nil のまま使うと panic する）
+			s := NewOIDCService(nil, tt.config)
+			if _, err := s.resolveUser(&tt.claims); err == nil {
+				t.Error("resolveUser() should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// ErrPermissionDenied - 権限不足
+var ErrPermissionDenied = errors.New("この操作を行う権限がありません")
+
+// ErrScopedGlobalPermission - 全体に対する権限を学年・学科に限定して与えようとした
+var ErrScopedGlobalPermission = errors.New("時間割編集・マスタ編集・CSV・ユーザー管理の権限は学年・学科を限定して割り当てられません")
+
+type PermissionService struct {
+	db *sql.DB
+}
+
+func NewPermissionService(db *sql.DB) *PermissionService {
+	return &PermissionService{db: db}
+}
+
+// GetUserGrants - ユーザーの有効な権限一覧を取得
+// 基本ロール（users.role）の権限は全体に、追加ロールの権限は割り当てた範囲に適用される
+func (s *PermissionService) GetUserGrants(userID int) ([]models.PermissionGrant, error) {
+	baseQuery := squirrel.Select("rp.permission", "rp.role").
+		From("users u").
+		Join("role_permissions rp ON rp.role = u.role").
+		Where(squirrel.Eq{"u.id": userID}).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := baseQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	grants := []models.PermissionGrant{}
+	for rows.Next() {
+		var g models.PermissionGrant
+		if err := rows.Scan(&g.Permission, &g.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		grants = append(grants, g)
+	}
+
+	assignedQuery := squirrel.Select("rp.permission", "rp.role", "ura.grade", "ura.department_id").
+		From("user_role_assignments ura").
+		Join("role_permissions rp ON rp.role = ura.role").
+		Where(squirrel.Eq{"ura.user_id": userID}).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err = assignedQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	assignedRows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer assignedRows.Close()
+
+	for assignedRows.Next() {
+		var g models.PermissionGrant
+		var grade, departmentID sql.NullInt64
+		if err := assignedRows.Scan(&g.Permission, &g.Role, &grade, &departmentID); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		g.Grade = nullIntPtr(grade)
+		g.DepartmentID = nullIntPtr(departmentID)
+		grants = append(grants, g)
+	}
+
+	return grants, nil
+}
+
+// HasPermission - ユーザーが指定範囲で権限を持つか（scope が nil ならいずれかの範囲で持てばよい）
+// 範囲を限定できない権限は、全体に対する割り当てで持つ場合のみ true
+func (s *PermissionService) HasPermission(userID int, permission string, scope *models.PermissionScope) (bool, error) {
+	var role string
+	err := s.db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// 管理者は常に全権限を持つ（権限表の設定ミスで締め出されないように）
+	if role == models.RoleAdmin {
+		return true, nil
+	}
+
+	grants, err := s.GetUserGrants(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, g := range grants {
+		if g.Permission != permission {
+			continue
+		}
+		if !models.IsScopedPermission(permission) && !g.PermissionScope.IsGlobal() {
+			continue
+		}
+		if g.PermissionScope.Covers(scope) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// PermissionScopes - ユーザーが権限を持つ範囲の一覧（権限がなければ空。管理者は全体）
+func (s *PermissionService) PermissionScopes(userID int, permission string) ([]models.PermissionScope, error) {
+	var role string
+	err := s.db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if role == models.RoleAdmin {
+		return []models.PermissionScope{{}}, nil
+	}
+
+	grants, err := s.GetUserGrants(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []models.PermissionScope
+	for _, g := range grants {
+		if g.Permission != permission {
+			continue
+		}
+		if !models.IsScopedPermission(permission) && !g.PermissionScope.IsGlobal() {
+			continue
+		}
+		scopes = append(scopes, g.PermissionScope)
+	}
+	return scopes, nil
+}
+
+// HasRole - ユーザーが指定範囲でロールを持つか（基本ロールは全体、追加ロールは割り当てた範囲。管理者は常に true）
+func (s *PermissionService) HasRole(userID int, role string, scope *models.PermissionScope) (bool, error) {
+	var baseRole string
+	err := s.db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&baseRole)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	if baseRole == models.RoleAdmin || baseRole == role {
+		return true, nil
+	}
+
+	assignments, err := s.GetRoleAssignments(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, a := range assignments {
+		assigned := models.PermissionScope{Grade: a.Grade, DepartmentID: a.DepartmentID}
+		if a.Role == role && assigned.Covers(scope) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RoleHolderIDs - 指定範囲でロールを持つユーザー（管理者を除く。基本ロールは全体、追加ロールは割り当てた範囲）
+func (s *PermissionService) RoleHolderIDs(role string, scope *models.PermissionScope) ([]int, error) {
+	var ids []int
+	rows, err := s.db.Query("SELECT id FROM users WHERE role = ? AND role <> ?", role, models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`
+		SELECT ura.user_id, ura.grade, ura.department_id
+		FROM user_role_assignments ura
+		JOIN users u ON ura.user_id = u.id
+		WHERE ura.role = ? AND u.role <> ?
+	`, role, models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var grade, departmentID sql.NullInt64
+		if err := rows.Scan(&id, &grade, &departmentID); err != nil {
+			return nil, err
+		}
+		assigned := models.PermissionScope{Grade: nullIntPtr(grade), DepartmentID: nullIntPtr(departmentID)}
+		if assigned.Covers(scope) && !containsInt(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, rows.Err()
+}
+
+// RequirePermission - 権限がなければ ErrPermissionDenied を返す
+func (s *PermissionService) RequirePermission(userID int, permission string, scope *models.PermissionScope) error {
+	ok, err := s.HasPermission(userID, permission, scope)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// GetRolePermissions - ロールの権限一覧取得
+func (s *PermissionService) GetRolePermissions(role string) ([]string, error) {
+	query := squirrel.Select("permission").
+		From("role_permissions").
+		Where(squirrel.Eq{"role": role}).
+		OrderBy("permission").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		permissions = append(permissions, p)
+	}
+
+	return permissions, nil
+}
+
+// SetRolePermissions - ロールの権限を置き換え
+func (s *PermissionService) SetRolePermissions(role string, permissions []string) error {
+	for _, p := range permissions {
+		if !containsString(models.AllPermissions, p) {
+			return fmt.Errorf("未定義の権限です: %s", p)
+		}
+	}
+
+	// 範囲を限定して割り当て済みのロールには、全体に対する権限を与えない
+	if !allScopedPermissions(permissions) {
+		var scoped int
+		err := s.db.QueryRow(
+			"SELECT COUNT(*) FROM user_role_assignments WHERE role = ? AND (grade IS NOT NULL OR department_id IS NOT NULL)",
+			role,
+		).Scan(&scoped)
+		if err != nil {
+			return err
+		}
+		if scoped > 0 {
+			return ErrScopedGlobalPermission
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = ?", role); err != nil {
+		return err
+	}
+
+	if len(permissions) > 0 {
+		insert := squirrel.Insert("role_permissions").
+			Columns("role", "permission").
+			PlaceholderFormat(squirrel.Question)
+		for _, p := range permissions {
+			insert = insert.Values(role, p)
+		}
+
+		sqlStr, args, err := insert.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqlStr, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRoleAssignments - ユーザーの追加ロール一覧取得
+func (s *PermissionService) GetRoleAssignments(userID int) ([]models.RoleAssignment, error) {
+	query := squirrel.Select("id", "user_id", "role", "grade", "department_id", "created_at").
+		From("user_role_assignments").
+		Where(squirrel.Eq{"user_id": userID}).
+		OrderBy("id").
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	assignments := []models.RoleAssignment{}
+	for rows.Next() {
+		var a models.RoleAssignment
+		var grade, departmentID sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Role, &grade, &departmentID, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		a.Grade = nullIntPtr(grade)
+		a.DepartmentID = nullIntPtr(departmentID)
+		assignments = append(assignments, a)
+	}
+
+	return assignments, nil
+}
+
+// AssignRole - ユーザーに追加ロールを割り当て
+func (s *PermissionService) AssignRole(userID int, req *models.CreateRoleAssignmentRequest) (*models.RoleAssignment, error) {
+	if _, ok := models.DefaultRolePermissions[req.Role]; !ok {
+		return nil, fmt.Errorf("未定義のロールです: %s", req.Role)
+	}
+
+	// 全体に対する権限を持つロールは、範囲を限定して割り当てられない
+	scope := models.PermissionScope{Grade: req.Grade, DepartmentID: req.DepartmentID}
+	if !scope.IsGlobal() {
+		permissions, err := s.GetRolePermissions(req.Role)
+		if err != nil {
+			return nil, err
+		}
+		if !allScopedPermissions(permissions) {
+			return nil, ErrScopedGlobalPermission
+		}
+	}
+
+	query := squirrel.Insert("user_role_assignments").
+		Columns("user_id", "role", "grade", "department_id").
+		Values(userID, req.Role, req.Grade, req.DepartmentID).
+		PlaceholderFormat(squirrel.Question)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &models.RoleAssignment{}
+	err = s.db.QueryRow("SELECT id, user_id, role, created_at FROM user_role_assignments WHERE id = ?", id).
+		Scan(&assignment.ID, &assignment.UserID, &assignment.Role, &assignment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	assignment.Grade = req.Grade
+	assignment.DepartmentID = req.DepartmentID
+
+	return assignment, nil
+}
+
+// RevokeRoleAssignment - 追加ロールの割り当て解除
+func (s *PermissionService) RevokeRoleAssignment(userID, assignmentID int) error {
+	result, err := s.db.Exec("DELETE FROM user_role_assignments WHERE id = ? AND user_id = ?", assignmentID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("role assignment not found")
+	}
+
+	return nil
+}
+
+// allScopedPermissions - すべて範囲を限定して与えられる権限か
+func allScopedPermissions(permissions []string) bool {
+	for _, p := range permissions {
+		if !models.IsScopedPermission(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
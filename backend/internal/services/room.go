@@ -0,0 +1,624 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// ErrAmbiguousRoom - 同じ表記の教室が複数登録されている（POST /api/rooms/:id/merge でまとめる）
+var ErrAmbiguousRoom = errors.New("同じ表記の教室が複数登録されています")
+
+var roomTypes = []string{
+	models.RoomTypeHR,
+	models.RoomTypeLecture,
+	models.RoomTypeLab,
+	models.RoomTypeGym,
+	models.RoomTypeSpecial,
+	models.RoomTypeOther,
+}
+
+type RoomService struct {
+	db               *sql.DB
+	timetableService *TimetableService
+}
+
+func NewRoomService(db *sql.DB, timetableService *TimetableService) *RoomService {
+	return &RoomService{db: db, timetableService: timetableService}
+}
+
+func roomSelect() squirrel.SelectBuilder {
+	return squirrel.Select("id", "name", "building", "capacity", "room_type", "created_at", "updated_at").
+		From("rooms").
+		PlaceholderFormat(squirrel.Question)
+}
+
+func scanRoom(scanner interface{ Scan(...interface{}) error }) (*models.Room, error) {
+	var r models.Room
+	var capacity sql.NullInt64
+	if err := scanner.Scan(&r.ID, &r.Name, &r.Building, &capacity, &r.RoomType, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	r.Capacity = nullIntPtr(capacity)
+	r.Aliases = []string{}
+	return &r, nil
+}
+
+// GetRooms - 教室一覧（別表記を含む）
+func (s *RoomService) GetRooms(filter models.RoomFilter) ([]models.Room, error) {
+	query := roomSelect().OrderBy("building", "name")
+	if filter.RoomType != nil {
+		query = query.Where(squirrel.Eq{"room_type": *filter.RoomType})
+	}
+	if filter.Building != nil {
+		query = query.Where(squirrel.Eq{"building": *filter.Building})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	rooms := []models.Room{}
+	index := make(map[int]int)
+	for rows.Next() {
+		r, err := scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		index[r.ID] = len(rooms)
+		rooms = append(rooms, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	aliases, err := s.db.Query("SELECT room_id, alias FROM room_aliases ORDER BY alias")
+	if err != nil {
+		return nil, err
+	}
+	defer aliases.Close()
+	for aliases.Next() {
+		var roomID int
+		var alias string
+		if err := aliases.Scan(&roomID, &alias); err != nil {
+			return nil, err
+		}
+		if i, ok := index[roomID]; ok {
+			rooms[i].Aliases = append(rooms[i].Aliases, alias)
+		}
+	}
+
+	return rooms, aliases.Err()
+}
+
+// GetRoomByID - 教室の取得（見つからなければ sql.ErrNoRows）
+func (s *RoomService) GetRoomByID(id int) (*models.Room, error) {
+	return getRoom(s.db, id)
+}
+
+func getRoom(q queryer, id int) (*models.Room, error) {
+	sqlStr, args, err := roomSelect().Where(squirrel.Eq{"id": id}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	r, err := scanRoom(q.QueryRow(sqlStr, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query("SELECT alias FROM room_aliases WHERE room_id = ? ORDER BY alias", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, err
+		}
+		r.Aliases = append(r.Aliases, alias)
+	}
+
+	return r, rows.Err()
+}
+
+// CreateRoom - 教室を登録する
+func (s *RoomService) CreateRoom(req *models.CreateRoomRequest) (*models.Room, error) {
+	name := strings.TrimSpace(req.Name)
+	roomType := req.RoomType
+	if roomType == "" {
+		roomType = models.RoomTypeOther
+	}
+	if err := validateRoom(name, roomType, req.Capacity); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkRoomNameAvailable(tx, 0, name); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO rooms (name, building, capacity, room_type)
+		VALUES (?, ?, ?, ?)
+	`, name, strings.TrimSpace(req.Building), req.Capacity, roomType)
+	if err != nil {
+		return nil, fmt.Errorf("教室の登録に失敗しました: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := replaceRoomAliases(tx, int(id), name, req.Aliases); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetRoomByID(int(id))
+}
+
+// UpdateRoom - 教室を更新する（名前を変えた場合は時間割などの授業場所も変え、元の名前を別表記に残す）
+func (s *RoomService) UpdateRoom(id int, req *models.UpdateRoomRequest) (*models.Room, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getRoom(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	name := current.Name
+	if strings.TrimSpace(req.Name) != "" {
+		name = strings.TrimSpace(req.Name)
+	}
+	roomType := current.RoomType
+	if req.RoomType != "" {
+		roomType = req.RoomType
+	}
+	if err := validateRoom(name, roomType, req.Capacity); err != nil {
+		return nil, err
+	}
+
+	query := squirrel.Update("rooms").
+		Set("name", name).
+		Set("room_type", roomType).
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Question)
+	if req.Building != nil {
+		query = query.Set("building", strings.TrimSpace(*req.Building))
+	}
+	if req.Capacity != nil {
+		query = query.Set("capacity", *req.Capacity)
+	}
+
+	aliases := current.Aliases
+	if req.Aliases != nil {
+		aliases = req.Aliases
+	}
+
+	if name != current.Name {
+		if err := checkRoomNameAvailable(tx, id, name); err != nil {
+			return nil, err
+		}
+		if err := renameRoomUsages(tx, current.Name, name); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, current.Name)
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	if _, err := tx.Exec(sqlStr, args...); err != nil {
+		return nil, fmt.Errorf("教室の更新に失敗しました: %v", err)
+	}
+
+	if err := replaceRoomAliases(tx, id, name, aliases); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetRoomByID(id)
+}
+
+// DeleteRoom - 教室を削除する（時間割・担当者の実施場所で使われている教室は削除できない）
+func (s *RoomService) DeleteRoom(id int) error {
+	room, err := getRoom(s.db, id)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = s.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM timetables WHERE room = ?) + (SELECT COUNT(*) FROM subject_assignments WHERE room = ?)
+	`, room.Name, room.Name).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("教室「%s」は時間割で使われているため削除できません（%d件）", room.Name, count)
+	}
+
+	_, err = s.db.Exec("DELETE FROM rooms WHERE id = ?", id)
+	return err
+}
+
+// MergeRoom - sourceID の教室を id の教室にまとめる（授業場所を置き換え、元の名前と別表記を id の教室の別表記にする）
+func (s *RoomService) MergeRoom(id int, req *models.MergeRoomRequest) (*models.Room, error) {
+	if req.SourceRoomID == id {
+		return nil, fmt.Errorf("同じ教室はまとめられません")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	target, err := getRoom(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	source, err := getRoom(tx, req.SourceRoomID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("まとめる教室が見つかりません")
+		}
+		return nil, err
+	}
+
+	if err := mergeRooms(tx, target, []*models.Room{source}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetRoomByID(id)
+}
+
+// MergeDuplicateRooms - 同じ表記（normalizeRoomName が同じ名前）で別々に登録された教室を、最初に登録された教室にまとめる
+// マイグレーションで登録した既存の授業場所の表記の揺れ（「1-1HR」と「1-1 HR」など）をまとめ、まとめた教室の数を返す
+func (s *RoomService) MergeDuplicateRooms() (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM rooms ORDER BY id FOR UPDATE")
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	groups := make(map[string][]*models.Room)
+	for _, id := range ids {
+		room, err := getRoom(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		key := normalizeRoomName(room.Name)
+		if groups[key] == nil {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], room)
+	}
+
+	merged := 0
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		if err := mergeRooms(tx, group[0], group[1:]); err != nil {
+			return 0, fmt.Errorf("教室「%s」のまとめに失敗しました: %v", group[0].Name, err)
+		}
+		merged += len(group) - 1
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return merged, nil
+}
+
+// mergeRooms - sources の教室を target の教室にまとめる（呼び出し側のトランザクション内で実行）
+// 授業場所を target の教室名に置き換え、sources の名前と別表記を target の別表記にする
+func mergeRooms(tx *sql.Tx, target *models.Room, sources []*models.Room) error {
+	aliases := target.Aliases
+	for _, source := range sources {
+		if err := renameRoomUsages(tx, source.Name, target.Name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM rooms WHERE id = ?", source.ID); err != nil {
+			return err
+		}
+		aliases = append(aliases, source.Name)
+		aliases = append(aliases, source.Aliases...)
+	}
+
+	// 別表記の確認はすべての sources を削除してから行う（残っていると同じ表記の教室が複数あることになる）
+	return replaceRoomAliases(tx, target.ID, target.Name, aliases)
+}
+
+// GetRoomOccupancy - 教室の date を含む週の使用状況（日付指定の変更を反映）
+// その週の授業には、この教室へ変更された授業と、休講・他の教室へ変更された授業も状態付きで含める
+func (s *RoomService) GetRoomOccupancy(id int, date time.Time) (*models.RoomOccupancy, error) {
+	room, err := getRoom(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.timetableService.GetTimetables(models.TimetableFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var timetables []models.Timetable
+	used := make(map[int]bool)
+	for _, t := range all {
+		if t.Room == room.Name {
+			timetables = append(timetables, t)
+			used[t.ID] = true
+		}
+	}
+
+	weekStart := weekStartOf(date)
+	weekEnd := weekStart.AddDate(0, 0, 4)
+	lessons, err := s.timetableService.GetEffectiveTimetable(models.EffectiveTimetableFilter{From: weekStart, To: weekEnd})
+	if err != nil {
+		return nil, err
+	}
+
+	occupied := make(map[string]bool)
+	days := weekLessonGrid(weekStart, weekEnd, lessons, func(lesson *models.EffectiveLesson) bool {
+		if lesson.Room == room.Name && isActiveLesson(lesson) {
+			occupied[fmt.Sprintf("%s-%d", lesson.Date, lesson.Period)] = true
+		}
+		return used[lesson.ID] || lesson.Room == room.Name
+	})
+
+	return &models.RoomOccupancy{
+		Room:            *room,
+		WeekStart:       weekStart.Format(dateLayout),
+		WeekEnd:         weekEnd.Format(dateLayout),
+		Timetable:       newWeeklyTimetable(timetables),
+		Days:            days,
+		OccupiedPeriods: len(occupied),
+		TotalPeriods:    len(timetableDays) * models.Period4,
+	}, nil
+}
+
+func validateRoom(name, roomType string, capacity *int) error {
+	if name == "" {
+		return fmt.Errorf("教室名を入力してください")
+	}
+	if name == models.RoomUndecided {
+		return fmt.Errorf("「%s」は教室名にできません", models.RoomUndecided)
+	}
+	if !containsString(roomTypes, roomType) {
+		return fmt.Errorf("教室の種類が不正です: %s", roomType)
+	}
+	if capacity != nil && *capacity <= 0 {
+		return fmt.Errorf("定員は1以上で指定してください")
+	}
+	return nil
+}
+
+// checkRoomNameAvailable - 名前がほかの教室の名前・別表記と同じ表記でないか確認する（exceptID の教室は除く）
+func checkRoomNameAvailable(q queryer, exceptID int, name string) error {
+	roomID, _, err := lookupRoom(q, name)
+	if err != nil {
+		return err
+	}
+	if roomID != 0 && roomID != exceptID {
+		return fmt.Errorf("教室「%s」は既に登録されています", name)
+	}
+	return nil
+}
+
+// replaceRoomAliases - 教室の別表記を置き換える（教室名と同じ表記・重複は除き、ほかの教室の表記とは重ねない）
+func replaceRoomAliases(tx *sql.Tx, roomID int, name string, aliases []string) error {
+	if _, err := tx.Exec("DELETE FROM room_aliases WHERE room_id = ?", roomID); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{normalizeRoomName(name): true}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		key := normalizeRoomName(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if err := checkRoomNameAvailable(tx, roomID, alias); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO room_aliases (alias, room_id) VALUES (?, ?)", alias, roomID); err != nil {
+			return fmt.Errorf("別表記の登録に失敗しました: %v", err)
+		}
+	}
+	return nil
+}
+
+// renameRoomUsages - 時間割・担当者の実施場所・日付指定の変更・カレンダー配信・申請の反映記録の授業場所を置き換える
+// 反映記録の反映前後の内容も置き換え、申請を取り消したときに登録されていない教室名に戻らないようにする
+func renameRoomUsages(tx *sql.Tx, from, to string) error {
+	if _, err := tx.Exec("UPDATE timetables SET room = ? WHERE room = ?", to, from); err != nil {
+		return fmt.Errorf("授業場所の変更に失敗しました: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE subject_assignments SET room = ? WHERE room = ?", to, from); err != nil {
+		return fmt.Errorf("授業場所の変更に失敗しました: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE timetable_overrides SET new_room = ? WHERE new_room = ?", to, from); err != nil {
+		return fmt.Errorf("授業場所の変更に失敗しました: %v", err)
+	}
+	if _, err := tx.Exec("UPDATE calendar_feeds SET room = ?, name = ? WHERE room = ?", to, to, from); err != nil {
+		return fmt.Errorf("授業場所の変更に失敗しました: %v", err)
+	}
+	for _, column := range []string{"before_data", "after_data"} {
+		_, err := tx.Exec(`
+			UPDATE change_request_applications SET `+column+` = JSON_REPLACE(`+column+`, '$.room', ?)
+			WHERE JSON_UNQUOTE(JSON_EXTRACT(`+column+`, '$.room')) = ?
+		`, to, from)
+		if err != nil {
+			return fmt.Errorf("授業場所の変更に失敗しました: %v", err)
+		}
+	}
+	return nil
+}
+
+// lookupRoom - 表記（教室名・別表記）に当たる教室のIDと教室名（見つからなければ 0）
+// 前後・途中の空白、全角・半角、英字の大文字・小文字の違いは同じ表記として扱う
+// 同じ表記に当たる教室が複数ある場合は、どちらか分からないため ErrAmbiguousRoom にする
+func lookupRoom(q queryer, name string) (int, string, error) {
+	key := normalizeRoomName(name)
+	if key == "" {
+		return 0, "", nil
+	}
+
+	rows, err := q.Query(`
+		SELECT id, name, name FROM rooms
+		UNION ALL
+		SELECT r.id, r.name, a.alias FROM room_aliases a JOIN rooms r ON a.room_id = r.id
+	`)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	foundID, foundName := 0, ""
+	for rows.Next() {
+		var id int
+		var roomName, spelling string
+		if err := rows.Scan(&id, &roomName, &spelling); err != nil {
+			return 0, "", err
+		}
+		if normalizeRoomName(spelling) != key {
+			continue
+		}
+		if foundID != 0 && foundID != id {
+			return 0, "", fmt.Errorf("%w: 「%s」は「%s」と「%s」のどちらにも当たります", ErrAmbiguousRoom, name, foundName, roomName)
+		}
+		foundID, foundName = id, roomName
+	}
+	return foundID, foundName, rows.Err()
+}
+
+// ResolveRoomName - 入力された授業場所を登録済みの教室名にする（登録のない教室はエラー）
+func (s *RoomService) ResolveRoomName(name string) (string, error) {
+	return resolveRoomName(s.db, name)
+}
+
+// resolveRoomName - 入力された授業場所を登録済みの教室名にする（「未定」はそのまま、登録のない教室はエラー）
+func resolveRoomName(q queryer, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == models.RoomUndecided {
+		return name, nil
+	}
+
+	id, roomName, err := lookupRoom(q, name)
+	if err != nil {
+		return "", err
+	}
+	if id == 0 {
+		return "", fmt.Errorf("教室「%s」が登録されていません", name)
+	}
+	return roomName, nil
+}
+
+// resolveOrCreateRoom - インポートした実施場所を教室名にする（登録のない教室は名前から種類を推定して登録する）
+func resolveOrCreateRoom(tx *sql.Tx, name string) (string, error) {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "　", " "))
+	if name == "" || name == models.RoomUndecided {
+		return name, nil
+	}
+
+	id, roomName, err := lookupRoom(tx, name)
+	if err != nil {
+		return "", err
+	}
+	if id != 0 {
+		return roomName, nil
+	}
+
+	if _, err := tx.Exec("INSERT INTO rooms (name, room_type) VALUES (?, ?)", name, guessRoomType(name)); err != nil {
+		return "", fmt.Errorf("教室の登録に失敗しました: %v", err)
+	}
+	return name, nil
+}
+
+// guessRoomType - 教室名から種類を推定する（マイグレーションでの推定と同じ規則）
+func guessRoomType(name string) string {
+	switch {
+	case strings.HasSuffix(strings.ToUpper(name), "HR"):
+		return models.RoomTypeHR
+	case strings.Contains(name, "実験"), strings.Contains(name, "実習"):
+		return models.RoomTypeLab
+	case strings.Contains(name, "体育"), strings.Contains(name, "グラウンド"):
+		return models.RoomTypeGym
+	}
+	return models.RoomTypeOther
+}
+
+// normalizeRoomName - 表記の比較用の値（全角英数記号を半角に、空白を除き、英字を大文字にする）
+func normalizeRoomName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
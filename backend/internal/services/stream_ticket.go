@@ -0,0 +1,63 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// 配信用チケットの有効期間（発行してすぐ接続する前提で短くする）
+const streamTicketTTL = 30 * time.Second
+
+// StreamTicket - 配信用チケットで認証したユーザー
+type StreamTicket struct {
+	UserID    int
+	Email     string
+	Role      string
+	ExpiresAt time.Time
+}
+
+// StreamTicketStore - Authorization ヘッダーを付けられない EventSource のための、一度だけ使える短期間のチケット
+// アクセストークンを URL に載せるとアクセスログやプロキシのログに残るため、代わりにこのチケットを渡す
+// チケットはプロセス内に保持するため、発行したプロセスへの接続でのみ使える（配信と同じ）
+type StreamTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]StreamTicket
+}
+
+func NewStreamTicketStore() *StreamTicketStore {
+	return &StreamTicketStore{tickets: make(map[string]StreamTicket)}
+}
+
+// Issue - ユーザーのチケットを発行する（期限切れのチケットはここで捨てる）
+func (s *StreamTicketStore) Issue(userID int, email, role string, now time.Time) (string, time.Time, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := now.Add(streamTicketTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, ticket := range s.tickets {
+		if !now.Before(ticket.ExpiresAt) {
+			delete(s.tickets, t)
+		}
+	}
+	s.tickets[token] = StreamTicket{UserID: userID, Email: email, Role: role, ExpiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// Redeem - チケットを使う（使ったチケットは無効になる。存在しないか期限切れなら false）
+func (s *StreamTicketStore) Redeem(token string, now time.Time) (*StreamTicket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[token]
+	if !ok {
+		return nil, false
+	}
+	delete(s.tickets, token)
+	if !now.Before(ticket.ExpiresAt) {
+		return nil, false
+	}
+	return &ticket, true
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type SubstituteService struct {
+	db *sql.DB
+}
+
+func NewSubstituteService(db *sql.DB) *SubstituteService {
+	return &SubstituteService{db: db}
+}
+
+// GetSubstituteSlots - 教員が不在の期間に代講が必要な授業と、各コマで代講できる教員の候補
+// 共同担当の授業も含める。候補は同じ科目の担当者、同じクラスの担当者、その日の授業が少ない順に並べる
+func (s *SubstituteService) GetSubstituteSlots(teacherID int, from, to time.Time) ([]models.SubstituteSlot, error) {
+	lessons, err := loadEffectiveLessons(s.db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// 各日・各コマで授業のある教員と、各日の担当授業数
+	busy := make(map[string]map[int]bool)
+	dailyCount := make(map[string]map[int]int)
+	var affected []models.EffectiveLesson
+	for i := range lessons {
+		l := &lessons[i]
+		if !isActiveLesson(l) {
+			continue
+		}
+		if teachesLesson(&l.Timetable, teacherID) {
+			affected = append(affected, *l)
+		}
+
+		slot := fmt.Sprintf("%s-%d", l.Date, l.Period)
+		if busy[slot] == nil {
+			busy[slot] = make(map[int]bool)
+		}
+		if dailyCount[l.Date] == nil {
+			dailyCount[l.Date] = make(map[int]int)
+		}
+		snapshot := models.TimetableSnapshot{TeacherID: l.TeacherID, CoTeacherIDs: l.CoTeacherIDs}
+		for _, id := range lessonTeachers(&snapshot) {
+			busy[slot][id] = true
+			dailyCount[l.Date][id]++
+		}
+	}
+
+	slots := []models.SubstituteSlot{}
+	if len(affected) == 0 {
+		return slots, nil
+	}
+
+	teachers, err := s.loadTeachers()
+	if err != nil {
+		return nil, err
+	}
+	unavailable, err := s.loadUnavailability()
+	if err != nil {
+		return nil, err
+	}
+	subjectTeachers, classTeachers, err := s.loadAssignmentTeachers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lesson := range affected {
+		slot := fmt.Sprintf("%s-%d", lesson.Date, lesson.Period)
+		candidates := []models.SubstituteCandidate{}
+		for _, t := range teachers {
+			if t.ID == teacherID || busy[slot][t.ID] || isUnavailable(unavailable[t.ID], lesson.DayOfWeek, lesson.Period) {
+				continue
+			}
+			candidates = append(candidates, models.SubstituteCandidate{
+				TeacherID:   t.ID,
+				TeacherName: t.Name,
+				SameSubject: subjectTeachers[lesson.SubjectID][t.ID],
+				SameClass:   classTeachers[lesson.ClassID][t.ID],
+				LessonCount: dailyCount[lesson.Date][t.ID],
+			})
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.SameSubject != b.SameSubject {
+				return a.SameSubject
+			}
+			if a.SameClass != b.SameClass {
+				return a.SameClass
+			}
+			return a.LessonCount < b.LessonCount
+		})
+
+		slots = append(slots, models.SubstituteSlot{Lesson: lesson, Candidates: candidates})
+	}
+
+	return slots, nil
+}
+
+// loadTeachers - 教員一覧（名前順）
+func (s *SubstituteService) loadTeachers() ([]models.User, error) {
+	rows, err := s.db.Query("SELECT id, name FROM users WHERE role = ? ORDER BY name", models.RoleTeacher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	teachers := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		teachers = append(teachers, u)
+	}
+
+	return teachers, rows.Err()
+}
+
+// loadUnavailability - 教員ごとの都合の悪い時間
+func (s *SubstituteService) loadUnavailability() (map[int][]models.TeacherUnavailability, error) {
+	rows, err := s.db.Query("SELECT teacher_id, day_of_week, period FROM teacher_unavailability")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	unavailable := make(map[int][]models.TeacherUnavailability)
+	for rows.Next() {
+		var u models.TeacherUnavailability
+		var period sql.NullInt64
+		if err := rows.Scan(&u.TeacherID, &u.DayOfWeek, &period); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		u.Period = nullIntPtr(period)
+		unavailable[u.TeacherID] = append(unavailable[u.TeacherID], u)
+	}
+
+	return unavailable, rows.Err()
+}
+
+// loadAssignmentTeachers - 担当者CSVの割り当てから、科目ごと・クラスごとの担当教員
+func (s *SubstituteService) loadAssignmentTeachers() (map[int]map[int]bool, map[int]map[int]bool, error) {
+	sqlStr, args, err := squirrel.Select("a.subject_id", "a.class_id", "t.teacher_id").
+		From("subject_assignments a").
+		Join("subject_assignment_teachers t ON t.assignment_id = a.id").
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	subjectTeachers := make(map[int]map[int]bool)
+	classTeachers := make(map[int]map[int]bool)
+	for rows.Next() {
+		var subjectID, classID, teacherID int
+		if err := rows.Scan(&subjectID, &classID, &teacherID); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if subjectTeachers[subjectID] == nil {
+			subjectTeachers[subjectID] = make(map[int]bool)
+		}
+		subjectTeachers[subjectID][teacherID] = true
+		if classTeachers[classID] == nil {
+			classTeachers[classID] = make(map[int]bool)
+		}
+		classTeachers[classID][teacherID] = true
+	}
+
+	return subjectTeachers, classTeachers, rows.Err()
+}
+
+// isUnavailable - 都合の悪い時間に当たるか
+func isUnavailable(items []models.TeacherUnavailability, dayOfWeek string, period int) bool {
+	for _, u := range items {
+		if u.DayOfWeek == dayOfWeek && (u.Period == nil || *u.Period == period) {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteOverrides - 代講申請の内容を日付指定の代講（1コマごと）に変換する
+// 共同担当の授業では申請者（requesterID）だけを代講の教員に入れ替える
+func substituteOverrides(data *models.TimetableChangeData, requesterID int) ([]*models.TimetableOverride, error) {
+	if len(data.Substitutions) == 0 {
+		return nil, fmt.Errorf("代講する授業を指定してください")
+	}
+
+	overrides := make([]*models.TimetableOverride, 0, len(data.Substitutions))
+	for _, sub := range data.Substitutions {
+		if sub.TimetableID == 0 || sub.Date == "" || sub.TeacherID == 0 {
+			return nil, fmt.Errorf("代講する授業・日付・教員を指定してください")
+		}
+		// 期間が指定されている場合は期間内の授業のみ
+		if (data.Date != "" && sub.Date < data.Date) || (data.DateTo != "" && sub.Date > data.DateTo) {
+			return nil, fmt.Errorf("%s は申請の期間外です", sub.Date)
+		}
+
+		teacherID := sub.TeacherID
+		override := &models.TimetableOverride{
+			TimetableID:  sub.TimetableID,
+			Type:         models.OverrideSubstitute,
+			Date:         sub.Date,
+			NewTeacherID: &teacherID,
+			Note:         data.Reason,
+		}
+		if requesterID != 0 {
+			replacedID := requesterID
+			override.ReplacedTeacherID = &replacedID
+		}
+		overrides = append(overrides, override)
+	}
+
+	return overrides, nil
+}
+
+// validateSubstituteLessons - 代講を依頼する授業が申請者の担当授業（共同担当を含む）か確認
+func validateSubstituteLessons(q queryer, requesterID int, data *models.TimetableChangeData) error {
+	for _, sub := range data.Substitutions {
+		var teacherID int
+		err := q.QueryRow("SELECT teacher_id FROM timetables WHERE id = ?", sub.TimetableID).Scan(&teacherID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("代講する授業が見つかりません")
+			}
+			return err
+		}
+		lesson := []models.TimetableSnapshot{{ID: sub.TimetableID, TeacherID: teacherID}}
+		if err := loadCoTeachers(q, lesson); err != nil {
+			return err
+		}
+		if !containsInt(lessonTeachers(&lesson[0]), requesterID) {
+			return fmt.Errorf("担当していない授業の代講は申請できません")
+		}
+		if sub.TeacherID == requesterID {
+			return fmt.Errorf("代講する教員に申請者本人は指定できません")
+		}
+		if containsInt(lessonTeachers(&lesson[0]), sub.TeacherID) {
+			return fmt.Errorf("代講する教員はすでにこの授業の担当です")
+		}
+	}
+	return nil
+}
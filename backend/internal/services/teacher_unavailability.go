@@ -0,0 +1,96 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+)
+
+type TeacherUnavailabilityService struct {
+	db *sql.DB
+}
+
+func NewTeacherUnavailabilityService(db *sql.DB) *TeacherUnavailabilityService {
+	return &TeacherUnavailabilityService{db: db}
+}
+
+// GetTeacherUnavailability - 教員の都合の悪い時間一覧
+func (s *TeacherUnavailabilityService) GetTeacherUnavailability(teacherID int) ([]models.TeacherUnavailability, error) {
+	rows, err := s.db.Query(`
+		SELECT id, teacher_id, day_of_week, period, reason, created_at
+		FROM teacher_unavailability
+		WHERE teacher_id = ?
+		ORDER BY FIELD(day_of_week, 'monday', 'tuesday', 'wednesday', 'thursday', 'friday'), period
+	`, teacherID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	items := []models.TeacherUnavailability{}
+	for rows.Next() {
+		var u models.TeacherUnavailability
+		var period sql.NullInt64
+		if err := rows.Scan(&u.ID, &u.TeacherID, &u.DayOfWeek, &period, &u.Reason, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		u.Period = nullIntPtr(period)
+		items = append(items, u)
+	}
+
+	return items, nil
+}
+
+// AddTeacherUnavailability - 教員の都合の悪い時間を登録（時限を省略すると終日）
+func (s *TeacherUnavailabilityService) AddTeacherUnavailability(teacherID int, req *models.CreateTeacherUnavailabilityRequest) (*models.TeacherUnavailability, error) {
+	if !timetableDays[req.DayOfWeek] {
+		return nil, fmt.Errorf("曜日が不正です: %s", req.DayOfWeek)
+	}
+	if req.Period != nil && (*req.Period < models.Period1 || *req.Period > models.Period4) {
+		return nil, fmt.Errorf("時限が不正です: %d", *req.Period)
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO teacher_unavailability (teacher_id, day_of_week, period, reason) VALUES (?, ?, ?, ?)",
+		teacherID, req.DayOfWeek, req.Period, req.Reason,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unavailability: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	var u models.TeacherUnavailability
+	var period sql.NullInt64
+	err = s.db.QueryRow(
+		"SELECT id, teacher_id, day_of_week, period, reason, created_at FROM teacher_unavailability WHERE id = ?", id,
+	).Scan(&u.ID, &u.TeacherID, &u.DayOfWeek, &period, &u.Reason, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	u.Period = nullIntPtr(period)
+
+	return &u, nil
+}
+
+// DeleteTeacherUnavailability - 教員の都合の悪い時間を削除
+func (s *TeacherUnavailabilityService) DeleteTeacherUnavailability(teacherID, id int) error {
+	result, err := s.db.Exec("DELETE FROM teacher_unavailability WHERE id = ? AND teacher_id = ?", id, teacherID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("unavailability not found")
+	}
+
+	return nil
+}
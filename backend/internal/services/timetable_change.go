@@ -0,0 +1,310 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// ErrSlotOccupied - 変更先のコマに別の授業が入っている（ConflictError がクラスの重複を含む場合も該当）
+var ErrSlotOccupied = errors.New("変更先のコマは既に使用されています")
+
+// 時間割の曜日（DB の day_of_week）
+var timetableDays = map[string]bool{
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true,
+}
+
+// applyTimetableChange - 申請内容を時間割へ反映し、反映前後の内容を記録する（呼び出し側のトランザクション内で実行）
+func (s *ChangeRequestService) applyTimetableChange(tx *sql.Tx, requestID int, requestData json.RawMessage) ([]models.ChangeRequestApplication, error) {
+	var data models.TimetableChangeData
+	if err := json.Unmarshal(requestData, &data); err != nil {
+		return nil, fmt.Errorf("申請データが不正です: %v", err)
+	}
+	if data.RequestType == models.RequestTypeBatch {
+		return s.applyBatch(tx, requestID, &data)
+	}
+
+	return s.applyChangeData(tx, requestID, &data)
+}
+
+// applyChangeData - 1件の変更を時間割へ反映し、反映前後の内容を記録する
+func (s *ChangeRequestService) applyChangeData(tx *sql.Tx, requestID int, data *models.TimetableChangeData) ([]models.ChangeRequestApplication, error) {
+	if data.RequestType == models.RequestTypeSubstitute {
+		return s.applySubstitutes(tx, requestID, data)
+	}
+	if data.OriginalTimetableID == 0 {
+		return nil, fmt.Errorf("変更対象の時間割が指定されていません")
+	}
+
+	if data.RequestType == models.RequestTypeSwap {
+		return s.applySwap(tx, requestID, data)
+	}
+
+	// 日付指定の変更は週間時間割を変えずに、その日の変更として登録する
+	if data.Date != "" {
+		override := overrideFromChangeData(data)
+		override.ChangeRequestID = &requestID
+		if err := applyOverride(tx, s.conflictService, override); err != nil {
+			return nil, err
+		}
+
+		application, err := recordOverrideApplication(tx, requestID, override)
+		if err != nil {
+			return nil, err
+		}
+		return []models.ChangeRequestApplication{*application}, nil
+	}
+
+	before, err := loadTimetableSnapshot(tx, data.OriginalTimetableID)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := changedSnapshot(tx, before, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 反映後の時間割がほかの授業・教員の予定とぶつからないか確認
+	conflicts, err := s.conflictService.CheckLessons(tx, []models.TimetableSnapshot{*after}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	if err := updateTimetable(tx, after); err != nil {
+		return nil, err
+	}
+
+	application, err := recordApplication(tx, requestID, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.ChangeRequestApplication{*application}, nil
+}
+
+// applySubstitutes - 代講申請の各コマを日付指定の代講として登録する（すべて登録するか、どれも登録しない）
+func (s *ChangeRequestService) applySubstitutes(tx *sql.Tx, requestID int, data *models.TimetableChangeData) ([]models.ChangeRequestApplication, error) {
+	var requesterID int
+	if err := tx.QueryRow("SELECT requester_id FROM change_requests WHERE id = ?", requestID).Scan(&requesterID); err != nil {
+		return nil, err
+	}
+	overrides, err := substituteOverrides(data, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		o.ChangeRequestID = &requestID
+	}
+	if err := applyOverrides(tx, s.conflictService, overrides); err != nil {
+		return nil, err
+	}
+
+	return recordOverrideApplications(tx, requestID, overrides)
+}
+
+// changedSnapshot - 申請内容を反映した後の時間割（1コマ）
+func changedSnapshot(q queryer, before *models.TimetableSnapshot, data *models.TimetableChangeData) (*models.TimetableSnapshot, error) {
+	after := *before
+	if data.NewClassID != 0 && data.NewClassID != after.ClassID {
+		// グループはクラスごとのため、別クラスへ移す場合はクラス全体の授業にする
+		after.ClassID = data.NewClassID
+		after.GroupID = nil
+	}
+	if data.NewSubjectID != 0 {
+		after.SubjectID = data.NewSubjectID
+	}
+	if data.NewTeacherID != 0 {
+		after.TeacherID = data.NewTeacherID
+	}
+	if data.NewDay != "" {
+		if !timetableDays[data.NewDay] {
+			return nil, fmt.Errorf("曜日が不正です: %s", data.NewDay)
+		}
+		after.DayOfWeek = data.NewDay
+	}
+	if data.NewPeriod != 0 {
+		if data.NewPeriod < models.Period1 || data.NewPeriod > models.Period4 {
+			return nil, fmt.Errorf("時限が不正です: %d", data.NewPeriod)
+		}
+		after.Period = data.NewPeriod
+	}
+	if data.NewRoom != "" {
+		room, err := resolveRoomName(q, data.NewRoom)
+		if err != nil {
+			return nil, err
+		}
+		after.Room = room
+	}
+	return &after, nil
+}
+
+// updateTimetable - 時間割（1コマ）を変更後の内容で更新
+func updateTimetable(tx *sql.Tx, after *models.TimetableSnapshot) error {
+	_, err := tx.Exec(`
+		UPDATE timetables
+		SET class_id = ?, group_id = ?, subject_id = ?, teacher_id = ?, day_of_week = ?, period = ?, room = ?, updated_at = NOW()
+		WHERE id = ?
+	`, after.ClassID, after.GroupID, after.SubjectID, after.TeacherID, after.DayOfWeek, after.Period, after.Room, after.ID)
+	if err != nil {
+		return fmt.Errorf("時間割の更新に失敗しました: %v", err)
+	}
+	return nil
+}
+
+// loadTimetableSnapshot - 時間割（1コマ）を共同担当教員とあわせて取得（トランザクション内では行ロックする）
+func loadTimetableSnapshot(q queryer, id int) (*models.TimetableSnapshot, error) {
+	var t models.TimetableSnapshot
+	var groupID sql.NullInt64
+	err := q.QueryRow(`
+		SELECT id, class_id, group_id, subject_id, teacher_id, day_of_week, period, room
+		FROM timetables WHERE id = ? FOR UPDATE
+	`, id).Scan(&t.ID, &t.ClassID, &groupID, &t.SubjectID, &t.TeacherID, &t.DayOfWeek, &t.Period, &t.Room)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("変更対象の時間割が見つかりません")
+		}
+		return nil, err
+	}
+	t.GroupID = nullIntPtr(groupID)
+
+	lessons := []models.TimetableSnapshot{t}
+	if err := loadCoTeachers(q, lessons); err != nil {
+		return nil, err
+	}
+
+	return &lessons[0], nil
+}
+
+// recordApplication - 反映前後の内容を記録
+func recordApplication(tx *sql.Tx, requestID int, before, after *models.TimetableSnapshot) (*models.ChangeRequestApplication, error) {
+	application := &models.ChangeRequestApplication{
+		ChangeRequestID: requestID,
+		Before:          before,
+		After:           after,
+	}
+
+	var beforeJSON, afterJSON interface{}
+	if before != nil {
+		b, err := json.Marshal(before)
+		if err != nil {
+			return nil, err
+		}
+		beforeJSON = string(b)
+		application.TimetableID = &before.ID
+	}
+	if after != nil {
+		b, err := json.Marshal(after)
+		if err != nil {
+			return nil, err
+		}
+		afterJSON = string(b)
+		application.TimetableID = &after.ID
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO change_request_applications (change_request_id, timetable_id, before_data, after_data)
+		VALUES (?, ?, ?, ?)
+	`, requestID, application.TimetableID, beforeJSON, afterJSON)
+	if err != nil {
+		return nil, fmt.Errorf("反映記録の保存に失敗しました: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	application.ID = int(id)
+
+	return application, nil
+}
+
+// recordOverrideApplication - 申請によって登録した日付指定の変更を記録
+func recordOverrideApplication(tx *sql.Tx, requestID int, override *models.TimetableOverride) (*models.ChangeRequestApplication, error) {
+	result, err := tx.Exec(`
+		INSERT INTO change_request_applications (change_request_id, timetable_id, override_id)
+		VALUES (?, ?, ?)
+	`, requestID, override.TimetableID, override.ID)
+	if err != nil {
+		return nil, fmt.Errorf("反映記録の保存に失敗しました: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ChangeRequestApplication{
+		ID:              int(id),
+		ChangeRequestID: requestID,
+		TimetableID:     &override.TimetableID,
+		OverrideID:      &override.ID,
+	}, nil
+}
+
+// recordOverrideApplications - 申請によって登録した複数の日付指定の変更を記録
+func recordOverrideApplications(tx *sql.Tx, requestID int, overrides []*models.TimetableOverride) ([]models.ChangeRequestApplication, error) {
+	applications := []models.ChangeRequestApplication{}
+	for _, o := range overrides {
+		application, err := recordOverrideApplication(tx, requestID, o)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, *application)
+	}
+	return applications, nil
+}
+
+// GetChangeRequestApplications - 申請によって変更された時間割の記録
+func (s *ChangeRequestService) GetChangeRequestApplications(requestID int) ([]models.ChangeRequestApplication, error) {
+	return loadApplications(s.db, requestID)
+}
+
+// loadApplications - 申請の反映記録（反映した順）
+func loadApplications(q queryer, requestID int) ([]models.ChangeRequestApplication, error) {
+	rows, err := q.Query(`
+		SELECT id, change_request_id, timetable_id, override_id, before_data, after_data, applied_at, reverted_at
+		FROM change_request_applications
+		WHERE change_request_id = ?
+		ORDER BY id
+	`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	applications := []models.ChangeRequestApplication{}
+	for rows.Next() {
+		var a models.ChangeRequestApplication
+		var timetableID, overrideID sql.NullInt64
+		var beforeJSON, afterJSON sql.NullString
+		var revertedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.ChangeRequestID, &timetableID, &overrideID, &beforeJSON, &afterJSON, &a.AppliedAt, &revertedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		a.TimetableID = nullIntPtr(timetableID)
+		a.OverrideID = nullIntPtr(overrideID)
+		if revertedAt.Valid {
+			a.RevertedAt = &revertedAt.Time
+		}
+		if beforeJSON.Valid {
+			if err := json.Unmarshal([]byte(beforeJSON.String), &a.Before); err != nil {
+				return nil, err
+			}
+		}
+		if afterJSON.Valid {
+			if err := json.Unmarshal([]byte(afterJSON.String), &a.After); err != nil {
+				return nil, err
+			}
+		}
+		applications = append(applications, a)
+	}
+
+	return applications, rows.Err()
+}
@@ -0,0 +1,636 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+const dateLayout = "2006-01-02"
+
+// ErrLessonAlreadyChanged - 休講・移動済みの授業に、同じ日の別の変更を登録しようとした
+var ErrLessonAlreadyChanged = errors.New("この日の授業はすでに休講・移動されています。先にその変更を取り消してください")
+
+// 実際の時間割を一度に取得できる最大日数
+const maxEffectiveDays = 62
+
+type TimetableOverrideService struct {
+	db              *sql.DB
+	conflictService *ConflictService
+	broker          *EventBroker
+}
+
+func NewTimetableOverrideService(db *sql.DB) *TimetableOverrideService {
+	return &TimetableOverrideService{
+		db:              db,
+		conflictService: NewConflictService(db),
+	}
+}
+
+// SetEventBroker - 日付指定の変更を配信する先を設定する（設定しなければ配信しない）
+func (s *TimetableOverrideService) SetEventBroker(broker *EventBroker) {
+	s.broker = broker
+}
+
+// GetOverrides - 期間内の日付指定の変更一覧（変更対象日・移動先の日付のどちらかが期間内のもの）
+func (s *TimetableOverrideService) GetOverrides(from, to time.Time) ([]models.TimetableOverride, error) {
+	return loadOverrides(s.db, from, to)
+}
+
+// CreateOverride - 日付指定の変更を登録（競合がある場合は登録しない）
+func (s *TimetableOverrideService) CreateOverride(req *models.CreateTimetableOverrideRequest, userID int) (*models.TimetableOverride, error) {
+	override := &models.TimetableOverride{
+		TimetableID:       req.TimetableID,
+		Type:              req.Type,
+		Date:              req.Date,
+		NewDate:           req.NewDate,
+		NewPeriod:         req.NewPeriod,
+		NewTeacherID:      req.NewTeacherID,
+		ReplacedTeacherID: req.ReplacedTeacherID,
+		NewRoom:           req.NewRoom,
+		Note:              req.Note,
+		CreatedBy:         &userID,
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := applyOverride(tx, s.conflictService, override); err != nil {
+		return nil, err
+	}
+	if err := notifyOverrides(tx, []*models.TimetableOverride{override}, userID); err != nil {
+		return nil, err
+	}
+	event, err := overrideEvent(tx, override.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := enqueueTimetableWebhook(tx, event); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.broker.Publish(event)
+
+	return override, nil
+}
+
+// DeleteOverride - 日付指定の変更を取り消す
+func (s *TimetableOverrideService) DeleteOverride(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 削除すると対象の授業がわからなくなるため、先に配信する内容を作っておく
+	event, err := overrideEvent(tx, id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM timetable_overrides WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("override not found")
+	}
+	if err := enqueueTimetableWebhook(tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.broker.Publish(event)
+	return nil
+}
+
+// applyOverride - 日付指定の変更を検証・競合チェックして登録する（呼び出し側のトランザクション内で実行）
+func applyOverride(tx *sql.Tx, conflictService *ConflictService, override *models.TimetableOverride) error {
+	return applyOverrides(tx, conflictService, []*models.TimetableOverride{override})
+}
+
+// applyOverrides - 同時に反映する日付指定の変更をまとめて競合チェックして登録する
+func applyOverrides(tx *sql.Tx, conflictService *ConflictService, overrides []*models.TimetableOverride) error {
+	conflicts, err := conflictService.CheckOverrides(tx, overrides)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+
+	for _, override := range overrides {
+		if err := insertOverride(tx, override); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertOverride - 日付指定の変更を保存
+func insertOverride(tx *sql.Tx, override *models.TimetableOverride) error {
+	var newDate interface{}
+	if override.NewDate != "" {
+		newDate = override.NewDate
+	}
+	var newRoom interface{}
+	if override.NewRoom != "" {
+		newRoom = override.NewRoom
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO timetable_overrides
+			(timetable_id, change_request_id, override_type, date, new_date, new_period, new_teacher_id, replaced_teacher_id,
+			 new_room, note, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, override.TimetableID, override.ChangeRequestID, override.Type, override.Date, newDate,
+		override.NewPeriod, override.NewTeacherID, override.ReplacedTeacherID, newRoom, override.Note, override.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("日付指定の変更の登録に失敗しました: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	override.ID = int(id)
+	override.CreatedAt = time.Now()
+
+	return nil
+}
+
+// validateOverride - 変更内容を検証し、元の授業を返す
+func validateOverride(q queryer, override *models.TimetableOverride) (*models.TimetableSnapshot, error) {
+	base, err := loadTimetableSnapshot(q, override.TimetableID)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse(dateLayout, override.Date)
+	if err != nil {
+		return nil, fmt.Errorf("日付が不正です: %s", override.Date)
+	}
+	if weekdayName(date) != base.DayOfWeek {
+		return nil, fmt.Errorf("%s にこの授業はありません", override.Date)
+	}
+
+	// 休講・移動した授業に重ねると、移動先が増えたり休講した授業が戻ったりするため受け付けない
+	var removed int
+	err = q.QueryRow(`
+		SELECT COUNT(*) FROM timetable_overrides
+		WHERE timetable_id = ? AND date = ? AND override_type IN (?, ?)
+	`, override.TimetableID, override.Date, models.OverrideCancel, models.OverrideMove).Scan(&removed)
+	if err != nil {
+		return nil, err
+	}
+	if removed > 0 {
+		return nil, ErrLessonAlreadyChanged
+	}
+
+	switch override.Type {
+	case models.OverrideCancel:
+	case models.OverrideMove:
+		if override.NewDate == "" && override.NewPeriod == nil {
+			return nil, fmt.Errorf("移動先の日付または時限を指定してください")
+		}
+	case models.OverrideSubstitute:
+		if override.NewTeacherID == nil {
+			return nil, fmt.Errorf("代講の教員を指定してください")
+		}
+		if override.ReplacedTeacherID != nil && !containsInt(lessonTeachers(base), *override.ReplacedTeacherID) {
+			return nil, fmt.Errorf("交代する教員はこの授業の担当ではありません")
+		}
+	case models.OverrideRoomChange:
+		if override.NewRoom == "" {
+			return nil, fmt.Errorf("変更後の教室を指定してください")
+		}
+	default:
+		return nil, fmt.Errorf("変更の種類が不正です: %s", override.Type)
+	}
+
+	// 教室は登録済みの教室名に揃える（別表記で指定されても重複を確認できるように）
+	if override.NewRoom != "" {
+		room, err := resolveRoomName(q, override.NewRoom)
+		if err != nil {
+			return nil, err
+		}
+		override.NewRoom = room
+	}
+
+	if override.NewDate != "" {
+		newDate, err := time.Parse(dateLayout, override.NewDate)
+		if err != nil {
+			return nil, fmt.Errorf("移動先の日付が不正です: %s", override.NewDate)
+		}
+		if !timetableDays[weekdayName(newDate)] {
+			return nil, fmt.Errorf("移動先の日付が授業日ではありません: %s", override.NewDate)
+		}
+	}
+	if override.NewPeriod != nil && (*override.NewPeriod < models.Period1 || *override.NewPeriod > models.Period4) {
+		return nil, fmt.Errorf("時限が不正です: %d", *override.NewPeriod)
+	}
+
+	return base, nil
+}
+
+// overrideFromChangeData - 日付指定の変更申請の内容を変更データに変換（種類の指定がなければ内容から判断する）
+func overrideFromChangeData(data *models.TimetableChangeData) *models.TimetableOverride {
+	override := &models.TimetableOverride{
+		TimetableID: data.OriginalTimetableID,
+		Type:        data.ChangeType,
+		Date:        data.Date,
+		NewDate:     data.NewDate,
+		NewRoom:     data.NewRoom,
+		Note:        data.Reason,
+	}
+	if data.NewPeriod != 0 {
+		period := data.NewPeriod
+		override.NewPeriod = &period
+	}
+	if data.NewTeacherID != 0 {
+		teacherID := data.NewTeacherID
+		override.NewTeacherID = &teacherID
+	}
+
+	if override.Type == "" {
+		switch {
+		case override.NewDate != "" || override.NewPeriod != nil:
+			override.Type = models.OverrideMove
+		case override.NewTeacherID != nil:
+			override.Type = models.OverrideSubstitute
+		case override.NewRoom != "":
+			override.Type = models.OverrideRoomChange
+		default:
+			override.Type = models.OverrideCancel
+		}
+	}
+
+	return override
+}
+
+// loadOverrides - 変更対象日・移動先の日付のどちらかが期間内の変更
+func loadOverrides(q queryer, from, to time.Time) ([]models.TimetableOverride, error) {
+	rows, err := q.Query(`
+		SELECT `+overrideColumns+`
+		FROM timetable_overrides
+		WHERE date BETWEEN ? AND ? OR new_date BETWEEN ? AND ?
+		ORDER BY id
+	`, from.Format(dateLayout), to.Format(dateLayout), from.Format(dateLayout), to.Format(dateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	return scanOverrides(rows)
+}
+
+// overrideColumns - scanOverrides で読む日付指定の変更の列
+const overrideColumns = `id, timetable_id, change_request_id, override_type, date, new_date, new_period, new_teacher_id,
+		       replaced_teacher_id, COALESCE(new_room, ''), note, created_by, created_at`
+
+// scanOverrides - overrideColumns を選択した結果を読む
+func scanOverrides(rows *sql.Rows) ([]models.TimetableOverride, error) {
+	overrides := []models.TimetableOverride{}
+	for rows.Next() {
+		var o models.TimetableOverride
+		var changeRequestID, newPeriod, newTeacherID, replacedTeacherID, createdBy sql.NullInt64
+		var date time.Time
+		var newDate sql.NullTime
+		err := rows.Scan(&o.ID, &o.TimetableID, &changeRequestID, &o.Type, &date, &newDate, &newPeriod, &newTeacherID,
+			&replacedTeacherID, &o.NewRoom, &o.Note, &createdBy, &o.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		o.Date = date.Format(dateLayout)
+		if newDate.Valid {
+			o.NewDate = newDate.Time.Format(dateLayout)
+		}
+		o.ChangeRequestID = nullIntPtr(changeRequestID)
+		o.NewPeriod = nullIntPtr(newPeriod)
+		o.NewTeacherID = nullIntPtr(newTeacherID)
+		o.ReplacedTeacherID = nullIntPtr(replacedTeacherID)
+		o.CreatedBy = nullIntPtr(createdBy)
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
+
+// loadEffectiveLessons - 期間内の各日の授業を、週間時間割に日付指定の変更を重ねて求める
+func loadEffectiveLessons(q queryer, from, to time.Time) ([]models.EffectiveLesson, error) {
+	from = truncateDate(from)
+	to = truncateDate(to)
+	if to.Before(from) {
+		return nil, fmt.Errorf("期間が不正です")
+	}
+	if to.Sub(from) > maxEffectiveDays*24*time.Hour {
+		return nil, fmt.Errorf("期間は%d日以内で指定してください", maxEffectiveDays)
+	}
+
+	// 週間時間割
+	sqlStr, args, err := timetableSelect().ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	rows, err := q.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var timetables []models.Timetable
+	for rows.Next() {
+		t, err := scanTimetable(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		timetables = append(timetables, *t)
+	}
+	rows.Close()
+	if err := loadTimetableCoTeachers(q, timetables); err != nil {
+		return nil, err
+	}
+
+	overrides, err := loadOverrides(q, from, to)
+	if err != nil {
+		return nil, err
+	}
+	teacherNames, err := loadOverrideTeacherNames(q, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	// 各日の通常の授業
+	lessons := &effectiveLessons{index: make(map[lessonKey]*models.EffectiveLesson)}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := weekdayName(d)
+		for i := range timetables {
+			if timetables[i].DayOfWeek == day {
+				lessons.regular(&timetables[i], d.Format(dateLayout))
+			}
+		}
+	}
+
+	// 日付指定の変更を登録順に重ねる
+	lessons.overlay(timetables, overrides, teacherNames, from, to)
+
+	return lessons.sorted(), nil
+}
+
+// loadChangedLessons - 期間内の日付指定の変更で通常どおりでなくなった授業
+// overrides は対象の授業の変更を登録順にすべて含むこと。変更のない日の授業は求めないため、期間の上限はない
+func loadChangedLessons(q queryer, timetables []models.Timetable, overrides []models.TimetableOverride, from, to time.Time) ([]models.EffectiveLesson, error) {
+	teacherNames, err := loadOverrideTeacherNames(q, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	lessons := &effectiveLessons{index: make(map[lessonKey]*models.EffectiveLesson)}
+	lessons.overlay(timetables, overrides, teacherNames, truncateDate(from), truncateDate(to))
+
+	changed := []models.EffectiveLesson{}
+	for _, lesson := range lessons.sorted() {
+		if lesson.Status != models.LessonRegular {
+			changed = append(changed, lesson)
+		}
+	}
+	return changed, nil
+}
+
+// lessonKey - 日ごとの授業（週間時間割の授業と日付）
+type lessonKey struct {
+	timetableID int
+	date        string
+}
+
+// effectiveLessons - 週間時間割に日付指定の変更を重ねた各日の授業
+type effectiveLessons struct {
+	lessons []*models.EffectiveLesson
+	index   map[lessonKey]*models.EffectiveLesson
+}
+
+// regular - 通常どおりの授業を追加する
+func (e *effectiveLessons) regular(t *models.Timetable, date string) *models.EffectiveLesson {
+	lesson := &models.EffectiveLesson{
+		Timetable: *t,
+		Date:      date,
+		Status:    models.LessonRegular,
+	}
+	e.lessons = append(e.lessons, lesson)
+	e.index[lessonKey{lesson.ID, lesson.Date}] = lesson
+	return lesson
+}
+
+// overlay - 日付指定の変更を登録順に重ねる（変更する日の授業がまだなければ通常どおりの授業から始める）
+func (e *effectiveLessons) overlay(timetables []models.Timetable, overrides []models.TimetableOverride, teacherNames map[int]string, from, to time.Time) {
+	byID := make(map[int]*models.Timetable, len(timetables))
+	for i := range timetables {
+		byID[timetables[i].ID] = &timetables[i]
+	}
+
+	for _, o := range overrides {
+		base, ok := byID[o.TimetableID]
+		if !ok {
+			continue
+		}
+		lesson := e.index[lessonKey{o.TimetableID, o.Date}]
+		if lesson == nil {
+			if date, err := time.Parse(dateLayout, o.Date); err == nil && !date.Before(from) && !date.After(to) && weekdayName(date) == base.DayOfWeek {
+				lesson = e.regular(base, o.Date)
+			}
+		}
+
+		switch o.Type {
+		case models.OverrideCancel:
+			if lesson != nil {
+				markLesson(lesson, o, models.LessonCanceled)
+			}
+
+		case models.OverrideMove:
+			source := base
+			if lesson != nil {
+				source = &lesson.Timetable
+				markLesson(lesson, o, models.LessonMovedOut)
+			}
+
+			target := o.Date
+			if o.NewDate != "" {
+				target = o.NewDate
+			}
+			targetDate, err := time.Parse(dateLayout, target)
+			if err != nil || targetDate.Before(from) || targetDate.After(to) {
+				continue
+			}
+
+			moved := &models.EffectiveLesson{
+				Timetable:      *source,
+				Date:           target,
+				Status:         models.LessonMovedIn,
+				OriginalDate:   o.Date,
+				OriginalPeriod: base.Period,
+			}
+			moved.DayOfWeek = weekdayName(targetDate)
+			if o.NewPeriod != nil {
+				moved.Period = *o.NewPeriod
+			}
+			applyOverrideDetails(moved, o, teacherNames)
+			moved.OverrideIDs = []int{o.ID}
+			moved.Note = o.Note
+			moved.IsChanged = true
+			e.lessons = append(e.lessons, moved)
+
+		case models.OverrideSubstitute, models.OverrideRoomChange:
+			if lesson == nil || lesson.Status == models.LessonCanceled || lesson.Status == models.LessonMovedOut {
+				continue
+			}
+			applyOverrideDetails(lesson, o, teacherNames)
+			status := models.LessonSubstituted
+			if o.Type == models.OverrideRoomChange {
+				status = models.LessonRoomChanged
+			}
+			if lesson.Status != models.LessonRegular {
+				status = lesson.Status
+			}
+			markLesson(lesson, o, status)
+		}
+	}
+}
+
+// sorted - 日付・時限・学年・クラスの順に並べた授業
+func (e *effectiveLessons) sorted() []models.EffectiveLesson {
+	result := make([]models.EffectiveLesson, 0, len(e.lessons))
+	for _, lesson := range e.lessons {
+		result = append(result, *lesson)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		if result[i].Period != result[j].Period {
+			return result[i].Period < result[j].Period
+		}
+		if result[i].Grade != result[j].Grade {
+			return result[i].Grade < result[j].Grade
+		}
+		return result[i].ClassName < result[j].ClassName
+	})
+
+	return result
+}
+
+// markLesson - 変更を反映した授業に状態と変更IDを記録
+func markLesson(lesson *models.EffectiveLesson, o models.TimetableOverride, status string) {
+	lesson.Status = status
+	lesson.IsChanged = true
+	lesson.OverrideIDs = append(lesson.OverrideIDs, o.ID)
+	if o.Note != "" {
+		lesson.Note = o.Note
+	}
+}
+
+// applyOverrideDetails - 代講の教員・変更後の教室を反映
+func applyOverrideDetails(lesson *models.EffectiveLesson, o models.TimetableOverride, teacherNames map[int]string) {
+	if o.NewTeacherID != nil {
+		teacherID, coTeacherIDs := substituteTeacher(lesson.TeacherID, lesson.CoTeacherIDs, o.ReplacedTeacherID, *o.NewTeacherID)
+		if teacherID != lesson.TeacherID {
+			lesson.TeacherName = teacherNames[teacherID]
+		}
+		lesson.TeacherID = teacherID
+		lesson.CoTeacherIDs = coTeacherIDs
+	}
+	if o.NewRoom != "" {
+		lesson.Room = o.NewRoom
+	}
+}
+
+// substituteTeacher - 代講で入れ替えた後の主担当と共同担当
+// replaced が共同担当ならその教員だけを入れ替え、それ以外（nil・主担当）は主担当を入れ替える
+func substituteTeacher(teacherID int, coTeacherIDs []int, replaced *int, newTeacherID int) (int, []int) {
+	if replaced == nil || *replaced == teacherID || !containsInt(coTeacherIDs, *replaced) {
+		return newTeacherID, coTeacherIDs
+	}
+
+	// 共同担当の一覧は週間時間割の授業と共有しているため、書き換えずに作り直す
+	result := make([]int, 0, len(coTeacherIDs))
+	for _, id := range coTeacherIDs {
+		if id == *replaced {
+			id = newTeacherID
+		}
+		result = append(result, id)
+	}
+	return teacherID, result
+}
+
+// loadOverrideTeacherNames - 代講の教員名
+func loadOverrideTeacherNames(q queryer, overrides []models.TimetableOverride) (map[int]string, error) {
+	names := make(map[int]string)
+	var ids []int
+	for _, o := range overrides {
+		if o.NewTeacherID != nil {
+			ids = append(ids, *o.NewTeacherID)
+		}
+	}
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	sqlStr, args, err := squirrel.Select("id", "name").
+		From("users").
+		Where(squirrel.Eq{"id": ids}).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := q.Query(sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		names[id] = name
+	}
+
+	return names, rows.Err()
+}
+
+// isActiveLesson - 実際に行われる授業か（休講・移動元を除く）
+func isActiveLesson(lesson *models.EffectiveLesson) bool {
+	return lesson.Status != models.LessonCanceled && lesson.Status != models.LessonMovedOut
+}
+
+// weekdayName - 日付の曜日（DB の day_of_week の表記）
+func weekdayName(t time.Time) string {
+	return strings.ToLower(t.Weekday().String())
+}
+
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -2,40 +2,104 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"kosen-schedule-system/internal/models"
 
 	"github.com/Masterminds/squirrel"
 )
 
+// ErrNotEnrolled - 学生の所属クラスが登録されていない
+var ErrNotEnrolled = errors.New("所属クラスが登録されていません")
+
+// ErrTeacherNotFound - 教員が見つからない
+var ErrTeacherNotFound = errors.New("教員が見つかりません")
+
 type TimetableService struct {
-	db *sql.DB
+	db                *sql.DB
+	enrollmentService *EnrollmentService
+	classGroupService *ClassGroupService
 }
 
 func NewTimetableService(db *sql.DB) *TimetableService {
-	return &TimetableService{db: db}
+	return &TimetableService{
+		db:                db,
+		enrollmentService: NewEnrollmentService(db),
+		classGroupService: NewClassGroupService(db),
+	}
 }
 
-func (s *TimetableService) GetTimetables(filter models.TimetableFilter) ([]models.Timetable, error) {
-	query := squirrel.Select(
-		"t.id", "t.class_id", "t.subject_id", "t.teacher_id",
+func timetableSelect() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"t.id", "t.class_id", "t.group_id", "t.subject_id", "t.teacher_id",
 		"t.day_of_week", "t.period", "t.room", "t.created_at",
-		"c.class_name", "c.grade", "s.name as subject_name", "u.name as teacher_name",
+		"c.class_name", "c.grade", "COALESCE(g.name, '')", "c.department_id", "COALESCE(d.name, '')",
+		"s.name as subject_name", "u.name as teacher_name",
 	).
 		From("timetables t").
 		Join("classes c ON t.class_id = c.id").
+		LeftJoin("class_groups g ON t.group_id = g.id").
+		LeftJoin("departments d ON c.department_id = d.id").
 		Join("subjects s ON t.subject_id = s.id").
 		Join("users u ON t.teacher_id = u.id").
 		PlaceholderFormat(squirrel.Question)
+}
+
+func scanTimetable(scanner interface{ Scan(...interface{}) error }) (*models.Timetable, error) {
+	var t models.Timetable
+	var groupID, departmentID sql.NullInt64
+	err := scanner.Scan(
+		&t.ID, &t.ClassID, &groupID, &t.SubjectID, &t.TeacherID,
+		&t.DayOfWeek, &t.Period, &t.Room, &t.CreatedAt,
+		&t.ClassName, &t.Grade, &t.GroupName, &departmentID, &t.DepartmentName,
+		&t.SubjectName, &t.TeacherName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.GroupID = nullIntPtr(groupID)
+	t.DepartmentID = nullIntPtr(departmentID)
+	return &t, nil
+}
+
+// loadTimetableCoTeachers - 共同担当教員のIDを読み込む
+func loadTimetableCoTeachers(q queryer, timetables []models.Timetable) error {
+	if len(timetables) == 0 {
+		return nil
+	}
+
+	snapshots := make([]models.TimetableSnapshot, len(timetables))
+	for i, t := range timetables {
+		snapshots[i].ID = t.ID
+	}
+	if err := loadCoTeachers(q, snapshots); err != nil {
+		return err
+	}
+	for i := range timetables {
+		timetables[i].CoTeacherIDs = snapshots[i].CoTeacherIDs
+	}
+	return nil
+}
+
+func (s *TimetableService) GetTimetables(filter models.TimetableFilter) ([]models.Timetable, error) {
+	query := timetableSelect()
 
 	// フィルター適用（修正: class_id を使用）
+	if filter.ID != nil {
+		query = query.Where(squirrel.Eq{"t.id": *filter.ID})
+	}
 	if filter.Grade != nil {
 		query = query.Where(squirrel.Eq{"c.grade": *filter.Grade})
 	}
 	if filter.ClassID != nil {
 		query = query.Where(squirrel.Eq{"t.class_id": *filter.ClassID})
 	}
+	if filter.GroupID != nil {
+		query = query.Where(squirrel.Eq{"t.group_id": *filter.GroupID})
+	}
 	if filter.ClassName != nil {
 		query = query.Where(squirrel.Eq{"c.class_name": *filter.ClassName})
 	}
@@ -45,8 +109,11 @@ func (s *TimetableService) GetTimetables(filter models.TimetableFilter) ([]model
 	if filter.TeacherID != nil {
 		query = query.Where(squirrel.Eq{"t.teacher_id": *filter.TeacherID})
 	}
+	if filter.DepartmentID != nil {
+		query = query.Where(squirrel.Eq{"c.department_id": *filter.DepartmentID})
+	}
 
-	query = query.OrderBy("c.grade", "c.class_name", "t.day_of_week", "t.period")
+	query = query.OrderBy("c.grade", "c.class_name", "t.day_of_week", "t.period", "t.group_key")
 
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
@@ -61,16 +128,16 @@ func (s *TimetableService) GetTimetables(filter models.TimetableFilter) ([]model
 
 	var timetables []models.Timetable
 	for rows.Next() {
-		var t models.Timetable
-		err := rows.Scan(
-			&t.ID, &t.ClassID, &t.SubjectID, &t.TeacherID,
-			&t.DayOfWeek, &t.Period, &t.Room, &t.CreatedAt, 
-			&t.ClassName, &t.Grade, &t.SubjectName, &t.TeacherName,
-		)
+		t, err := scanTimetable(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
-		timetables = append(timetables, t)
+		timetables = append(timetables, *t)
+	}
+	rows.Close()
+
+	if err := loadTimetableCoTeachers(s.db, timetables); err != nil {
+		return nil, err
 	}
 
 	return timetables, nil
@@ -83,20 +150,295 @@ func (s *TimetableService) GetWeeklyTimetable(classID int) (models.WeeklyTimetab
 		return nil, err
 	}
 
+	return newWeeklyTimetable(timetables), nil
+}
+
+// newWeeklyTimetable - 授業を曜日・時限ごとに並べる（授業のない平日も空で含める）
+func newWeeklyTimetable(timetables []models.Timetable) models.WeeklyTimetable {
 	weekly := make(models.WeeklyTimetable)
 	days := []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
 	
 	for _, day := range days {
-		weekly[day] = make(map[int]*models.Timetable)
+		weekly[day] = make(map[int][]*models.Timetable)
 	}
 
 	for _, t := range timetables {
 		if weekly[t.DayOfWeek] == nil {
-			weekly[t.DayOfWeek] = make(map[int]*models.Timetable)
+			weekly[t.DayOfWeek] = make(map[int][]*models.Timetable)
 		}
 		tCopy := t
-		weekly[t.DayOfWeek][t.Period] = &tCopy
+		weekly[t.DayOfWeek][t.Period] = append(weekly[t.DayOfWeek][t.Period], &tCopy)
 	}
 
-	return weekly, nil
-}
\ No newline at end of file
+	return weekly
+}
+
+// GetTeacherTimetable - 教員の date を含む週の時間割（担当・共同担当するすべてのクラスの授業）
+// その週の授業には日付指定の変更を反映し、代講する授業と、代講に任せた・休講にした自分の授業を含める
+func (s *TimetableService) GetTeacherTimetable(teacherID int, date time.Time) (*models.TeacherTimetable, error) {
+	var name string
+	err := s.db.QueryRow("SELECT name FROM users WHERE id = ? AND role IN ('teacher', 'admin')", teacherID).Scan(&name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTeacherNotFound
+		}
+		return nil, err
+	}
+
+	all, err := s.GetTimetables(models.TimetableFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var timetables []models.Timetable
+	taught := make(map[int]bool)
+	for _, t := range all {
+		if teachesLesson(&t, teacherID) {
+			timetables = append(timetables, t)
+			taught[t.ID] = true
+		}
+	}
+
+	weekStart := weekStartOf(date)
+	weekEnd := weekStart.AddDate(0, 0, 4)
+	lessons, err := s.GetEffectiveTimetable(models.EffectiveTimetableFilter{From: weekStart, To: weekEnd})
+	if err != nil {
+		return nil, err
+	}
+
+	days := weekLessonGrid(weekStart, weekEnd, lessons, func(lesson *models.EffectiveLesson) bool {
+		return taught[lesson.ID] || teachesLesson(&lesson.Timetable, teacherID)
+	})
+
+	return &models.TeacherTimetable{
+		TeacherID:   teacherID,
+		TeacherName: name,
+		WeekStart:   weekStart.Format(dateLayout),
+		WeekEnd:     weekEnd.Format(dateLayout),
+		Timetable:   newWeeklyTimetable(timetables),
+		Days:        days,
+	}, nil
+}
+
+// weekLessonGrid - その週の授業のうち include に当たるものを曜日・時限ごとに並べる（期間内の曜日は空でも含める）
+func weekLessonGrid(weekStart, weekEnd time.Time, lessons []models.EffectiveLesson, include func(*models.EffectiveLesson) bool) map[string]map[int][]models.EffectiveLesson {
+	days := make(map[string]map[int][]models.EffectiveLesson)
+	for d := weekStart; !d.After(weekEnd); d = d.AddDate(0, 0, 1) {
+		days[weekdayName(d)] = make(map[int][]models.EffectiveLesson)
+	}
+	for i := range lessons {
+		lesson := &lessons[i]
+		if !include(lesson) {
+			continue
+		}
+		day := lesson.DayOfWeek
+		if days[day] == nil {
+			days[day] = make(map[int][]models.EffectiveLesson)
+		}
+		days[day][lesson.Period] = append(days[day][lesson.Period], *lesson)
+	}
+	return days
+}
+
+// GetTimetableByID - 時間割（1コマ）取得
+func (s *TimetableService) GetTimetableByID(id int) (*models.Timetable, error) {
+	filter := models.TimetableFilter{ID: &id}
+	timetables, err := s.GetTimetables(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(timetables) == 0 {
+		return nil, fmt.Errorf("timetable not found")
+	}
+	return &timetables[0], nil
+}
+
+// GetStudentTimetable - ログイン中の学生の今週の時間割（承認済みの変更を反映）
+func (s *TimetableService) GetStudentTimetable(studentID int, now time.Time) (*models.StudentTimetable, error) {
+	enrollment, err := s.enrollmentService.GetStudentEnrollment(studentID, models.AcademicYearOf(now))
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		return nil, ErrNotEnrolled
+	}
+
+	weekly, err := s.GetWeeklyTimetable(enrollment.ClassID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.markChangedLessons(enrollment.ClassID, weekly, weekStartOf(now))
+	if err != nil {
+		return nil, err
+	}
+
+	// 所属していないグループの授業を除く
+	groupIDs, err := s.classGroupService.GetStudentGroupIDs(studentID, enrollment.ClassID)
+	if err != nil {
+		return nil, err
+	}
+	filterGroupLessons(weekly, groupIDs)
+
+	// 今週の日付指定の変更を反映した授業
+	weekStart := weekStartOf(now)
+	classID := enrollment.ClassID
+	lessons, err := s.GetEffectiveTimetable(models.EffectiveTimetableFilter{
+		From:    weekStart,
+		To:      weekStart.AddDate(0, 0, 4),
+		ClassID: &classID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	kept := lessons[:0]
+	for _, lesson := range lessons {
+		if lesson.GroupID == nil || groupIDs[*lesson.GroupID] {
+			kept = append(kept, lesson)
+		}
+	}
+
+	return &models.StudentTimetable{
+		Enrollment: *enrollment,
+		WeekStart:  weekStart.Format("2006-01-02"),
+		WeekEnd:    weekStart.AddDate(0, 0, 4).Format("2006-01-02"),
+		Timetable:  weekly,
+		Lessons:    kept,
+		Changes:    changes,
+	}, nil
+}
+
+// GetEffectiveTimetable - 期間内の実際の時間割（週間時間割に日付指定の変更を反映）
+func (s *TimetableService) GetEffectiveTimetable(filter models.EffectiveTimetableFilter) ([]models.EffectiveLesson, error) {
+	lessons, err := loadEffectiveLessons(s.db, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.EffectiveLesson{}
+	for _, lesson := range lessons {
+		if filter.ClassID != nil && lesson.ClassID != *filter.ClassID {
+			continue
+		}
+		if filter.TeacherID != nil && !teachesLesson(&lesson.Timetable, *filter.TeacherID) {
+			continue
+		}
+		if filter.Room != nil && lesson.Room != *filter.Room {
+			continue
+		}
+		result = append(result, lesson)
+	}
+
+	return result, nil
+}
+
+// teachesLesson - 教員が担当（共同担当を含む）する授業か
+func teachesLesson(t *models.Timetable, teacherID int) bool {
+	if t.TeacherID == teacherID {
+		return true
+	}
+	for _, id := range t.CoTeacherIDs {
+		if id == teacherID {
+			return true
+		}
+	}
+	return false
+}
+
+// markChangedLessons - クラスに関係する反映済み申請のうち weekStart の週に影響するものを取得し、申請で変更されたコマに印を付ける
+// 週間時間割の変更は実施日（なければ反映日）がその週のもの、日付指定の変更は変更前後の日付がその週のものを対象にする
+// 時間割は承認時に更新済みのため、ここでは表示用の印付けだけを行う
+func (s *TimetableService) markChangedLessons(classID int, weekly models.WeeklyTimetable, weekStart time.Time) ([]models.ChangeRequest, error) {
+	from := weekStart.Format(dateLayout)
+	to := weekStart.AddDate(0, 0, 6).Format(dateLayout)
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT cr.id, cr.requester_id, cr.title, cr.description, cr.status, cr.request_data, cr.created_at, cr.updated_at,
+		       a.timetable_id
+		FROM change_requests cr
+		JOIN change_request_applications a ON a.change_request_id = cr.id
+		LEFT JOIN timetable_overrides o ON a.override_id = o.id
+		LEFT JOIN timetables t ON o.timetable_id = t.id
+		WHERE cr.status = ?
+		  AND (
+		        (a.override_id IS NULL
+		         AND (JSON_VALUE(a.before_data, '$.class_id') = ? OR JSON_VALUE(a.after_data, '$.class_id') = ?)
+		         AND COALESCE(cr.effective_date, DATE(a.applied_at)) BETWEEN ? AND ?)
+		     OR (t.class_id = ?
+		         AND (o.date BETWEEN ? AND ? OR o.new_date BETWEEN ? AND ?))
+		      )
+		ORDER BY cr.updated_at
+	`, models.StatusApplied, classID, classID, from, to, classID, from, to, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	changes := []models.ChangeRequest{}
+	changedIDs := make(map[int]bool)
+	seen := make(map[int]bool)
+	for rows.Next() {
+		var cr models.ChangeRequest
+		var requestData string
+		var timetableID sql.NullInt64
+		err := rows.Scan(&cr.ID, &cr.RequesterID, &cr.Title, &cr.Description, &cr.Status, &requestData, &cr.CreatedAt, &cr.UpdatedAt, &timetableID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if timetableID.Valid {
+			changedIDs[int(timetableID.Int64)] = true
+		}
+		if seen[cr.ID] {
+			continue
+		}
+		seen[cr.ID] = true
+		cr.RequestData = json.RawMessage(requestData)
+		changes = append(changes, cr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, periods := range weekly {
+		for _, lessons := range periods {
+			for _, t := range lessons {
+				if changedIDs[t.ID] {
+					t.IsChanged = true
+				}
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// filterGroupLessons - クラス全体の授業と所属グループの授業だけを残す
+func filterGroupLessons(weekly models.WeeklyTimetable, groupIDs map[int]bool) {
+	for _, periods := range weekly {
+		for period, lessons := range periods {
+			kept := lessons[:0]
+			for _, t := range lessons {
+				if t.GroupID == nil || groupIDs[*t.GroupID] {
+					kept = append(kept, t)
+				}
+			}
+			if len(kept) == 0 {
+				delete(periods, period)
+				continue
+			}
+			periods[period] = kept
+		}
+	}
+}
+
+// weekStartOf - 表示対象の週の月曜日（土日は翌週）
+func weekStartOf(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch day.Weekday() {
+	case time.Saturday:
+		return day.AddDate(0, 0, 2)
+	case time.Sunday:
+		return day.AddDate(0, 0, 1)
+	default:
+		return day.AddDate(0, 0, -int(day.Weekday()-time.Monday))
+	}
+}
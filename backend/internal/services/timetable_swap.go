@@ -0,0 +1,215 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"kosen-schedule-system/internal/models"
+)
+
+// swapSnapshots - 週間時間割の2コマの日時を入れ替えた前後の内容
+func swapSnapshots(q queryer, data *models.TimetableChangeData) ([]models.TimetableSnapshot, []models.TimetableSnapshot, error) {
+	if data.SwapTimetableID == 0 || data.SwapTimetableID == data.OriginalTimetableID {
+		return nil, nil, fmt.Errorf("入れ替え相手の授業を指定してください")
+	}
+
+	first, err := loadTimetableSnapshot(q, data.OriginalTimetableID)
+	if err != nil {
+		return nil, nil, err
+	}
+	second, err := loadTimetableSnapshot(q, data.SwapTimetableID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("入れ替え相手の授業が見つかりません")
+	}
+	if first.DayOfWeek == second.DayOfWeek && first.Period == second.Period {
+		return nil, nil, fmt.Errorf("同じコマの授業は入れ替えられません")
+	}
+
+	firstAfter, secondAfter := *first, *second
+	firstAfter.DayOfWeek, firstAfter.Period = second.DayOfWeek, second.Period
+	secondAfter.DayOfWeek, secondAfter.Period = first.DayOfWeek, first.Period
+
+	before := []models.TimetableSnapshot{*first, *second}
+	after := []models.TimetableSnapshot{firstAfter, secondAfter}
+	return before, after, nil
+}
+
+// validateSwapLessons - 入れ替えを申請する授業が申請者の担当授業（共同担当を含む）か確認
+func validateSwapLessons(q queryer, requesterID int, data *models.TimetableChangeData) error {
+	original, err := loadTimetableSnapshot(q, data.OriginalTimetableID)
+	if err != nil {
+		return err
+	}
+	if !containsInt(lessonTeachers(original), requesterID) {
+		return fmt.Errorf("担当していない授業の入れ替えは申請できません")
+	}
+	return nil
+}
+
+// swapOverrides - 日付指定の入れ替え（それぞれの授業を相手の日付・時限へ移動する2件の変更）
+func swapOverrides(q queryer, data *models.TimetableChangeData) ([]*models.TimetableOverride, error) {
+	if data.SwapTimetableID == 0 || data.SwapTimetableID == data.OriginalTimetableID {
+		return nil, fmt.Errorf("入れ替え相手の授業を指定してください")
+	}
+	if data.SwapDate == "" {
+		return nil, fmt.Errorf("入れ替え相手の授業の日付を指定してください")
+	}
+
+	first, err := loadTimetableSnapshot(q, data.OriginalTimetableID)
+	if err != nil {
+		return nil, err
+	}
+	second, err := loadTimetableSnapshot(q, data.SwapTimetableID)
+	if err != nil {
+		return nil, fmt.Errorf("入れ替え相手の授業が見つかりません")
+	}
+	if data.Date == data.SwapDate && first.Period == second.Period {
+		return nil, fmt.Errorf("同じコマの授業は入れ替えられません")
+	}
+
+	firstPeriod, secondPeriod := first.Period, second.Period
+	return []*models.TimetableOverride{
+		{
+			TimetableID: first.ID,
+			Type:        models.OverrideMove,
+			Date:        data.Date,
+			NewDate:     data.SwapDate,
+			NewPeriod:   &secondPeriod,
+			Note:        data.Reason,
+		},
+		{
+			TimetableID: second.ID,
+			Type:        models.OverrideMove,
+			Date:        data.SwapDate,
+			NewDate:     data.Date,
+			NewPeriod:   &firstPeriod,
+			Note:        data.Reason,
+		},
+	}, nil
+}
+
+// checkSwap - 入れ替えた場合の競合を確認（入れ替える授業同士は比較しない）
+func (s *ConflictService) checkSwap(q queryer, data *models.TimetableChangeData) ([]models.Conflict, error) {
+	if data.Date != "" {
+		overrides, err := swapOverrides(q, data)
+		if err != nil {
+			return nil, err
+		}
+		return s.CheckOverrides(q, overrides)
+	}
+
+	_, after, err := swapSnapshots(q, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.CheckLessons(q, after, nil)
+}
+
+// applySwap - 入れ替え申請を反映する（2コマとも反映するか、どちらも反映しない）
+func (s *ChangeRequestService) applySwap(tx *sql.Tx, requestID int, data *models.TimetableChangeData) ([]models.ChangeRequestApplication, error) {
+	if data.Date != "" {
+		overrides, err := swapOverrides(tx, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range overrides {
+			o.ChangeRequestID = &requestID
+		}
+		if err := applyOverrides(tx, s.conflictService, overrides); err != nil {
+			return nil, err
+		}
+		return recordOverrideApplications(tx, requestID, overrides)
+	}
+
+	before, after, err := swapSnapshots(tx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := s.conflictService.CheckLessons(tx, after, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	// 同じクラス・グループの授業同士は一意制約に掛かるため、一方を空いているコマへ退避してから入れ替える
+	if sameClassGroup(&before[0], &before[1]) {
+		if err := parkTimetable(tx, &before[0]); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(after) - 1; i >= 0; i-- {
+		if err := updateTimetable(tx, &after[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	applications := []models.ChangeRequestApplication{}
+	for i := range after {
+		application, err := recordApplication(tx, requestID, &before[i], &after[i])
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, *application)
+	}
+
+	return applications, nil
+}
+
+// sameClassGroup - 同じクラスの同じグループ（またはどちらもクラス全体）の授業か
+func sameClassGroup(a, b *models.TimetableSnapshot) bool {
+	if a.ClassID != b.ClassID {
+		return false
+	}
+	if a.GroupID == nil || b.GroupID == nil {
+		return a.GroupID == nil && b.GroupID == nil
+	}
+	return *a.GroupID == *b.GroupID
+}
+
+// parkTimetable - 授業を同じクラス・グループの空いているコマへ一時的に移す
+func parkTimetable(tx *sql.Tx, t *models.TimetableSnapshot) error {
+	groupKey := 0
+	if t.GroupID != nil {
+		groupKey = *t.GroupID
+	}
+
+	rows, err := tx.Query(`
+		SELECT day_of_week, period FROM timetables
+		WHERE class_id = ? AND group_key = ?
+	`, t.ClassID, groupKey)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %v", err)
+	}
+	occupied := make(map[string]bool)
+	for rows.Next() {
+		var day string
+		var period int
+		if err := rows.Scan(&day, &period); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		occupied[fmt.Sprintf("%s-%d", day, period)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, day := range []string{"monday", "tuesday", "wednesday", "thursday", "friday"} {
+		for period := models.Period1; period <= models.Period4; period++ {
+			if occupied[fmt.Sprintf("%s-%d", day, period)] {
+				continue
+			}
+			_, err := tx.Exec("UPDATE timetables SET day_of_week = ?, period = ? WHERE id = ?", day, period, t.ID)
+			if err != nil {
+				return fmt.Errorf("時間割の更新に失敗しました: %v", err)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("クラスの時間割に空きがないため入れ替えられません")
+}
@@ -0,0 +1,655 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+
+	"github.com/Masterminds/squirrel"
+)
+
+const (
+	maxWebhookAttempts  = 6                // これだけ失敗したら再送をやめて dead にする
+	webhookRetryBase    = 30 * time.Second // 1回目の失敗後の待ち時間（失敗するごとに倍にする）
+	webhookRetryMax     = time.Hour        // 再送までの待ち時間の上限
+	webhookClaimTimeout = 2 * time.Minute  // 送信中の記録を他の処理が拾わないようにする時間
+	webhookBatchSize    = 100              // 1回の確認で送信する件数
+	webhookTimeout      = 10 * time.Second // 1回の送信の待ち時間
+)
+
+// Webhook の出来事として指定できるもの（"*" と "request.*"・"timetable.*" も指定できる）
+var webhookEvents = []string{
+	models.WebhookEventRequestPrefix + models.EventCreated,
+	models.WebhookEventRequestPrefix + models.EventStatus,
+	models.WebhookEventRequestPrefix + models.EventEdit,
+	models.WebhookEventRequestPrefix + models.EventComment,
+	models.WebhookEventRequestPrefix + models.EventConsent,
+	models.WebhookEventRequestPrefix + models.EventApproval,
+	models.WebhookEventRequestPrefix + models.EventDeadline,
+	models.WebhookEventTimetableChanged,
+	models.WebhookEventTimetableReverted,
+}
+
+type WebhookService struct {
+	db *sql.DB
+}
+
+func NewWebhookService(db *sql.DB) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+func webhookSelect() squirrel.SelectBuilder {
+	return squirrel.Select("id", "name", "url", "events", "is_active", "created_by", "created_at", "updated_at").
+		From("webhooks").
+		PlaceholderFormat(squirrel.Question)
+}
+
+func scanWebhook(scanner interface{ Scan(...interface{}) error }) (*models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	var createdBy sql.NullInt64
+	if err := scanner.Scan(&w.ID, &w.Name, &w.URL, &events, &w.IsActive, &createdBy, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = splitWebhookEvents(events)
+	w.CreatedBy = nullIntPtr(createdBy)
+	return &w, nil
+}
+
+// GetWebhooks - Webhook 一覧（署名の鍵は含めない）
+func (s *WebhookService) GetWebhooks() ([]models.Webhook, error) {
+	sqlQuery, args, err := webhookSelect().OrderBy("id").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhookByID - Webhook 取得（見つからなければ sql.ErrNoRows）
+func (s *WebhookService) GetWebhookByID(id int) (*models.Webhook, error) {
+	sqlQuery, args, err := webhookSelect().Where(squirrel.Eq{"id": id}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	return scanWebhook(s.db.QueryRow(sqlQuery, args...))
+}
+
+// CreateWebhook - Webhook を登録する（署名の鍵を発行して返す）
+func (s *WebhookService) CreateWebhook(userID int, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	events := req.Events
+	if len(events) == 0 {
+		events = []string{"*"}
+	}
+	if err := validateWebhook(req.Name, req.URL, events); err != nil {
+		return nil, err
+	}
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	secret, err := newRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO webhooks (name, url, secret, events, is_active, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.Name, req.URL, secret, strings.Join(events, ","), isActive, userID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.GetWebhookByID(int(id))
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = secret
+	return webhook, nil
+}
+
+// UpdateWebhook - Webhook を変更する（rotate_secret の場合は署名の鍵を再発行して返す）
+func (s *WebhookService) UpdateWebhook(id int, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	current, err := s.GetWebhookByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, webhookURL, events := current.Name, current.URL, current.Events
+	if req.Name != "" {
+		name = req.Name
+	}
+	if req.URL != "" {
+		webhookURL = req.URL
+	}
+	if req.Events != nil {
+		events = req.Events
+	}
+	if err := validateWebhook(name, webhookURL, events); err != nil {
+		return nil, err
+	}
+
+	query := squirrel.Update("webhooks").
+		Set("name", name).
+		Set("url", webhookURL).
+		Set("events", strings.Join(events, ",")).
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Question)
+	if req.IsActive != nil {
+		query = query.Set("is_active", *req.IsActive)
+	}
+	var secret string
+	if req.RotateSecret {
+		secret, err = newRandomToken()
+		if err != nil {
+			return nil, err
+		}
+		query = query.Set("secret", secret)
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	if _, err := s.db.Exec(sqlQuery, args...); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.GetWebhookByID(id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = secret
+	return webhook, nil
+}
+
+// DeleteWebhook - Webhook を削除する（送信記録も削除される）
+func (s *WebhookService) DeleteWebhook(id int) error {
+	result, err := s.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TestWebhook - 設定確認用の ping を送信待ちに登録する（出来事の絞り込み・無効の設定に関わらず送る）
+func (s *WebhookService) TestWebhook(id int) (*models.WebhookDelivery, error) {
+	if _, err := s.GetWebhookByID(id); err != nil {
+		return nil, err
+	}
+
+	deliveryID, err := insertWebhookDelivery(s.db, id, models.WebhookEventPing, map[string]interface{}{
+		"message": "Webhook の設定を確認するためのテスト送信です",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getDelivery(deliveryID)
+}
+
+// GetDeliveries - Webhook の送信記録（新しい順、status で絞り込み。dead は再送をやめた記録）と件数
+func (s *WebhookService) GetDeliveries(webhookID int, status string, limit, offset int) ([]models.WebhookDelivery, int, error) {
+	where := squirrel.Eq{"webhook_id": webhookID}
+	if status != "" {
+		where["status"] = status
+	}
+
+	countQuery, countArgs, err := squirrel.Select("COUNT(*)").
+		From("webhook_deliveries").
+		Where(where).
+		PlaceholderFormat(squirrel.Question).
+		ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build query: %v", err)
+	}
+	var total int
+	if err := s.db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sqlQuery, args, err := deliverySelect().
+		Where(where).
+		OrderBy("id DESC").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)).
+		ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build query: %v", err)
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// RetryDelivery - 再送をやめた（dead）送信記録を、すぐに送り直すよう送信待ちに戻す
+func (s *WebhookService) RetryDelivery(id int) (*models.WebhookDelivery, error) {
+	result, err := s.db.Exec(`
+		UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND status = ?
+	`, models.WebhookDeliveryPending, time.Now(), id, models.WebhookDeliveryDead)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		d, err := s.getDelivery(id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("再送できるのは再送をやめた送信のみです（現在の状態: %s）", d.Status)
+	}
+	return s.getDelivery(id)
+}
+
+func (s *WebhookService) getDelivery(id int) (*models.WebhookDelivery, error) {
+	sqlQuery, args, err := deliverySelect().Where(squirrel.Eq{"id": id}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	return scanDelivery(s.db.QueryRow(sqlQuery, args...))
+}
+
+func deliverySelect() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"id", "webhook_id", "event_type", "payload", "status", "attempts", "next_attempt_at",
+		"response_status", "COALESCE(last_error, '')", "delivered_at", "created_at",
+	).
+		From("webhook_deliveries").
+		PlaceholderFormat(squirrel.Question)
+}
+
+func scanDelivery(scanner interface{ Scan(...interface{}) error }) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	var responseStatus sql.NullInt64
+	var deliveredAt sql.NullTime
+	err := scanner.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&responseStatus, &d.LastError, &deliveredAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	d.ResponseStatus = nullIntPtr(responseStatus)
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
+
+// validateWebhook - 名前・URL（http/https）・出来事の指定を確認する
+func validateWebhook(name, webhookURL string, events []string) error {
+	if name == "" {
+		return fmt.Errorf("名前を入力してください")
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL が不正です: %s", webhookURL)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("通知する出来事を指定してください")
+	}
+	for _, event := range events {
+		if event == "*" || event == "request.*" || event == "timetable.*" || containsString(webhookEvents, event) {
+			continue
+		}
+		return fmt.Errorf("出来事の指定が不正です: %s", event)
+	}
+	return nil
+}
+
+// newRandomToken - 推測できない 64 文字の値（Webhook の署名の鍵・配信のトークン）
+func newRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func splitWebhookEvents(events string) []string {
+	var list []string
+	for _, event := range strings.Split(events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			list = append(list, event)
+		}
+	}
+	return list
+}
+
+// webhookMatches - 出来事が Webhook の指定（"*"、"request.*" のような前方一致、完全一致）に該当するか
+func webhookMatches(patterns []string, event string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == event {
+			return true
+		}
+		if strings.HasSuffix(pattern, ".*") && strings.HasPrefix(event, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookExecer - 送信待ちの登録に使う（*sql.DB と *sql.Tx）
+type webhookExecer interface {
+	queryer
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// enqueueWebhooks - 出来事を、該当する有効な Webhook の送信待ちに登録する（呼び出し側のトランザクション内で実行）
+func enqueueWebhooks(tx webhookExecer, event string, data interface{}) error {
+	rows, err := tx.Query("SELECT id, events FROM webhooks WHERE is_active = TRUE")
+	if err != nil {
+		return err
+	}
+	var webhookIDs []int
+	for rows.Next() {
+		var id int
+		var events string
+		if err := rows.Scan(&id, &events); err != nil {
+			rows.Close()
+			return err
+		}
+		if webhookMatches(splitWebhookEvents(events), event) {
+			webhookIDs = append(webhookIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range webhookIDs {
+		if _, err := insertWebhookDelivery(tx, id, event, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertWebhookDelivery(ex webhookExecer, webhookID int, event string, data interface{}) (int, error) {
+	now := time.Now()
+	payload, err := json.Marshal(&models.WebhookPayload{Event: event, OccurredAt: now, Data: data})
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := ex.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, webhookID, event, string(payload), models.WebhookDeliveryPending, now)
+	if err != nil {
+		return 0, fmt.Errorf("Webhook の送信待ちへの登録に失敗しました: %v", err)
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// enqueueRequestWebhook - 申請の履歴に記録した出来事を Webhook で送る（申請の件名・状態を添える）
+func enqueueRequestWebhook(tx webhookExecer, event *models.ChangeRequestEvent) error {
+	var title, status string
+	var requesterID int
+	err := tx.QueryRow("SELECT title, status, requester_id FROM change_requests WHERE id = ?", event.ChangeRequestID).
+		Scan(&title, &status, &requesterID)
+	if err != nil {
+		return err
+	}
+
+	return enqueueWebhooks(tx, models.WebhookEventRequestPrefix+event.Type, map[string]interface{}{
+		"change_request": map[string]interface{}{
+			"id":           event.ChangeRequestID,
+			"title":        title,
+			"status":       status,
+			"requester_id": requesterID,
+		},
+		"event": event,
+	})
+}
+
+// enqueueTimetableWebhook - 時間割の変更を Webhook で送る
+func enqueueTimetableWebhook(tx webhookExecer, event *models.TimetableEvent) error {
+	webhookEvent := models.WebhookEventTimetableChanged
+	if event.Type == models.TimetableEventReverted {
+		webhookEvent = models.WebhookEventTimetableReverted
+	}
+	return enqueueWebhooks(tx, webhookEvent, event)
+}
+
+// WebhookDispatcher - 送信待ちの Webhook を定期的に送信する
+// 本文の HMAC-SHA256 署名を付けて POST し、2xx 以外は間隔を倍にしながら再送して、maxWebhookAttempts 回失敗したら dead にする
+type WebhookDispatcher struct {
+	db       *sql.DB
+	client   *http.Client
+	interval time.Duration
+}
+
+func NewWebhookDispatcher(db *sql.DB, interval time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		db:       db,
+		client:   &http.Client{Timeout: webhookTimeout},
+		interval: interval,
+	}
+}
+
+// Start - ctx が終了するまでバックグラウンドで定期的に送信する
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := d.RunOnce(time.Now()); err != nil {
+				log.Printf("webhook dispatcher: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce - now の時点で送信予定の Webhook を送信する（送信の失敗は記録して再送を予約し、エラーにはしない）
+func (d *WebhookDispatcher) RunOnce(now time.Time) error {
+	rows, err := d.db.Query(`
+		SELECT id FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`, models.WebhookDeliveryPending, now, webhookBatchSize)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := d.deliver(id, now); err != nil {
+			return fmt.Errorf("Webhook の送信記録 %d の処理に失敗しました: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// deliver - 1件を送信し、結果を記録する
+func (d *WebhookDispatcher) deliver(id int, now time.Time) error {
+	// 他の処理が同じ記録を送らないよう、送信中は次の送信予定を先へずらしておく
+	result, err := d.db.Exec(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at <= ?
+	`, now.Add(webhookClaimTimeout), id, models.WebhookDeliveryPending, now)
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+
+	var webhookURL, secret, event, payload string
+	var attempts int
+	err = d.db.QueryRow(`
+		SELECT w.url, w.secret, d.event_type, d.payload, d.attempts
+		FROM webhook_deliveries d
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.id = ?
+	`, id).Scan(&webhookURL, &secret, &event, &payload, &attempts)
+	if err != nil {
+		return err
+	}
+
+	responseStatus, err := d.post(webhookURL, secret, id, event, []byte(payload))
+	attempts++
+
+	var statusCode interface{}
+	if responseStatus != 0 {
+		statusCode = responseStatus
+	}
+	if err == nil {
+		_, err := d.db.Exec(`
+			UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, delivered_at = ?, last_error = NULL
+			WHERE id = ?
+		`, models.WebhookDeliveryDelivered, attempts, statusCode, time.Now(), id)
+		return err
+	}
+
+	status := webhookFailureStatus(attempts)
+	if status == models.WebhookDeliveryDead {
+		log.Printf("webhook dispatcher: delivery %d (%s) gave up after %d attempts: %v", id, event, attempts, err)
+	}
+	_, updateErr := d.db.Exec(`
+		UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, next_attempt_at = ?, last_error = ?
+		WHERE id = ?
+	`, status, attempts, statusCode, now.Add(webhookRetryDelay(attempts)), err.Error(), id)
+	return updateErr
+}
+
+// post - 署名を付けて送信し、レスポンスのステータスコードを返す（2xx 以外はエラー）
+// 署名は "タイムスタンプ.本文" の HMAC-SHA256 を X-Webhook-Signature に "sha256=16進" で付ける
+func (d *WebhookDispatcher) post(webhookURL, secret string, deliveryID int, event string, payload []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kosen-schedule-system-webhook")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(deliveryID))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(secret, timestamp, payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("HTTP %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// SignWebhook - Webhook の署名（受信側での検証にも使う）
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookFailureStatus - attempts 回目の送信に失敗した後の状態（maxWebhookAttempts 回失敗したら dead）
+func webhookFailureStatus(attempts int) string {
+	if attempts >= maxWebhookAttempts {
+		return models.WebhookDeliveryDead
+	}
+	return models.WebhookDeliveryPending
+}
+
+// webhookRetryDelay - attempts 回失敗した後、次に送信するまでの待ち時間
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kosen-schedule-system/internal/models"
+)
+
+func TestSignWebhook(t *testing.T) {
+	payload := []byte(`{"change_request_id":1}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(payload)))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhook("secret", "1700000000", payload); got != want {
+		t.Fatalf("SignWebhook() = %s, want %s", got, want)
+	}
+	if got := SignWebhook("other", "1700000000", payload); got == want {
+		t.Fatal("SignWebhook() with a different secret should not match")
+	}
+	if got := SignWebhook("secret", "1700000001", payload); got == want {
+		t.Fatal("SignWebhook() with a different timestamp should not match")
+	}
+}
+
+func TestWebhookDispatcherPost(t *testing.T) {
+	payload := []byte(`{"event":"request.created"}`)
+
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := NewWebhookDispatcher(nil, time.Minute)
+	status, err := d.post(receiver.URL, "secret", 42, "request.created", payload)
+	if err != nil {
+		t.Fatalf("post() error = %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Fatalf("post() status = %d, want %d", status, http.StatusNoContent)
+	}
+
+	if string(body) != string(payload) {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	if event := got.Header.Get("X-Webhook-Event"); event != "request.created" {
+		t.Errorf("X-Webhook-Event = %q", event)
+	}
+	if delivery := got.Header.Get("X-Webhook-Delivery"); delivery != "42" {
+		t.Errorf("X-Webhook-Delivery = %q", delivery)
+	}
+
+	// 受信側の検証と同じ手順で署名を確かめる
+	signature := strings.TrimPrefix(got.Header.Get("X-Webhook-Signature"), "sha256=")
+	expected := SignWebhook("secret", got.Header.Get("X-Webhook-Timestamp"), body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		t.Errorf("X-Webhook-Signature = %q, want sha256=%s", got.Header.Get("X-Webhook-Signature"), expected)
+	}
+}
+
+func TestWebhookDispatcherPostFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	d := NewWebhookDispatcher(nil, time.Minute)
+	status, err := d.post(receiver.URL, "secret", 1, "timetable.changed", []byte(`{}`))
+	if err == nil {
+		t.Fatal("post() should fail for a non-2xx response")
+	}
+	if status != http.StatusInternalServerError {
+		t.Fatalf("post() status = %d, want %d", status, http.StatusInternalServerError)
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		event    string
+		want     bool
+	}{
+		{[]string{"*"}, "request.created", true},
+		{[]string{"*"}, "timetable.changed", true},
+		{[]string{"request.*"}, "request.created", true},
+		{[]string{"request.*"}, "request.status", true},
+		{[]string{"request.*"}, "timetable.changed", false},
+		{[]string{"request.*"}, "requestx.created", false},
+		{[]string{"timetable.*"}, "timetable.reverted", true},
+		{[]string{"request.created"}, "request.created", true},
+		{[]string{"request.created"}, "request.status", false},
+		{[]string{"request"}, "request.created", false},
+		{[]string{"request.status", "timetable.*"}, "timetable.changed", true},
+		{nil, "request.created", false},
+	}
+
+	for _, tt := range tests {
+		if got := webhookMatches(tt.patterns, tt.event); got != tt.want {
+			t.Errorf("webhookMatches(%v, %q) = %v, want %v", tt.patterns, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookFailureStatus(t *testing.T) {
+	for attempts := 1; attempts < maxWebhookAttempts; attempts++ {
+		if got := webhookFailureStatus(attempts); got != models.WebhookDeliveryPending {
+			t.Errorf("webhookFailureStatus(%d) = %s, want %s", attempts, got, models.WebhookDeliveryPending)
+		}
+	}
+	for _, attempts := range []int{maxWebhookAttempts, maxWebhookAttempts + 1} {
+		if got := webhookFailureStatus(attempts); got != models.WebhookDeliveryDead {
+			t.Errorf("webhookFailureStatus(%d) = %s, want %s", attempts, got, models.WebhookDeliveryDead)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+-- 権限管理テーブル
+
+-- ロールごとの権限
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL,
+    permission VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (role, permission)
+);
+
+-- ユーザーへの追加ロール割り当て（学年・学科で範囲を限定できる）
+CREATE TABLE IF NOT EXISTS user_role_assignments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    grade INT NULL CHECK (grade BETWEEN 1 AND 5),
+    department_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_id (user_id),
+    INDEX idx_role (role)
+);
+
+-- 初期権限
+INSERT IGNORE INTO role_permissions (role, permission) VALUES
+('admin', 'request.create'),
+('admin', 'request.approve'),
+('admin', 'timetable.edit'),
+('admin', 'master.edit'),
+('admin', 'csv.import'),
+('admin', 'csv.export'),
+('admin', 'user.manage'),
+('teacher', 'request.create'),
+('department_head', 'request.create'),
+('department_head', 'request.approve'),
+('academic_affairs', 'timetable.edit'),
+('academic_affairs', 'master.edit'),
+('academic_affairs', 'csv.import'),
+('academic_affairs', 'csv.export');
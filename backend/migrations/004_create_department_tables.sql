@@ -0,0 +1,53 @@
+-- 学科テーブル
+CREATE TABLE IF NOT EXISTS departments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(10) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    short_name VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- クラスの所属学科（1年生は混合学級のため NULL）
+ALTER TABLE classes ADD COLUMN IF NOT EXISTS department_id INT NULL;
+ALTER TABLE classes ADD CONSTRAINT fk_classes_department
+    FOREIGN KEY (department_id) REFERENCES departments(id) ON DELETE SET NULL;
+
+-- 学科所属教員
+CREATE TABLE IF NOT EXISTS department_teachers (
+    department_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (department_id, user_id),
+    FOREIGN KEY (department_id) REFERENCES departments(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 学科開講科目
+CREATE TABLE IF NOT EXISTS department_subjects (
+    department_id INT NOT NULL,
+    subject_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (department_id, subject_id),
+    FOREIGN KEY (department_id) REFERENCES departments(id) ON DELETE CASCADE,
+    FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE
+);
+
+-- 学科単位の権限割り当て
+ALTER TABLE user_role_assignments ADD CONSTRAINT fk_user_role_assignments_department
+    FOREIGN KEY (department_id) REFERENCES departments(id) ON DELETE CASCADE;
+
+-- 初期データ
+INSERT INTO departments (code, name, short_name) VALUES
+('M', '機械工学科', '機械'),
+('E', '電気電子工学科', '電気'),
+('S', '電子制御工学科', '制御'),
+('C', '物質工学科', '物質'),
+('J', '情報工学科', '情報')
+ON DUPLICATE KEY UPDATE name = VALUES(name);
+
+-- 2年生以上のクラスを学科に紐付け（クラス番号順）
+UPDATE classes c
+JOIN departments d ON d.code = ELT(CAST(c.class_name AS UNSIGNED), 'M', 'E', 'S', 'C', 'J')
+SET c.department_id = d.id
+WHERE c.grade >= 2 AND c.department_id IS NULL;
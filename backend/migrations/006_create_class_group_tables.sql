@@ -0,0 +1,30 @@
+-- クラス内のグループ（選択科目・分割授業）
+CREATE TABLE IF NOT EXISTS class_groups (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    class_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_class_group (class_id, name)
+);
+
+-- グループ所属学生
+CREATE TABLE IF NOT EXISTS class_group_members (
+    group_id INT NOT NULL,
+    student_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, student_id),
+    FOREIGN KEY (group_id) REFERENCES class_groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 時間割のグループ（NULL はクラス全体の授業）
+-- 同じコマにグループごとの授業を並行して登録できるよう一意制約を張り替える
+ALTER TABLE timetables ADD COLUMN IF NOT EXISTS group_id INT NULL AFTER class_id;
+ALTER TABLE timetables ADD CONSTRAINT fk_timetables_group
+    FOREIGN KEY (group_id) REFERENCES class_groups(id) ON DELETE CASCADE;
+ALTER TABLE timetables ADD COLUMN IF NOT EXISTS group_key INT AS (COALESCE(group_id, 0)) STORED;
+ALTER TABLE timetables
+    ADD UNIQUE KEY unique_class_group_time (class_id, day_of_week, period, group_key),
+    DROP INDEX unique_class_time;
@@ -0,0 +1,12 @@
+-- 承認時に時間割へ反映した変更の記録（反映前後の内容）
+CREATE TABLE IF NOT EXISTS change_request_applications (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    change_request_id INT NOT NULL,
+    timetable_id INT NULL,
+    before_data JSON NULL,
+    after_data JSON NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (change_request_id) REFERENCES change_requests(id) ON DELETE CASCADE,
+    FOREIGN KEY (timetable_id) REFERENCES timetables(id) ON DELETE SET NULL,
+    INDEX idx_timetable (timetable_id)
+);
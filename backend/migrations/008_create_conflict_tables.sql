@@ -0,0 +1,45 @@
+-- 担当者CSVの科目・クラスごとの実施場所と担当教員
+CREATE TABLE IF NOT EXISTS subject_assignments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    subject_id INT NOT NULL,
+    class_id INT NOT NULL,
+    room VARCHAR(50) NOT NULL DEFAULT '',
+    work_type VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (subject_id) REFERENCES subjects(id) ON DELETE CASCADE,
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_subject_class (subject_id, class_id)
+);
+
+-- 担当教員（position 1 が主担当、2・3 は共同担当）
+CREATE TABLE IF NOT EXISTS subject_assignment_teachers (
+    assignment_id INT NOT NULL,
+    teacher_id INT NOT NULL,
+    position INT NOT NULL DEFAULT 1,
+    PRIMARY KEY (assignment_id, teacher_id),
+    FOREIGN KEY (assignment_id) REFERENCES subject_assignments(id) ON DELETE CASCADE,
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- 時間割の共同担当教員（主担当は timetables.teacher_id）
+CREATE TABLE IF NOT EXISTS timetable_teachers (
+    timetable_id INT NOT NULL,
+    teacher_id INT NOT NULL,
+    PRIMARY KEY (timetable_id, teacher_id),
+    FOREIGN KEY (timetable_id) REFERENCES timetables(id) ON DELETE CASCADE,
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_teacher (teacher_id)
+);
+
+-- 教員の都合の悪い時間（period が NULL の場合は終日）
+CREATE TABLE IF NOT EXISTS teacher_unavailability (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    teacher_id INT NOT NULL,
+    day_of_week ENUM('monday', 'tuesday', 'wednesday', 'thursday', 'friday') NOT NULL,
+    period INT NULL CHECK (period BETWEEN 1 AND 4),
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_teacher_day (teacher_id, day_of_week)
+);
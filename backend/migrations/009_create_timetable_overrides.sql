@@ -0,0 +1,27 @@
+-- 日付指定の授業変更（週間時間割に重ねて適用する）
+--   cancel: 休講 / move: 日付・時限の移動 / substitute: 代講 / room_change: 教室変更
+CREATE TABLE IF NOT EXISTS timetable_overrides (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    timetable_id INT NOT NULL,
+    change_request_id INT NULL,
+    override_type ENUM('cancel', 'move', 'substitute', 'room_change') NOT NULL,
+    date DATE NOT NULL,
+    new_date DATE NULL,
+    new_period INT NULL CHECK (new_period BETWEEN 1 AND 4),
+    new_teacher_id INT NULL,
+    new_room VARCHAR(50) NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (timetable_id) REFERENCES timetables(id) ON DELETE CASCADE,
+    FOREIGN KEY (change_request_id) REFERENCES change_requests(id) ON DELETE SET NULL,
+    FOREIGN KEY (new_teacher_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_date (date),
+    INDEX idx_new_date (new_date)
+);
+
+-- 申請によって作成された日付指定の変更
+ALTER TABLE change_request_applications ADD COLUMN IF NOT EXISTS override_id INT NULL AFTER timetable_id;
+ALTER TABLE change_request_applications ADD CONSTRAINT fk_applications_override
+    FOREIGN KEY (override_id) REFERENCES timetable_overrides(id) ON DELETE SET NULL;
@@ -0,0 +1,17 @@
+-- 入れ替え申請など、承認者に回る前に相手教員の同意が必要な申請
+ALTER TABLE change_requests
+    MODIFY COLUMN status ENUM('awaiting_consent', 'pending', 'approved', 'rejected', 'canceled') NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS change_request_consents (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    change_request_id INT NOT NULL,
+    teacher_id INT NOT NULL,
+    status ENUM('pending', 'accepted', 'declined') NOT NULL DEFAULT 'pending',
+    comment TEXT NULL,
+    responded_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (change_request_id) REFERENCES change_requests(id) ON DELETE CASCADE,
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_request_teacher (change_request_id, teacher_id),
+    INDEX idx_teacher_status (teacher_id, status)
+);
@@ -0,0 +1,2 @@
+-- 承認・却下時のコメント
+ALTER TABLE change_requests ADD COLUMN IF NOT EXISTS approver_comment TEXT NULL AFTER approved_at;
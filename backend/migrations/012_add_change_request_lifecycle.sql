@@ -0,0 +1,10 @@
+-- 申請の状態遷移（下書き・反映済み・取り消し済み）と楽観的ロック用の version
+ALTER TABLE change_requests
+    MODIFY COLUMN status ENUM('draft', 'awaiting_consent', 'pending', 'approved', 'applied', 'rejected', 'canceled', 'reverted') NOT NULL DEFAULT 'pending';
+ALTER TABLE change_requests ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0 AFTER status;
+
+-- これまでの承認済み申請のうち、時間割への反映の記録があるものだけを反映済みにする
+-- （承認時に時間割へ反映するようになる前の申請は記録がないため、承認済みのまま残す）
+UPDATE change_requests cr SET cr.status = 'applied'
+WHERE cr.status = 'approved'
+  AND EXISTS (SELECT 1 FROM change_request_applications a WHERE a.change_request_id = cr.id);
@@ -0,0 +1,16 @@
+-- 申請の履歴（コメント、状態の変更、申請内容の修正）
+CREATE TABLE IF NOT EXISTS change_request_events (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    change_request_id INT NOT NULL,
+    actor_id INT NULL,
+    event_type ENUM('created', 'comment', 'status', 'edit', 'consent') NOT NULL,
+    from_status VARCHAR(20) NULL,
+    to_status VARCHAR(20) NULL,
+    comment TEXT NULL,
+    before_data JSON NULL,
+    after_data JSON NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (change_request_id) REFERENCES change_requests(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_request_created (change_request_id, created_at)
+);
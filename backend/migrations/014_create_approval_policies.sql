@@ -0,0 +1,59 @@
+-- 多段階承認（申請の種類・対象クラスの範囲ごとに、承認が必要なロールの順番を設定する）
+
+-- 承認ポリシー（request_kind・grade・department_id が NULL の場合は絞り込まない）
+CREATE TABLE IF NOT EXISTS approval_policies (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    request_kind VARCHAR(20) NULL,
+    grade INT NULL CHECK (grade BETWEEN 1 AND 5),
+    department_id INT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (department_id) REFERENCES departments(id) ON DELETE CASCADE
+);
+
+-- ポリシーの承認段階
+CREATE TABLE IF NOT EXISTS approval_policy_steps (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    policy_id INT NOT NULL,
+    step_order INT NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    FOREIGN KEY (policy_id) REFERENCES approval_policies(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_policy_step (policy_id, step_order)
+);
+
+-- 申請ごとの承認段階（提出時にポリシーから作成する）
+CREATE TABLE IF NOT EXISTS change_request_approval_steps (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    change_request_id INT NOT NULL,
+    step_order INT NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    status ENUM('pending', 'approved', 'rejected') DEFAULT 'pending',
+    approver_id INT NULL,
+    comment TEXT NULL,
+    decided_at TIMESTAMP NULL,
+    FOREIGN KEY (change_request_id) REFERENCES change_requests(id) ON DELETE CASCADE,
+    FOREIGN KEY (approver_id) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE KEY unique_request_step (change_request_id, step_order)
+);
+
+ALTER TABLE change_request_events
+    MODIFY COLUMN event_type ENUM('created', 'comment', 'status', 'edit', 'consent', 'approval') NOT NULL;
+
+-- 教務主事を追加し、教務係も教室変更などを承認できるようにする
+INSERT IGNORE INTO role_permissions (role, permission) VALUES
+('academic_dean', 'request.create'),
+('academic_dean', 'request.approve'),
+('academic_affairs', 'request.approve');
+
+-- 初期ポリシー：教室変更は教務係のみ、それ以外は学科長 → 教務主事
+INSERT INTO approval_policies (id, name, request_kind, priority) VALUES
+(1, '標準（学科長 → 教務主事）', NULL, 0),
+(2, '教室変更（教務係）', 'room_change', 10)
+ON DUPLICATE KEY UPDATE id = id;
+
+INSERT IGNORE INTO approval_policy_steps (policy_id, step_order, role) VALUES
+(1, 1, 'department_head'),
+(1, 2, 'academic_dean'),
+(2, 1, 'academic_affairs');
@@ -0,0 +1,2 @@
+-- 反映を取り消した日時（取り消した申請の反映記録は残す）
+ALTER TABLE change_request_applications ADD COLUMN IF NOT EXISTS reverted_at TIMESTAMP NULL AFTER applied_at;
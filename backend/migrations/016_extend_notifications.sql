@@ -0,0 +1,7 @@
+-- 通知（001 で作成済み）に、通知の原因となった出来事と関連する申請、既読にした日時を追加
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS event_type VARCHAR(50) NULL AFTER type;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS change_request_id INT NULL AFTER event_type;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS read_at TIMESTAMP NULL AFTER is_read;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_request
+    FOREIGN KEY (change_request_id) REFERENCES change_requests(id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD INDEX IF NOT EXISTS idx_user_read (user_id, is_read, created_at);
@@ -0,0 +1,11 @@
+-- 申請の実施日と判断期限（期限切れ・期限超過の通知・リマインド）
+ALTER TABLE change_requests
+    MODIFY COLUMN status ENUM('draft', 'awaiting_consent', 'pending', 'approved', 'applied', 'rejected', 'canceled', 'reverted', 'expired') NOT NULL DEFAULT 'pending';
+ALTER TABLE change_requests ADD COLUMN IF NOT EXISTS effective_date DATE NULL AFTER request_data;
+ALTER TABLE change_requests ADD COLUMN IF NOT EXISTS decision_deadline DATETIME NULL AFTER effective_date;
+ALTER TABLE change_requests ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMP NULL AFTER decision_deadline;
+ALTER TABLE change_requests ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP NULL AFTER reminded_at;
+ALTER TABLE change_requests ADD INDEX IF NOT EXISTS idx_status_deadline (status, decision_deadline);
+
+ALTER TABLE change_request_events
+    MODIFY COLUMN event_type ENUM('created', 'comment', 'status', 'edit', 'consent', 'approval', 'deadline') NOT NULL;
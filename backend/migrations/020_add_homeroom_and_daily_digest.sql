@@ -0,0 +1,11 @@
+-- クラスの担任と、翌授業日の変更まとめ（ダイジェスト）の送信記録
+ALTER TABLE classes ADD COLUMN IF NOT EXISTS homeroom_teacher_id INT NULL AFTER department_id;
+ALTER TABLE classes ADD CONSTRAINT fk_classes_homeroom_teacher
+    FOREIGN KEY (homeroom_teacher_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- 対象日ごとに1回だけ送信する
+CREATE TABLE IF NOT EXISTS digest_runs (
+    target_date DATE PRIMARY KEY,
+    recipients INT NOT NULL DEFAULT 0,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
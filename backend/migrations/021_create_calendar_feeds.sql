@@ -0,0 +1,31 @@
+-- 時限の開始・終了時刻（iCalendar 配信の予定の時刻に使う）
+CREATE TABLE IF NOT EXISTS bell_schedule (
+    period INT PRIMARY KEY CHECK (period BETWEEN 1 AND 4),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO bell_schedule (period, start_time, end_time) VALUES
+(1, '08:50:00', '10:20:00'),
+(2, '10:30:00', '12:00:00'),
+(3, '12:50:00', '14:20:00'),
+(4, '14:30:00', '16:00:00');
+
+-- クラス・教員・教室ごとの iCalendar 配信（URL に含めるトークンで認証する）
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    scope ENUM('class', 'teacher', 'room') NOT NULL,
+    class_id INT NULL,
+    teacher_id INT NULL,
+    room VARCHAR(50) NULL,
+    name VARCHAR(100) NOT NULL,
+    token CHAR(64) NOT NULL UNIQUE,
+    last_accessed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (class_id) REFERENCES classes(id) ON DELETE CASCADE,
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id)
+);
@@ -0,0 +1,39 @@
+-- 教室マスタ（授業場所の表記を統一し、教室の重複を確認できるようにする）
+-- 時間割などの授業場所には教室名（rooms.name）をそのまま保存する。「未定」は教室として登録しない
+CREATE TABLE IF NOT EXISTS rooms (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    building VARCHAR(50) NOT NULL DEFAULT '',
+    capacity INT NULL,
+    room_type ENUM('hr', 'lecture', 'lab', 'gym', 'special', 'other') NOT NULL DEFAULT 'other',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- 教室の別表記（「1-1 HR」「１－１HR」など。インポート・申請の入力を教室名に読み替える）
+CREATE TABLE IF NOT EXISTS room_aliases (
+    alias VARCHAR(50) PRIMARY KEY,
+    room_id INT NOT NULL,
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
+);
+
+-- 既存の授業場所の前後の空白（全角を含む）を除く
+UPDATE timetables SET room = TRIM(REPLACE(room, '　', ' '));
+UPDATE subject_assignments SET room = TRIM(REPLACE(room, '　', ' '));
+UPDATE timetable_overrides SET new_room = TRIM(REPLACE(new_room, '　', ' ')) WHERE new_room IS NOT NULL;
+
+-- 既存の授業場所を教室として登録（全角・半角や空白だけが違う表記の揺れは、POST /api/rooms/merge-duplicates で1つの教室にまとめる）
+INSERT IGNORE INTO rooms (name, room_type)
+SELECT room,
+       CASE
+           WHEN room LIKE '%HR' THEN 'hr'
+           WHEN room LIKE '%実験%' OR room LIKE '%実習%' THEN 'lab'
+           WHEN room LIKE '%体育%' OR room LIKE '%グラウンド%' THEN 'gym'
+           ELSE 'other'
+       END
+FROM (
+    SELECT room FROM timetables
+    UNION SELECT room FROM subject_assignments
+    UNION SELECT new_room FROM timetable_overrides WHERE new_room IS NOT NULL
+) r
+WHERE room NOT IN ('', '未定');
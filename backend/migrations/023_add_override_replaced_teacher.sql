@@ -0,0 +1,4 @@
+-- 代講で交代する教員（NULL は主担当。共同担当の授業でその教員だけを入れ替える）
+ALTER TABLE timetable_overrides ADD COLUMN IF NOT EXISTS replaced_teacher_id INT NULL AFTER new_teacher_id;
+ALTER TABLE timetable_overrides ADD CONSTRAINT fk_timetable_overrides_replaced_teacher
+    FOREIGN KEY (replaced_teacher_id) REFERENCES users(id) ON DELETE SET NULL;